@@ -3,7 +3,14 @@ package main
 import (
 	"context"
 	"debez/internal/config"
+	"debez/internal/repository"
+	"debez/internal/service"
 	v1 "debez/internal/transport/http/v1"
+	"debez/internal/userevents"
+	"debez/pkg/cdc"
+	"debez/pkg/cdc/pgoutput"
+	"debez/pkg/cdc/sink"
+	debeziumclient "debez/pkg/debezium-client"
 	"debez/pkg/logger"
 	"debez/pkg/postgres"
 	"errors"
@@ -13,6 +20,7 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -42,14 +50,237 @@ func main() {
 		return
 	}
 
+	metrics := cdc.NewMetrics()
+	dispatcher := cdc.NewDispatcher()
+	var deadLetters cdc.DeadLetterStore
+	if cfg.CDC.DeadLetter {
+		deadLetters = repository.NewDeadLetterRepository(db.Pool)
+		// Outermost, so logging and metrics still see the failure the queue absorbs.
+		dispatcher.Use(cdc.DeadLetters(deadLetters))
+	}
+	dispatcher.Use(
+		cdc.Logging(),
+		metrics.Middleware(),
+		cdc.Retry(cfg.CDC.HandlerRetries, cfg.CDC.HandlerRetryBackoff),
+	)
+	var processedEvents *repository.ProcessedEventRepository
+	if cfg.CDC.Dedup {
+		processedEvents = repository.NewProcessedEventRepository(db.Pool)
+		// Innermost, so every retry runs in a fresh transaction. Only handlers that write to
+		// the database are deduplicated, in-memory ones would pay a transaction per event.
+		dispatcher.Use(cdc.Only(cdc.Idempotent(processedEvents), userHistoryHandler, webhooksHandler))
+	}
+	if cfg.CDC.GroupTransactions {
+		if cfg.CDC.Source != cdcSourcePgoutput {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "CDC_GROUP_TRANSACTIONS requires CDC_SOURCE=pgoutput")
+			return
+		}
+		dispatcher.GroupTransactions(cfg.CDC.MaxTxEvents, cfg.CDC.TxTimeout)
+	}
+
+	// Snapshot reads record the state users had when capture started.
+	userHistory := service.NewUserHistoryService(repository.NewUserHistoryRepository(db.Pool))
+	dispatcher.RegisterTx(userHistoryHandler, cdc.Route{
+		Schema: userevents.Schema,
+		Table:  userevents.Table,
+		Ops:    []cdc.Op{cdc.OpCreate, cdc.OpUpdate, cdc.OpDelete, cdc.OpTruncate, cdc.OpRead},
+	}, userHistory)
+
+	var webhooks *service.WebhookService
+	var webhookRepository *repository.WebhookRepository
+	if cfg.Webhooks.Enabled {
+		webhookRepository = repository.NewWebhookRepository(db.Pool)
+		webhooks = service.NewWebhookService(webhookRepository, service.WebhookOptions{
+			Timeout:              cfg.Webhooks.Timeout,
+			PollInterval:         cfg.Webhooks.PollInterval,
+			Backoff:              cfg.Webhooks.Backoff,
+			MaxBackoff:           cfg.Webhooks.MaxBackoff,
+			MaxFailures:          cfg.Webhooks.MaxFailures,
+			Concurrency:          cfg.Webhooks.Concurrency,
+			AllowPrivateNetworks: cfg.Webhooks.AllowPrivate,
+		})
+		dispatcher.Register(webhooksHandler, cdc.Route{Schema: userevents.Schema, Table: userevents.Table}, webhooks)
+	}
+
+	sinks, err := registerSinks(dispatcher, cfg.Sinks, deadLetters)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to create sinks", zap.Error(err))
+		return
+	}
+
+	hub := cdc.NewHub(cfg.CDC.StreamHistory)
+	dispatcher.Register(userChangesHandler, cdc.Route{Schema: userevents.Schema, Table: userevents.Table}, hub)
+	socketHub := cdc.NewHub(0)
+	dispatcher.Register(socketHandler, cdc.Route{}, socketHub)
+
+	// Schema changes are detected once per event, before any handler decodes it.
+	var handler cdc.Handler = dispatcher
+	var schemaVersions *service.SchemaVersionService
+	if cfg.CDC.SchemaDetection {
+		schemaVersionRepository := repository.NewSchemaVersionRepository(db.Pool)
+		schemaVersions = service.NewSchemaVersionService(schemaVersionRepository)
+		handler = cdc.NewSchemaDetector(schemaVersionRepository, schemaVersions).Middleware()(dispatcher)
+	}
+
+	// The recording sees the events in the order they arrived, before the workers reorder them.
+	var record cdc.Middleware
+	if cfg.CDC.RecordFile != "" {
+		recordFile, err := os.OpenFile(cfg.CDC.RecordFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to open change event recording", zap.Error(err))
+			return
+		}
+		defer recordFile.Close()
+		record = cdc.Record(recordFile)
+	}
+	// Grouped events are buffered after the receiver acknowledged them, so it is not served.
+	var receiver cdc.Handler
+	if !cfg.CDC.GroupTransactions {
+		receiver = recorded(handler, record)
+	}
+
+	// Replays skip schema detection and are not recorded again.
+	var replay *cdc.Dispatcher
+	replayHandlers := []string{userChangesHandler, socketHandler}
+	if cfg.CDC.ReplayEnabled {
+		replay = dispatcher
+	}
+
 	server := v1.NewServer(cfg.Server.Port, db.Pool)
+	server.SetAdmin(v1.Admin{
+		Tables:       cfg.CDC.Tables,
+		SecretHeader: cfg.Server.AdminHeader,
+		Secret:       cfg.Server.AdminSecret,
+	})
+	if cfg.Cache.Enabled {
+		userCache := service.NewCachedUserRepository(repository.NewUserRepository(db.Pool), cfg.Cache.Size, cfg.Cache.Pages, cfg.Cache.TTL)
+		dispatcher.Register(userCacheHandler, cdc.Route{Schema: userevents.Schema, Table: userevents.Table}, userCache)
+		server.SetUserRepository(userCache)
+		replayHandlers = append(replayHandlers, userCacheHandler)
+	}
+	server.SetChangeEvents(v1.ChangeEvents{
+		Handler:        receiver,
+		SinkHeader:     cfg.CDC.SinkHeader,
+		SinkSecret:     cfg.CDC.SinkSecret,
+		Metrics:        metrics,
+		Hub:            hub,
+		Keepalive:      cfg.CDC.StreamKeepalive,
+		SocketHub:      socketHub,
+		SocketBuffer:   cfg.CDC.SocketBuffer,
+		Dispatcher:     dispatcher,
+		Replay:         replay,
+		ReplayHandlers: replayHandlers,
+		SchemaVersions: schemaVersions,
+	})
 	err = server.RegisterHandler(ctx)
 	if err != nil {
 		logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to register http handler", zap.Error(err))
 		return
 	}
 
+	bgCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+
 	wg := sync.WaitGroup{}
+	if cfg.Heartbeat.Enabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "heartbeat started", zap.Duration("interval", cfg.Heartbeat.Interval))
+			postgres.NewHeartbeat(db, cfg.Heartbeat.Interval).Run(bgCtx)
+		}()
+	}
+
+	if processedEvents != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			service.NewProcessedEventPruner(processedEvents, cfg.CDC.DedupRetention).Run(bgCtx)
+		}()
+	}
+
+	if webhooks != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "webhook delivery started", zap.Duration("interval", cfg.Webhooks.PollInterval))
+			webhooks.Run(bgCtx)
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			service.NewWebhookPruner(webhookRepository, cfg.Webhooks.Retention).Run(bgCtx)
+		}()
+	}
+
+	if cfg.CDC.GroupTransactions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dispatcher.RunTxTimeouts(bgCtx)
+		}()
+	}
+
+	for _, batcher := range sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			batcher.Run(bgCtx)
+		}()
+	}
+
+	if cfg.Debezium.Watch {
+		connectorEvents := service.NewConnectorEventService(
+			repository.NewConnectorEventRepository(db.Pool),
+			debeziumclient.New(cfg.Debezium.BaseURL, cfg.Debezium.Timeout),
+		)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "connector watcher started", zap.Duration("interval", cfg.Debezium.PollInterval))
+			connectorEvents.Watch(bgCtx, cfg.Debezium.PollInterval)
+		}()
+	}
+
+	if cfg.CDC.Source == cdcSourcePgoutput {
+		if err := db.EnsurePublication(ctx, cfg.CDC.Publication, cfg.CDC.Tables...); err != nil {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to ensure publication", zap.Error(err))
+			return
+		}
+		checkpoints := cdc.NewCheckpointer(repository.NewCheckpointRepository(db.Pool), cfg.CDC.CheckpointInterval)
+		source := pgoutput.NewSource(pgoutput.Config{
+			Postgres:    cfg.Postgres,
+			Slot:        cfg.CDC.Slot,
+			Publication: cfg.CDC.Publication,
+			Tables:      cfg.CDC.Tables,
+			ServerName:  cfg.CDC.ServerName,
+			CreateSlot:  true,
+			Checkpoints: checkpoints,
+		})
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			checkpoints.Run(bgCtx)
+		}()
+		go func() {
+			defer wg.Done()
+			pool := cdc.PoolConfig{
+				Workers:   cfg.CDC.Workers,
+				QueueSize: cfg.CDC.WorkerQueue,
+				Key:       cdc.KeyColumns(cfg.CDC.KeyColumns...),
+			}
+			if cfg.CDC.GroupTransactions {
+				// Workers would buffer a transaction in the order they finish its events.
+				pool.Workers = 0
+			}
+			runSource(bgCtx, source, handler, record, pool, cfg.CDC.RetryDelay)
+			// Save the positions confirmed after the checkpointer's last flush.
+			if err := checkpoints.Flush(ctx); err != nil {
+				logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to flush checkpoints", zap.Error(err))
+			}
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -68,16 +299,114 @@ func main() {
 	if err := server.Stop(shutdownCtx); err != nil {
 		logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to stop http server", zap.Error(err))
 	}
-	db.Close()
+	stopBackground()
 	wg.Wait()
+	db.Close()
 	logger.GetLoggerFromCtx(ctx).Info(ctx, "service stopped")
 }
 
+const cdcSourcePgoutput = "pgoutput"
+
+// Names of the handlers that write to the database, they are deduplicated.
+const (
+	userHistoryHandler = "user-history"
+	webhooksHandler    = "webhooks"
+)
+
+// Names of the handlers without effects outside the service, replays go to them by default.
+const (
+	userChangesHandler = "user-changes-stream"
+	socketHandler      = "change-events-socket"
+	userCacheHandler   = "user-cache"
+)
+
+// registerSinks registers a batcher per configured sink for users changes, snapshot
+// reads included, so a new sink starts with a full copy.
+func registerSinks(dispatcher *cdc.Dispatcher, cfg config.Sinks, deadLetters cdc.DeadLetterStore) ([]*sink.Batcher, error) {
+	route := cdc.Route{
+		Schema: userevents.Schema,
+		Table:  userevents.Table,
+		Ops:    []cdc.Op{cdc.OpCreate, cdc.OpUpdate, cdc.OpDelete, cdc.OpRead, cdc.OpTruncate},
+	}
+	batcherConfig := func(name string) sink.Config {
+		return sink.Config{
+			Name:          name,
+			BatchSize:     cfg.BatchSize,
+			FlushInterval: cfg.FlushInterval,
+			Retries:       cfg.Retries,
+			Backoff:       cfg.Backoff,
+			MaxBackoff:    cfg.MaxBackoff,
+			DeadLetters:   deadLetters,
+		}
+	}
+
+	var batchers []*sink.Batcher
+	if cfg.JSONLDir != "" {
+		jsonl, err := sink.NewJSONL(sink.JSONLConfig{
+			Dir:      cfg.JSONLDir,
+			Prefix:   userevents.Table,
+			MaxBytes: cfg.JSONLMaxBytes,
+			MaxAge:   cfg.JSONLMaxAge,
+		})
+		if err != nil {
+			return nil, err
+		}
+		batcher := sink.NewBatcher(jsonl, batcherConfig("jsonl-sink"))
+		dispatcher.Register("jsonl-sink", route, batcher)
+		batchers = append(batchers, batcher)
+	}
+	if cfg.ElasticsearchURL != "" {
+		es := sink.NewElasticsearch(sink.ElasticsearchConfig{
+			URL:   cfg.ElasticsearchURL,
+			Index: cfg.ElasticsearchIndex,
+		})
+		batcher := sink.NewBatcher(es, batcherConfig("elasticsearch-sink"))
+		dispatcher.Register("elasticsearch-sink", route, batcher)
+		batchers = append(batchers, batcher)
+	}
+	return batchers, nil
+}
+
+// runSource streams until ctx is done, restarting the source after errors. With
+// pool.Workers set, events are handled by a fresh cdc.Pool on every run, so a failed
+// run resumes from the last position all workers finished. record, when set, wraps
+// whatever the source feeds. Unconfirmed transactions are redelivered by the slot
+// after every restart, and recorded again.
+func runSource(ctx context.Context, source *pgoutput.Source, handler cdc.Handler, record cdc.Middleware, pool cdc.PoolConfig, retryDelay time.Duration) {
+	for {
+		var err error
+		if pool.Workers > 0 {
+			workers := cdc.NewPool(ctx, handler, pool)
+			err = source.Run(ctx, recorded(workers, record))
+			if poolErr := workers.Close(); poolErr != nil {
+				err = errors.Join(err, poolErr)
+			}
+		} else {
+			err = source.Run(ctx, recorded(handler, record))
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		logger.GetLoggerFromCtx(ctx).Info(ctx, "logical replication stopped", zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func recorded(handler cdc.Handler, record cdc.Middleware) cdc.Handler {
+	if record == nil {
+		return handler
+	}
+	return record(handler)
+}
+
 /*
 TODO
 1) Дописать gRPC api
 2) Подключить бд
 3) Написать запросы
-4) Кэш
 
 */
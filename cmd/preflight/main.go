@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"debez/internal/config"
+	"debez/pkg/postgres"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	var (
+		user        string
+		tables      string
+		publication string
+		slot        string
+		strict      bool
+		timeout     time.Duration
+	)
+
+	flag.StringVar(&user, "user", "", "connector database user (defaults to POSTGRES_USER)")
+	flag.StringVar(&tables, "tables", "public.users", "comma separated list of captured tables")
+	flag.StringVar(&publication, "publication", "dbz_publication", "publication used by the connector")
+	flag.StringVar(&slot, "slot", "debezium", "replication slot used by the connector")
+	flag.BoolVar(&strict, "strict", false, "treat warnings as failures")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "timeout for all checks")
+	flag.Parse()
+
+	envPath := os.Getenv("ENV_PATH")
+	if envPath == "" {
+		envPath = "./config/.env"
+	}
+
+	cfg, err := config.ParseConfig(envPath)
+	if err != nil {
+		fmt.Printf("error parsing config: %v\n", err)
+		os.Exit(1)
+	}
+	if user == "" {
+		user = cfg.Postgres.User
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	db, err := postgres.New(ctx, cfg.Postgres)
+	if err != nil {
+		fmt.Printf("failed to connect to db: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	report, err := db.Preflight(ctx, postgres.PreflightOptions{
+		User:        user,
+		Tables:      splitList(tables),
+		Publication: publication,
+		Slot:        slot,
+	})
+	if err != nil {
+		fmt.Printf("preflight failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Printf("failed to encode report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if report.Failed() || (strict && report.Status == postgres.CheckWarn) {
+		os.Exit(2)
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -12,14 +12,121 @@ type Config struct {
 	Environment string `env:"ENV" env-default:"development"`
 	Server      Server
 	Debezium    Debezium
+	Heartbeat   Heartbeat
+	CDC         CDC
+	Cache       Cache
+	Webhooks    Webhooks
+	Sinks       Sinks
 	Postgres    postgres.Config
 }
 type Server struct {
 	Port    int           `env:"PORT"         env-default:"8080"`
 	TimeOut time.Duration `env:"HTTP_TIMEOUT" env-default:"30s"`
+	// AdminSecret must be sent in AdminHeader to change replica identities of the CDC tables.
+	// Empty refuses these changes.
+	AdminHeader string `env:"ADMIN_HEADER" env-default:"X-Admin-Secret"`
+	AdminSecret string `env:"ADMIN_SECRET"`
 }
 type Debezium struct {
-	BaseURL string `env:"DEBEZIUM_BASE_URL" env-default:"http://localhost:8080"`
+	BaseURL      string        `env:"DEBEZIUM_BASE_URL"      env-default:"http://localhost:8080"`
+	Timeout      time.Duration `env:"DEBEZIUM_TIMEOUT"       env-default:"10s"`
+	Watch        bool          `env:"DEBEZIUM_WATCH"         env-default:"false"`
+	PollInterval time.Duration `env:"DEBEZIUM_POLL_INTERVAL" env-default:"15s"`
+}
+type Heartbeat struct {
+	Enabled  bool          `env:"HEARTBEAT_ENABLED"  env-default:"true"`
+	Interval time.Duration `env:"HEARTBEAT_INTERVAL" env-default:"30s"`
+}
+type Cache struct {
+	// Enabled caches users reads. Enable it with a CDC source feeding invalidations, writes
+	// made outside this service are otherwise served stale for up to TTL.
+	Enabled bool `env:"CACHE_ENABLED" env-default:"false"`
+	Size    int  `env:"CACHE_SIZE"    env-default:"10000"`
+	Pages   int  `env:"CACHE_PAGES"   env-default:"100"`
+	// TTL bounds staleness when no change events arrive, normally entries are invalidated by them.
+	TTL time.Duration `env:"CACHE_TTL" env-default:"1m"`
+}
+type Webhooks struct {
+	// Enabled queues and sends deliveries. Instances claim deliveries, so it may be set on all of them.
+	Enabled      bool          `env:"WEBHOOKS_ENABLED"       env-default:"true"`
+	PollInterval time.Duration `env:"WEBHOOKS_POLL_INTERVAL" env-default:"1s"`
+	Timeout      time.Duration `env:"WEBHOOKS_TIMEOUT"       env-default:"10s"`
+	Backoff      time.Duration `env:"WEBHOOKS_BACKOFF"       env-default:"5s"`
+	MaxBackoff   time.Duration `env:"WEBHOOKS_MAX_BACKOFF"   env-default:"1h"`
+	MaxFailures  int           `env:"WEBHOOKS_MAX_FAILURES"  env-default:"10"`
+	Concurrency  int           `env:"WEBHOOKS_CONCURRENCY"   env-default:"8"`
+	// Retention is how long delivered deliveries and attempts are kept, and how long deliveries
+	// are queued for a subscription disabled after failures before they expire.
+	Retention time.Duration `env:"WEBHOOKS_RETENTION" env-default:"168h"`
+	// AllowPrivate lets subscriptions target loopback, link-local and private addresses. The
+	// subscription API is not authenticated: enable it for local development only.
+	AllowPrivate bool `env:"WEBHOOKS_ALLOW_PRIVATE" env-default:"false"`
+}
+type Sinks struct {
+	BatchSize     int           `env:"SINK_BATCH_SIZE"     env-default:"500"`
+	FlushInterval time.Duration `env:"SINK_FLUSH_INTERVAL" env-default:"1s"`
+	Retries       int           `env:"SINK_RETRIES"        env-default:"5"`
+	Backoff       time.Duration `env:"SINK_BACKOFF"        env-default:"500ms"`
+	MaxBackoff    time.Duration `env:"SINK_MAX_BACKOFF"    env-default:"30s"`
+	// JSONLDir enables the JSON lines file sink, files rotate at JSONLMaxBytes or JSONLMaxAge.
+	JSONLDir      string        `env:"SINK_JSONL_DIR"`
+	JSONLMaxBytes int64         `env:"SINK_JSONL_MAX_BYTES" env-default:"104857600"`
+	JSONLMaxAge   time.Duration `env:"SINK_JSONL_MAX_AGE"   env-default:"1h"`
+	// ElasticsearchURL enables indexing users into ElasticsearchIndex with the _bulk API.
+	ElasticsearchURL   string `env:"SINK_ELASTICSEARCH_URL"`
+	ElasticsearchIndex string `env:"SINK_ELASTICSEARCH_INDEX" env-default:"users"`
+}
+type CDC struct {
+	// Source is the in-process change event source: none or pgoutput (logical replication).
+	// Events POSTed by the Debezium Server HTTP sink are accepted whatever the source is.
+	Source      string   `env:"CDC_SOURCE"      env-default:"none"`
+	Slot        string   `env:"CDC_SLOT"        env-default:"debez_inprocess"`
+	Publication string   `env:"CDC_PUBLICATION" env-default:"debez_publication"`
+	Tables      []string `env:"CDC_TABLES"      env-default:"public.users" env-separator:","`
+	ServerName  string   `env:"CDC_SERVER_NAME" env-default:"debez"`
+	// RetryDelay is the pause before the in-process source reconnects after an error.
+	RetryDelay time.Duration `env:"CDC_RETRY_DELAY" env-default:"5s"`
+	SinkHeader string        `env:"CDC_SINK_HEADER" env-default:"X-Debezium-Secret"`
+	// SinkSecret is the shared secret the Debezium Server HTTP sink sends in SinkHeader. Empty disables the check.
+	SinkSecret string `env:"CDC_SINK_SECRET"`
+	// HandlerRetries is how many times a failing handler is called before the event fails.
+	HandlerRetries      int           `env:"CDC_HANDLER_RETRIES"       env-default:"3"`
+	HandlerRetryBackoff time.Duration `env:"CDC_HANDLER_RETRY_BACKOFF" env-default:"200ms"`
+	// Dedup skips events a handler already processed, keyed by LSN, transaction and table.
+	Dedup bool `env:"CDC_DEDUP" env-default:"true"`
+	// DedupRetention is how long processed markers are kept, longer than events can be redelivered.
+	DedupRetention time.Duration `env:"CDC_DEDUP_RETENTION" env-default:"168h"`
+	// DeadLetter parks events a handler still fails on after its retries instead of stopping the source.
+	DeadLetter bool `env:"CDC_DEAD_LETTER" env-default:"true"`
+	// SchemaDetection records the row schema versions of captured tables and alerts on changes.
+	SchemaDetection bool `env:"CDC_SCHEMA_DETECTION" env-default:"true"`
+	// GroupTransactions dispatches the events of a transaction together, MaxTxEvents bounds its
+	// buffer and TxTimeout how long it waits for the rest before dispatching what it has. It
+	// requires the pgoutput source, the HTTP receiver is not served with it: buffered events
+	// would be acknowledged.
+	GroupTransactions bool          `env:"CDC_GROUP_TRANSACTIONS" env-default:"false"`
+	MaxTxEvents       int           `env:"CDC_MAX_TX_EVENTS"      env-default:"10000"`
+	TxTimeout         time.Duration `env:"CDC_TX_TIMEOUT"         env-default:"1m"`
+	// Workers handle replication events in parallel, partitioned by KeyColumns so the events of
+	// a row stay in order. WorkerQueue is each worker's backlog before the stream waits. 0 workers
+	// is sequential, so are grouped transactions: their events must reach the dispatcher in order.
+	Workers     int      `env:"CDC_WORKERS"      env-default:"0"`
+	WorkerQueue int      `env:"CDC_WORKER_QUEUE" env-default:"1024"`
+	KeyColumns  []string `env:"CDC_KEY_COLUMNS"  env-default:"id" env-separator:","`
+	// RecordFile appends every received change event to this file, for replaying with
+	// POST /api/v1/cdc/replay. Empty disables recording.
+	RecordFile string `env:"CDC_RECORD_FILE" env-default:""`
+	// ReplayEnabled serves POST /api/v1/cdc/replay, guarded by SinkSecret like the HTTP receiver.
+	// Replays run in the background, GET /api/v1/cdc/replay/{id} reports their progress. They
+	// only reach the change streams and the cache unless the request names other handlers.
+	ReplayEnabled bool `env:"CDC_REPLAY_ENABLED" env-default:"false"`
+	// CheckpointInterval is how often consumers save their positions.
+	CheckpointInterval time.Duration `env:"CDC_CHECKPOINT_INTERVAL" env-default:"5s"`
+	// StreamHistory is how many events live streams keep for clients resuming with Last-Event-ID.
+	StreamHistory   int           `env:"CDC_STREAM_HISTORY"   env-default:"1000"`
+	StreamKeepalive time.Duration `env:"CDC_STREAM_KEEPALIVE" env-default:"15s"`
+	// SocketBuffer is how many events a WebSocket client may lag behind before it is disconnected.
+	SocketBuffer int `env:"CDC_SOCKET_BUFFER" env-default:"256"`
 }
 
 func ParseConfig(configPath string) (*Config, error) {
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ConnectorEventType string
+
+const (
+	ConnectorObserved      ConnectorEventType = "observed"
+	ConnectorStatusChanged ConnectorEventType = "status_change"
+	ConnectorTaskChanged   ConnectorEventType = "task_status_change"
+	ConnectorRestarted     ConnectorEventType = "restart"
+	ConnectorConfigChanged ConnectorEventType = "config_change"
+	ConnectorFailed        ConnectorEventType = "failure"
+	ConnectorRemoved       ConnectorEventType = "removed"
+)
+
+type ConnectorEvent struct {
+	ID            int64              `json:"id"`
+	Connector     string             `json:"connector"`
+	TaskID        *int               `json:"task_id,omitempty"`
+	Type          ConnectorEventType `json:"event_type"`
+	State         string             `json:"state,omitempty"`
+	PreviousState string             `json:"previous_state,omitempty"`
+	WorkerID      string             `json:"worker_id,omitempty"`
+	Trace         string             `json:"trace,omitempty"`
+	Config        json.RawMessage    `json:"config,omitempty"`
+	OccurredAt    time.Time          `json:"occurred_at"`
+}
+
+type ConnectorEventFilter struct {
+	Connector string
+	From      time.Time
+	To        time.Time
+	Offset    int
+	Limit     int
+}
@@ -1,9 +1,18 @@
 package models
 
+import (
+	"errors"
+	"time"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
 type User struct {
-	ID       int64    `json:"id"`
-	Email    string   `json:"email"`
-	Name     string   `json:"name"`
-	LastName string   `json:"last_name"`
-	Role     []string `json:"role"`
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	LastName  string    `json:"last_name"`
+	Role      []string  `json:"role"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
 }
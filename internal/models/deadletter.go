@@ -0,0 +1,56 @@
+package models
+
+import (
+	"debez/pkg/cdc"
+	"errors"
+	"time"
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrDeadLetterResolved is returned when replaying or discarding an entry that is no longer pending.
+	ErrDeadLetterResolved = errors.New("dead letter already resolved")
+)
+
+type DeadLetterStatus string
+
+const (
+	DeadLetterPending DeadLetterStatus = "pending"
+	// DeadLetterReplaying marks an entry claimed by a running replay.
+	DeadLetterReplaying DeadLetterStatus = "replaying"
+	DeadLetterReplayed  DeadLetterStatus = "replayed"
+	DeadLetterDiscarded DeadLetterStatus = "discarded"
+)
+
+// DeadLetter is a stored cdc.DeadLetter. Tx holds every event of a failed transaction, Event
+// is its last one.
+type DeadLetter struct {
+	ID         int64            `json:"id"`
+	Handler    string           `json:"handler"`
+	Schema     string           `json:"schema"`
+	Table      string           `json:"table"`
+	Op         string           `json:"op"`
+	LSN        string           `json:"lsn,omitempty"`
+	Event      cdc.Event        `json:"event"`
+	Tx         *cdc.Tx          `json:"transaction,omitempty"`
+	Error      string           `json:"error"`
+	Attempts   int              `json:"attempts"`
+	Status     DeadLetterStatus `json:"status"`
+	FailedAt   time.Time        `json:"failed_at"`
+	ResolvedAt *time.Time       `json:"resolved_at,omitempty"`
+}
+
+// DeadLetterFilter selects entries, IDs wins over the other fields when set.
+type DeadLetterFilter struct {
+	IDs     []int64
+	Handler string
+	Status  DeadLetterStatus
+	Offset  int
+	Limit   int
+}
+
+// DeadLetterResult is the outcome of a bulk replay or discard.
+type DeadLetterResult struct {
+	Succeeded []int64          `json:"succeeded"`
+	Failed    map[int64]string `json:"failed,omitempty"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// UserHistoryEntry is one captured change of a user.
+type UserHistoryEntry struct {
+	ID            int64       `json:"id"`
+	UserID        int64       `json:"user_id"`
+	Op            string      `json:"op"`
+	Before        *User       `json:"before"`
+	After         *User       `json:"after"`
+	ChangedFields []string    `json:"changed_fields"`
+	Diff          []FieldDiff `json:"diff,omitempty"`
+	LSN           string      `json:"lsn"`
+	TxID          int64       `json:"tx_id,omitempty"`
+	CommittedAt   time.Time   `json:"committed_at"`
+	RecordedAt    time.Time   `json:"recorded_at,omitzero"`
+}
+
+type FieldDiff struct {
+	Field string          `json:"field"`
+	Old   json.RawMessage `json:"old"`
+	New   json.RawMessage `json:"new"`
+}
+
+type UserHistoryFilter struct {
+	UserID int64
+	Offset int
+	Limit  int
+}
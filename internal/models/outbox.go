@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const AggregateUser = "user"
+
+type OutboxEventType string
+
+const (
+	UserCreated OutboxEventType = "UserCreated"
+	UserUpdated OutboxEventType = "UserUpdated"
+	UserDeleted OutboxEventType = "UserDeleted"
+)
+
+// OutboxEvent is a domain event written to the outbox table. Debezium's EventRouter
+// routes it by AggregateType and keys the Kafka message by AggregateID.
+type OutboxEvent struct {
+	ID            string          `json:"id"`
+	AggregateType string          `json:"aggregatetype"`
+	AggregateID   string          `json:"aggregateid"`
+	Type          OutboxEventType `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at,omitzero"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrReplayNotFound = errors.New("replay not found")
+	// ErrReplayRunning is returned when starting a replay while another one runs.
+	ErrReplayRunning = errors.New("a replay is already running")
+	// ErrReplayHandler is returned for replay targets that are not registered handlers.
+	ErrReplayHandler = errors.New("unknown replay handler")
+)
+
+type ReplayStatus string
+
+const (
+	ReplayRunning  ReplayStatus = "running"
+	ReplayDone     ReplayStatus = "done"
+	ReplayFailed   ReplayStatus = "failed"
+	ReplayCanceled ReplayStatus = "canceled"
+)
+
+// ReplayJob is a recording being replayed in the background, Replayed counts its lines so far.
+type ReplayJob struct {
+	ID         int64        `json:"id"`
+	Status     ReplayStatus `json:"status"`
+	Handlers   []string     `json:"handlers"`
+	Speed      float64      `json:"speed"`
+	Replayed   int          `json:"replayed"`
+	Error      string       `json:"error,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+}
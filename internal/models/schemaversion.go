@@ -0,0 +1,10 @@
+package models
+
+// SchemaVersionFilter selects detected schema versions, newest first. Empty Schema and
+// Table match every captured table.
+type SchemaVersionFilter struct {
+	Schema string
+	Table  string
+	Offset int
+	Limit  int
+}
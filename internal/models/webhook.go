@@ -0,0 +1,63 @@
+package models
+
+import (
+	"debez/pkg/cdc"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook subscription not found")
+	ErrInvalidWebhook  = errors.New("invalid webhook subscription")
+)
+
+// WebhookSubscription receives the user change events matching Filter. Secret signs the
+// deliveries and is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID                  int64      `json:"id"`
+	URL                 string     `json:"url"`
+	Secret              string     `json:"secret,omitempty"`
+	Filter              cdc.Filter `json:"filter"`
+	Enabled             bool       `json:"enabled"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledReason      string     `json:"disabled_reason,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscription_id"`
+	EventID        string          `json:"event_id"`
+	Payload        json.RawMessage `json:"payload"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+}
+
+type WebhookAttempt struct {
+	ID             int64     `json:"id"`
+	DeliveryID     int64     `json:"delivery_id"`
+	SubscriptionID int64     `json:"subscription_id"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	AttemptedAt    time.Time `json:"attempted_at"`
+}
+
+type WebhookEventType string
+
+const (
+	WebhookUserCreated WebhookEventType = "user.created"
+	WebhookUserUpdated WebhookEventType = "user.updated"
+	WebhookUserDeleted WebhookEventType = "user.deleted"
+)
+
+// WebhookPayload is the body POSTed to subscribers. ID is the LSN of the change.
+type WebhookPayload struct {
+	ID          string           `json:"id"`
+	Type        WebhookEventType `json:"type"`
+	Before      *User            `json:"before"`
+	After       *User            `json:"after"`
+	CommittedAt time.Time        `json:"committed_at"`
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"debez/pkg/cdc"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CheckpointRepository struct {
+	db      *pgxpool.Pool
+	builder squirrel.StatementBuilderType
+}
+
+func NewCheckpointRepository(db *pgxpool.Pool) *CheckpointRepository {
+	return &CheckpointRepository{
+		db:      db,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+	}
+}
+
+func (r *CheckpointRepository) Load(ctx context.Context, source, partition string) (cdc.Checkpoint, bool, error) {
+	sql, args, err := r.builder.Select("source", "partition_name", "position", "updated_at").
+		From("cdc_checkpoints").
+		Where(squirrel.Eq{"source": source, "partition_name": partition}).
+		ToSql()
+	if err != nil {
+		return cdc.Checkpoint{}, false, fmt.Errorf("load checkpoint: %w", err)
+	}
+	var cp cdc.Checkpoint
+	err = r.db.QueryRow(ctx, sql, args...).Scan(&cp.Source, &cp.Partition, &cp.Position, &cp.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return cdc.Checkpoint{}, false, nil
+	}
+	if err != nil {
+		return cdc.Checkpoint{}, false, fmt.Errorf("load checkpoint, Scan: %w", err)
+	}
+	return cp, true, nil
+}
+
+func (r *CheckpointRepository) Save(ctx context.Context, checkpoints ...cdc.Checkpoint) error {
+	if len(checkpoints) == 0 {
+		return nil
+	}
+	query := r.builder.Insert("cdc_checkpoints").
+		Columns("source", "partition_name", "position", "updated_at").
+		Suffix("ON CONFLICT (source, partition_name) DO UPDATE SET position = EXCLUDED.position, updated_at = EXCLUDED.updated_at")
+	for _, cp := range checkpoints {
+		query = query.Values(cp.Source, cp.Partition, cp.Position, cp.UpdatedAt)
+	}
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("save checkpoints: %w", err)
+	}
+	if _, err := r.db.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("save checkpoints, exec: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"debez/internal/models"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ConnectorEventRepository struct {
+	db      *pgxpool.Pool
+	builder squirrel.StatementBuilderType
+}
+
+func NewConnectorEventRepository(db *pgxpool.Pool) *ConnectorEventRepository {
+	return &ConnectorEventRepository{
+		db:      db,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+	}
+}
+
+func (r *ConnectorEventRepository) Insert(ctx context.Context, event models.ConnectorEvent) error {
+	var config any
+	if len(event.Config) > 0 {
+		config = string(event.Config)
+	}
+	sql, args, err := r.builder.Insert("connector_events").
+		Columns("connector", "task_id", "event_type", "state", "previous_state", "worker_id", "trace", "config", "occurred_at").
+		Values(event.Connector, event.TaskID, event.Type, event.State, event.PreviousState, event.WorkerID, event.Trace, config, event.OccurredAt).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("insert connector event: %w", err)
+	}
+	if _, err := r.db.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("insert connector event, exec: %w", err)
+	}
+	return nil
+}
+
+func (r *ConnectorEventRepository) Select(ctx context.Context, filter models.ConnectorEventFilter) ([]models.ConnectorEvent, error) {
+	query := r.builder.Select("id", "connector", "task_id", "event_type", "state", "previous_state", "worker_id", "trace", "config", "occurred_at").
+		From("connector_events").
+		Where(squirrel.Eq{"connector": filter.Connector}).
+		OrderBy("occurred_at", "id").
+		Offset(uint64(filter.Offset)).
+		Limit(uint64(filter.Limit))
+	if !filter.From.IsZero() {
+		query = query.Where(squirrel.GtOrEq{"occurred_at": filter.From})
+	}
+	if !filter.To.IsZero() {
+		query = query.Where(squirrel.Lt{"occurred_at": filter.To})
+	}
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("select connector events: %w", err)
+	}
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select connector events, query: %w", err)
+	}
+	events, err := scanConnectorEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("select connector events, %w", err)
+	}
+	return events, nil
+}
+
+// SelectLatest returns the last event of every connector and task, and the last connector
+// event with a config when it is not the last one. Together they are the journaled state.
+func (r *ConnectorEventRepository) SelectLatest(ctx context.Context) ([]models.ConnectorEvent, error) {
+	sql, args, err := r.builder.Select("id", "connector", "task_id", "event_type", "state", "previous_state", "worker_id", "trace", "config", "occurred_at").
+		Options("DISTINCT ON (connector, task_id, config IS NOT NULL)").
+		From("connector_events").
+		OrderBy("connector", "task_id", "config IS NOT NULL", "id DESC").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("select latest connector events: %w", err)
+	}
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select latest connector events, query: %w", err)
+	}
+	events, err := scanConnectorEvents(rows)
+	if err != nil {
+		return nil, fmt.Errorf("select latest connector events, %w", err)
+	}
+	return events, nil
+}
+
+func scanConnectorEvents(rows pgx.Rows) ([]models.ConnectorEvent, error) {
+	defer rows.Close()
+
+	var events []models.ConnectorEvent
+	for rows.Next() {
+		var event models.ConnectorEvent
+		var config []byte
+		if err := rows.Scan(&event.ID, &event.Connector, &event.TaskID, &event.Type, &event.State, &event.PreviousState,
+			&event.WorkerID, &event.Trace, &config, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("Scan: %w", err)
+		}
+		event.Config = config
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return events, nil
+}
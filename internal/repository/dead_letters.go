@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/cdc"
+	"debez/pkg/postgres"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var deadLetterColumns = []string{"id", "handler", "schema_name", "table_name", "op", "lsn", "event", "event_schema", "tx", "error", "attempts", "status", "failed_at", "resolved_at"}
+
+// deadLetterTx is a failed transaction as stored, with the schemas events do not marshal.
+type deadLetterTx struct {
+	ID      string            `json:"id"`
+	Events  []deadLetterEvent `json:"events"`
+	Partial bool              `json:"partial,omitempty"`
+}
+
+type deadLetterEvent struct {
+	Event  cdc.Event   `json:"event"`
+	Schema *cdc.Schema `json:"schema,omitempty"`
+}
+
+// deadLetterClaimTimeout frees claims of replays that never finished, e.g. after a crash.
+const deadLetterClaimTimeout = 10 * time.Minute
+
+type DeadLetterRepository struct {
+	db      *pgxpool.Pool
+	builder squirrel.StatementBuilderType
+}
+
+func NewDeadLetterRepository(db *pgxpool.Pool) *DeadLetterRepository {
+	return &DeadLetterRepository{
+		db:      db,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+	}
+}
+
+// Put stores a failed event, it implements cdc.DeadLetterStore.
+func (r *DeadLetterRepository) Put(ctx context.Context, letter cdc.DeadLetter) error {
+	event, err := json.Marshal(letter.Event)
+	if err != nil {
+		return fmt.Errorf("insert dead letter: %w", err)
+	}
+	var schema, lsn any
+	if letter.Event.Schema != nil {
+		data, err := json.Marshal(letter.Event.Schema)
+		if err != nil {
+			return fmt.Errorf("insert dead letter: %w", err)
+		}
+		schema = string(data)
+	}
+	if l, ok := letter.Event.Source.LSN(); ok {
+		lsn = int64(l)
+	}
+	var tx any
+	if letter.Tx != nil {
+		stored := deadLetterTx{ID: letter.Tx.ID, Partial: letter.Tx.Partial, Events: make([]deadLetterEvent, 0, len(letter.Tx.Events))}
+		for _, e := range letter.Tx.Events {
+			stored.Events = append(stored.Events, deadLetterEvent{Event: e, Schema: e.Schema})
+		}
+		data, err := json.Marshal(stored)
+		if err != nil {
+			return fmt.Errorf("insert dead letter: %w", err)
+		}
+		tx = string(data)
+	}
+	sql, args, err := r.builder.Insert("dead_letter_events").
+		Columns("handler", "schema_name", "table_name", "op", "lsn", "event", "event_schema", "tx", "error", "attempts").
+		Values(letter.Handler, letter.Event.Source.Schema, letter.Event.Source.Table, string(letter.Event.Op), lsn,
+			string(event), schema, tx, letter.Error, letter.Attempts).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("insert dead letter: %w", err)
+	}
+	if _, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("insert dead letter, exec: %w", err)
+	}
+	return nil
+}
+
+func (r *DeadLetterRepository) Select(ctx context.Context, filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	query := r.builder.Select(deadLetterColumns...).
+		From("dead_letter_events").
+		OrderBy("id")
+	if len(filter.IDs) > 0 {
+		query = query.Where(squirrel.Eq{"id": filter.IDs})
+	} else {
+		if filter.Handler != "" {
+			query = query.Where(squirrel.Eq{"handler": filter.Handler})
+		}
+		if filter.Status != "" {
+			query = query.Where(squirrel.Eq{"status": filter.Status})
+		}
+		query = query.Offset(uint64(filter.Offset)).Limit(uint64(filter.Limit))
+	}
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("select dead letters: %w", err)
+	}
+	rows, err := postgres.Conn(ctx, r.db).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select dead letters, query: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []models.DeadLetter
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("select dead letters, Scan: %w", err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select dead letters, rows: %w", err)
+	}
+	return letters, nil
+}
+
+func (r *DeadLetterRepository) SelectByID(ctx context.Context, id int64) (models.DeadLetter, error) {
+	sql, args, err := r.builder.Select(deadLetterColumns...).
+		From("dead_letter_events").
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return models.DeadLetter{}, fmt.Errorf("select dead letter: %w", err)
+	}
+	letter, err := scanDeadLetter(postgres.Conn(ctx, r.db).QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.DeadLetter{}, models.ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return models.DeadLetter{}, fmt.Errorf("select dead letter, Scan: %w", err)
+	}
+	return letter, nil
+}
+
+// Resolve moves a pending entry to status.
+func (r *DeadLetterRepository) Resolve(ctx context.Context, id int64, status models.DeadLetterStatus) error {
+	sql, args, err := r.builder.Update("dead_letter_events").
+		Set("status", status).
+		Set("resolved_at", squirrel.Expr("NOW()")).
+		Where(squirrel.Eq{"id": id, "status": models.DeadLetterPending}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("resolve dead letter: %w", err)
+	}
+	tag, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("resolve dead letter, exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := r.SelectByID(ctx, id); err != nil {
+			return err
+		}
+		return models.ErrDeadLetterResolved
+	}
+	return nil
+}
+
+// Claim moves a pending entry to replaying and returns it, so only one replay runs it.
+// A claim older than deadLetterClaimTimeout is taken over.
+func (r *DeadLetterRepository) Claim(ctx context.Context, id int64) (models.DeadLetter, error) {
+	sql, args, err := r.builder.Update("dead_letter_events").
+		Set("status", models.DeadLetterReplaying).
+		Set("claimed_at", squirrel.Expr("NOW()")).
+		Where(squirrel.Eq{"id": id}).
+		Where(squirrel.Or{
+			squirrel.Eq{"status": models.DeadLetterPending},
+			squirrel.And{
+				squirrel.Eq{"status": models.DeadLetterReplaying},
+				squirrel.Expr("claimed_at < NOW() - make_interval(secs => ?)", deadLetterClaimTimeout.Seconds()),
+			},
+		}).
+		Suffix("RETURNING " + strings.Join(deadLetterColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return models.DeadLetter{}, fmt.Errorf("claim dead letter: %w", err)
+	}
+	letter, err := scanDeadLetter(postgres.Conn(ctx, r.db).QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := r.SelectByID(ctx, id); err != nil {
+			return models.DeadLetter{}, err
+		}
+		return models.DeadLetter{}, models.ErrDeadLetterResolved
+	}
+	if err != nil {
+		return models.DeadLetter{}, fmt.Errorf("claim dead letter, Scan: %w", err)
+	}
+	return letter, nil
+}
+
+// ResolveReplay marks a claimed entry replayed.
+func (r *DeadLetterRepository) ResolveReplay(ctx context.Context, id int64) error {
+	sql, args, err := r.builder.Update("dead_letter_events").
+		Set("status", models.DeadLetterReplayed).
+		Set("resolved_at", squirrel.Expr("NOW()")).
+		Where(squirrel.Eq{"id": id, "status": models.DeadLetterReplaying}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("resolve dead letter replay: %w", err)
+	}
+	if _, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("resolve dead letter replay, exec: %w", err)
+	}
+	return nil
+}
+
+// RecordFailure counts a failed replay of a claimed entry and makes it pending again.
+func (r *DeadLetterRepository) RecordFailure(ctx context.Context, id int64, cause string) error {
+	sql, args, err := r.builder.Update("dead_letter_events").
+		Set("status", models.DeadLetterPending).
+		Set("attempts", squirrel.Expr("attempts + 1")).
+		Set("error", cause).
+		Where(squirrel.Eq{"id": id, "status": models.DeadLetterReplaying}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("record dead letter failure: %w", err)
+	}
+	if _, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("record dead letter failure, exec: %w", err)
+	}
+	return nil
+}
+
+func scanDeadLetter(row pgx.Row) (models.DeadLetter, error) {
+	var letter models.DeadLetter
+	var lsn *int64
+	var event, schema, tx []byte
+	if err := row.Scan(&letter.ID, &letter.Handler, &letter.Schema, &letter.Table, &letter.Op, &lsn, &event, &schema, &tx,
+		&letter.Error, &letter.Attempts, &letter.Status, &letter.FailedAt, &letter.ResolvedAt); err != nil {
+		return models.DeadLetter{}, err
+	}
+	if lsn != nil {
+		letter.LSN = cdc.LSN(*lsn).String()
+	}
+	if err := json.Unmarshal(event, &letter.Event); err != nil {
+		return models.DeadLetter{}, err
+	}
+	if schema != nil {
+		letter.Event.Schema = &cdc.Schema{}
+		if err := json.Unmarshal(schema, letter.Event.Schema); err != nil {
+			return models.DeadLetter{}, err
+		}
+	}
+	if tx != nil {
+		var stored deadLetterTx
+		if err := json.Unmarshal(tx, &stored); err != nil {
+			return models.DeadLetter{}, err
+		}
+		letter.Tx = &cdc.Tx{ID: stored.ID, Partial: stored.Partial, Events: make([]cdc.Event, 0, len(stored.Events))}
+		for _, e := range stored.Events {
+			e.Event.Schema = e.Schema
+			letter.Tx.Events = append(letter.Tx.Events, e.Event)
+		}
+	}
+	return letter, nil
+}
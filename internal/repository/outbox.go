@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/postgres"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OutboxRepository struct {
+	db      *pgxpool.Pool
+	builder squirrel.StatementBuilderType
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) *OutboxRepository {
+	return &OutboxRepository{
+		db:      db,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+	}
+}
+
+// Insert writes event in the transaction of ctx, if any, so it is published only when the
+// change it describes commits. The row is deleted right away: Debezium reads the insert from
+// the WAL and the EventRouter drops the delete, so the table stays empty.
+func (r *OutboxRepository) Insert(ctx context.Context, event models.OutboxEvent) error {
+	sql, args, err := r.builder.Insert("outbox").
+		Columns("aggregatetype", "aggregateid", "type", "payload").
+		Values(event.AggregateType, event.AggregateID, event.Type, string(event.Payload)).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("insert outbox event: %w", err)
+	}
+	conn := postgres.Conn(ctx, r.db)
+	var id string
+	if err := conn.QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+		return fmt.Errorf("insert outbox event, QueryRow: %w", err)
+	}
+
+	sql, args, err = r.builder.Delete("outbox").Where(squirrel.Eq{"id": id}).ToSql()
+	if err != nil {
+		return fmt.Errorf("insert outbox event, delete: %w", err)
+	}
+	if _, err := conn.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("insert outbox event, delete exec: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"debez/pkg/cdc"
+	"debez/pkg/postgres"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var errAlreadyProcessed = errors.New("already processed")
+
+type ProcessedEventRepository struct {
+	db      *pgxpool.Pool
+	builder squirrel.StatementBuilderType
+}
+
+func NewProcessedEventRepository(db *pgxpool.Pool) *ProcessedEventRepository {
+	return &ProcessedEventRepository{
+		db:      db,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+	}
+}
+
+// RunOnce inserts the processed marker and runs fn in one transaction. fn sees the transaction
+// through postgres.Conn, so repositories it calls commit or roll back together with the marker.
+func (r *ProcessedEventRepository) RunOnce(ctx context.Context, handler string, key cdc.EventKey, fn func(ctx context.Context) error) (bool, error) {
+	sql, args, err := r.builder.Insert("processed_events").
+		Columns("handler", "lsn", "tx_id", "schema_name", "table_name").
+		Values(handler, int64(key.LSN), key.TxID, key.Schema, key.Table).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("processed events: %w", err)
+	}
+
+	err = postgres.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		tag, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...)
+		if err != nil {
+			return fmt.Errorf("processed events, exec: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return errAlreadyProcessed
+		}
+		return fn(ctx)
+	})
+	if errors.Is(err, errAlreadyProcessed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteBefore prunes markers older than t. Events older than the slot's confirmed position
+// are never redelivered, so their markers are no longer needed.
+func (r *ProcessedEventRepository) DeleteBefore(ctx context.Context, t time.Time) (int64, error) {
+	sql, args, err := r.builder.Delete("processed_events").
+		Where(squirrel.Lt{"processed_at": t}).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("delete processed events: %w", err)
+	}
+	tag, err := r.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete processed events, exec: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/cdc"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var schemaVersionColumns = []string{"schema_name", "table_name", "version", "fields", "fingerprint", "detected_at"}
+
+type SchemaVersionRepository struct {
+	db      *pgxpool.Pool
+	builder squirrel.StatementBuilderType
+}
+
+func NewSchemaVersionRepository(db *pgxpool.Pool) *SchemaVersionRepository {
+	return &SchemaVersionRepository{
+		db:      db,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+	}
+}
+
+func (r *SchemaVersionRepository) Latest(ctx context.Context, schema, table string) (cdc.TableSchema, bool, error) {
+	sql, args, err := r.builder.Select(schemaVersionColumns...).
+		From("cdc_schema_versions").
+		Where(squirrel.Eq{"schema_name": schema, "table_name": table}).
+		OrderBy("version DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return cdc.TableSchema{}, false, fmt.Errorf("latest schema version: %w", err)
+	}
+	version, err := scanSchemaVersion(r.db.QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return cdc.TableSchema{}, false, nil
+	}
+	if err != nil {
+		return cdc.TableSchema{}, false, fmt.Errorf("latest schema version, Scan: %w", err)
+	}
+	return version, true, nil
+}
+
+// Save stores a version, a version another consumer already saved is kept.
+func (r *SchemaVersionRepository) Save(ctx context.Context, version cdc.TableSchema) error {
+	fields, err := json.Marshal(version.Fields)
+	if err != nil {
+		return fmt.Errorf("save schema version: %w", err)
+	}
+	sql, args, err := r.builder.Insert("cdc_schema_versions").
+		Columns("schema_name", "table_name", "version", "fields", "fingerprint", "detected_at").
+		Values(version.Schema, version.Table, version.Version, fields, version.Fingerprint, version.DetectedAt).
+		Suffix("ON CONFLICT (schema_name, table_name, version) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("save schema version: %w", err)
+	}
+	if _, err := r.db.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("save schema version, exec: %w", err)
+	}
+	return nil
+}
+
+func (r *SchemaVersionRepository) Select(ctx context.Context, filter models.SchemaVersionFilter) ([]cdc.TableSchema, error) {
+	query := r.builder.Select(schemaVersionColumns...).
+		From("cdc_schema_versions").
+		OrderBy("schema_name", "table_name", "version DESC").
+		Offset(uint64(filter.Offset)).
+		Limit(uint64(filter.Limit))
+	if filter.Schema != "" {
+		query = query.Where(squirrel.Eq{"schema_name": filter.Schema})
+	}
+	if filter.Table != "" {
+		query = query.Where(squirrel.Eq{"table_name": filter.Table})
+	}
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("select schema versions: %w", err)
+	}
+	rows, err := r.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select schema versions, query: %w", err)
+	}
+	defer rows.Close()
+	var versions []cdc.TableSchema
+	for rows.Next() {
+		version, err := scanSchemaVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("select schema versions, Scan: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select schema versions, rows: %w", err)
+	}
+	return versions, nil
+}
+
+func scanSchemaVersion(row pgx.Row) (cdc.TableSchema, error) {
+	var version cdc.TableSchema
+	var fields []byte
+	if err := row.Scan(&version.Schema, &version.Table, &version.Version, &fields, &version.Fingerprint, &version.DetectedAt); err != nil {
+		return cdc.TableSchema{}, err
+	}
+	if err := json.Unmarshal(fields, &version.Fields); err != nil {
+		return cdc.TableSchema{}, fmt.Errorf("fields: %w", err)
+	}
+	return version, nil
+}
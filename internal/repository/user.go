@@ -4,6 +4,7 @@ import (
 	"context"
 	"debez/internal/models"
 	"debez/pkg/logger"
+	"debez/pkg/postgres"
 	"fmt"
 
 	"github.com/Masterminds/squirrel"
@@ -32,7 +33,7 @@ func (r *UserRepository) Select(ctx context.Context, offset, limit int) ([]model
 	if err != nil {
 		return nil, fmt.Errorf("select: %w", err)
 	}
-	rows, err := r.db.Query(ctx, sql, args...)
+	rows, err := postgres.Conn(ctx, r.db).Query(ctx, sql, args...)
 	defer func(){
 		rows.Close()
 	}()
@@ -60,7 +61,7 @@ func (r *UserRepository) SelectByID(ctx context.Context, id int64) (models.User,
 	if err != nil {
 		return models.User{}, fmt.Errorf("selectByID: %w", err)
 	}
-	rows, err := r.db.Query(ctx, sql, args...)
+	rows, err := postgres.Conn(ctx, r.db).Query(ctx, sql, args...)
 	defer func(){
 		rows.Close()
 	}()
@@ -79,19 +80,21 @@ func (r *UserRepository) SelectByID(ctx context.Context, id int64) (models.User,
 	}
 	return user, nil
 }
-func (r *UserRepository) Insert(ctx context.Context, user models.User) error {
+func (r *UserRepository) Insert(ctx context.Context, user models.User) (int64, error) {
 	sql, args, err := r.builder.Insert("users").
 		Columns("email", "name", "last_name", "role").
 		Values(user.Email, user.Name, user.LastName, user.Role).
+		Suffix("RETURNING id").
 		ToSql()
 	if err != nil {
-		return fmt.Errorf("insert: %w", err)
+		return 0, fmt.Errorf("insert: %w", err)
 	}
-	_, err = r.db.Exec(ctx, sql, args...)
+	var id int64
+	err = postgres.Conn(ctx, r.db).QueryRow(ctx, sql, args...).Scan(&id)
 	if err != nil {
-		return fmt.Errorf("insert, exec: %w", err)
+		return 0, fmt.Errorf("insert, exec: %w", err)
 	}
-	return nil
+	return id, nil
 }
 func (r *UserRepository) Update(ctx context.Context, user models.User) error {
 	sql, args, err := r.builder.Update("users").
@@ -105,11 +108,14 @@ func (r *UserRepository) Update(ctx context.Context, user models.User) error {
 		logger.GetLoggerFromCtx(ctx).Debug(ctx, "failed to build update sql", zap.Error(err))
 		return fmt.Errorf("update: %w", err)
 	}
-	_, err = r.db.Exec(ctx, sql, args...)
+	tag, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...)
 	if err != nil {
 		logger.GetLoggerFromCtx(ctx).Debug(ctx, "failed to exec update sql", zap.Error(err))
 		return fmt.Errorf("update, exec: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("update: %w", models.ErrUserNotFound)
+	}
 	return nil
 }
 func (r *UserRepository) Delete(ctx context.Context, id int64) error {
@@ -119,9 +125,12 @@ func (r *UserRepository) Delete(ctx context.Context, id int64) error {
 	if err != nil {
 		return fmt.Errorf("delete: %w", err)
 	}
-	_, err = r.db.Exec(ctx, sql, args...)
+	tag, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...)
 	if err != nil {
 		return fmt.Errorf("delete, exec: %w", err)
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delete: %w", models.ErrUserNotFound)
+	}
 	return nil
 }
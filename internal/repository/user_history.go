@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/cdc"
+	"debez/pkg/postgres"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var userHistoryColumns = []string{"id", "user_id", "op", "before", "after", "changed_fields", "lsn", "tx_id", "committed_at", "recorded_at"}
+
+type UserHistoryRepository struct {
+	db      *pgxpool.Pool
+	builder squirrel.StatementBuilderType
+}
+
+func NewUserHistoryRepository(db *pgxpool.Pool) *UserHistoryRepository {
+	return &UserHistoryRepository{
+		db:      db,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+	}
+}
+
+func (r *UserHistoryRepository) Insert(ctx context.Context, entry models.UserHistoryEntry) error {
+	before, err := userJSON(entry.Before)
+	if err != nil {
+		return fmt.Errorf("insert user history: %w", err)
+	}
+	after, err := userJSON(entry.After)
+	if err != nil {
+		return fmt.Errorf("insert user history: %w", err)
+	}
+	lsn, err := cdc.ParseLSN(entry.LSN)
+	if err != nil {
+		return fmt.Errorf("insert user history: %w", err)
+	}
+	var txID any
+	if entry.TxID != 0 {
+		txID = entry.TxID
+	}
+	changedFields := entry.ChangedFields
+	if changedFields == nil {
+		changedFields = []string{}
+	}
+	sql, args, err := r.builder.Insert("user_history").
+		Columns("user_id", "op", "before", "after", "changed_fields", "lsn", "tx_id", "committed_at").
+		Values(entry.UserID, entry.Op, before, after, changedFields, int64(lsn), txID, entry.CommittedAt).
+		Suffix("ON CONFLICT (user_id, lsn) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("insert user history: %w", err)
+	}
+	if _, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("insert user history, exec: %w", err)
+	}
+	return nil
+}
+
+// InsertTruncate records a delete at lsn for every user that existed before it, so a
+// truncate is visible to point-in-time reads. It returns how many users were live.
+func (r *UserHistoryRepository) InsertTruncate(ctx context.Context, lsn cdc.LSN, txID int64, committedAt time.Time) (int64, error) {
+	var tx any
+	if txID != 0 {
+		tx = txID
+	}
+	latest := r.builder.Select("user_id", "op", "after").
+		Options("DISTINCT ON (user_id)").
+		From("user_history").
+		Where(squirrel.Lt{"lsn": int64(lsn)}).
+		OrderBy("user_id", "lsn DESC", "id DESC")
+	live := r.builder.Select("user_id").
+		Column(squirrel.Expr("?::varchar", string(cdc.OpDelete))).
+		Columns("after", "NULL::jsonb", "ARRAY(SELECT jsonb_object_keys(after) ORDER BY 1)").
+		Column(squirrel.Expr("?::bigint", int64(lsn))).
+		Column(squirrel.Expr("?::bigint", tx)).
+		Column(squirrel.Expr("?::timestamptz", committedAt)).
+		FromSelect(latest, "latest").
+		Where(squirrel.NotEq{"op": string(cdc.OpDelete)})
+	sql, args, err := r.builder.Insert("user_history").
+		Columns("user_id", "op", "before", "after", "changed_fields", "lsn", "tx_id", "committed_at").
+		Select(live).
+		Suffix("ON CONFLICT (user_id, lsn) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("insert user history truncate: %w", err)
+	}
+	tag, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("insert user history truncate, exec: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Select returns the history of a user, newest first.
+func (r *UserHistoryRepository) Select(ctx context.Context, filter models.UserHistoryFilter) ([]models.UserHistoryEntry, error) {
+	sql, args, err := r.builder.Select(userHistoryColumns...).
+		From("user_history").
+		Where(squirrel.Eq{"user_id": filter.UserID}).
+		OrderBy("committed_at DESC", "lsn DESC", "id DESC").
+		Offset(uint64(filter.Offset)).
+		Limit(uint64(filter.Limit)).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("select user history: %w", err)
+	}
+	return r.query(ctx, "select user history", sql, args)
+}
+
+// Latest returns the newest entry of a user, ok is false when it has none.
+func (r *UserHistoryRepository) Latest(ctx context.Context, userID int64) (models.UserHistoryEntry, bool, error) {
+	sql, args, err := r.builder.Select(userHistoryColumns...).
+		From("user_history").
+		Where(squirrel.Eq{"user_id": userID}).
+		OrderBy("committed_at DESC", "lsn DESC", "id DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return models.UserHistoryEntry{}, false, fmt.Errorf("latest user history: %w", err)
+	}
+	entry, err := scanUserHistory(postgres.Conn(ctx, r.db).QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.UserHistoryEntry{}, false, nil
+	}
+	if err != nil {
+		return models.UserHistoryEntry{}, false, fmt.Errorf("latest user history, Scan: %w", err)
+	}
+	return entry, true, nil
+}
+
+// StateAt returns the newest entry of a user committed at or before t.
+func (r *UserHistoryRepository) StateAt(ctx context.Context, userID int64, t time.Time) (models.UserHistoryEntry, bool, error) {
+	sql, args, err := r.builder.Select(userHistoryColumns...).
+		From("user_history").
+		Where(squirrel.Eq{"user_id": userID}).
+		Where(squirrel.LtOrEq{"committed_at": t}).
+		OrderBy("committed_at DESC", "lsn DESC", "id DESC").
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return models.UserHistoryEntry{}, false, fmt.Errorf("user state at: %w", err)
+	}
+	entry, err := scanUserHistory(postgres.Conn(ctx, r.db).QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.UserHistoryEntry{}, false, nil
+	}
+	if err != nil {
+		return models.UserHistoryEntry{}, false, fmt.Errorf("user state at, Scan: %w", err)
+	}
+	return entry, true, nil
+}
+
+// StatesAt returns the newest entry committed at or before t of every user that existed at t, by user id.
+func (r *UserHistoryRepository) StatesAt(ctx context.Context, t time.Time, offset, limit int) ([]models.UserHistoryEntry, error) {
+	latest := r.builder.Select(userHistoryColumns...).
+		Options("DISTINCT ON (user_id)").
+		From("user_history").
+		Where(squirrel.LtOrEq{"committed_at": t}).
+		OrderBy("user_id", "committed_at DESC", "lsn DESC", "id DESC")
+	sql, args, err := r.builder.Select(userHistoryColumns...).
+		FromSelect(latest, "latest").
+		Where(squirrel.NotEq{"op": string(cdc.OpDelete)}).
+		OrderBy("user_id").
+		Offset(uint64(offset)).
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("user states at: %w", err)
+	}
+	return r.query(ctx, "user states at", sql, args)
+}
+
+func (r *UserHistoryRepository) query(ctx context.Context, op, sql string, args []any) ([]models.UserHistoryEntry, error) {
+	rows, err := postgres.Conn(ctx, r.db).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s, query: %w", op, err)
+	}
+	defer rows.Close()
+
+	var entries []models.UserHistoryEntry
+	for rows.Next() {
+		entry, err := scanUserHistory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s, Scan: %w", op, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s, rows: %w", op, err)
+	}
+	return entries, nil
+}
+
+func scanUserHistory(row pgx.Row) (models.UserHistoryEntry, error) {
+	var entry models.UserHistoryEntry
+	var before, after []byte
+	var lsn int64
+	var txID *int64
+	if err := row.Scan(&entry.ID, &entry.UserID, &entry.Op, &before, &after, &entry.ChangedFields,
+		&lsn, &txID, &entry.CommittedAt, &entry.RecordedAt); err != nil {
+		return models.UserHistoryEntry{}, err
+	}
+	entry.LSN = cdc.LSN(lsn).String()
+	if txID != nil {
+		entry.TxID = *txID
+	}
+	var err error
+	if entry.Before, err = parseUserJSON(before); err != nil {
+		return models.UserHistoryEntry{}, err
+	}
+	if entry.After, err = parseUserJSON(after); err != nil {
+		return models.UserHistoryEntry{}, err
+	}
+	return entry, nil
+}
+
+func userJSON(user *models.User) (any, error) {
+	if user == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func parseUserJSON(data []byte) (*models.User, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var user models.User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,346 @@
+package repository
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/postgres"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var webhookSubscriptionColumns = []string{"id", "url", "secret", "filter", "enabled", "consecutive_failures", "disabled_reason", "created_at", "updated_at"}
+
+type WebhookRepository struct {
+	db      *pgxpool.Pool
+	builder squirrel.StatementBuilderType
+}
+
+func NewWebhookRepository(db *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{
+		db:      db,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+	}
+}
+
+func (r *WebhookRepository) InsertSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	filter, err := json.Marshal(sub.Filter)
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("insert webhook subscription: %w", err)
+	}
+	sql, args, err := r.builder.Insert("webhook_subscriptions").
+		Columns("url", "secret", "filter", "enabled").
+		Values(sub.URL, sub.Secret, string(filter), sub.Enabled).
+		Suffix("RETURNING " + strings.Join(webhookSubscriptionColumns, ", ")).
+		ToSql()
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("insert webhook subscription: %w", err)
+	}
+	created, err := scanWebhookSubscription(postgres.Conn(ctx, r.db).QueryRow(ctx, sql, args...))
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("insert webhook subscription, Scan: %w", err)
+	}
+	return created, nil
+}
+
+// SelectSubscriptions returns subscriptions by id, only the enabled ones when enabledOnly is set.
+func (r *WebhookRepository) SelectSubscriptions(ctx context.Context, enabledOnly bool) ([]models.WebhookSubscription, error) {
+	query := r.builder.Select(webhookSubscriptionColumns...).
+		From("webhook_subscriptions").
+		OrderBy("id")
+	if enabledOnly {
+		query = query.Where(squirrel.Eq{"enabled": true})
+	}
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("select webhook subscriptions: %w", err)
+	}
+	rows, err := postgres.Conn(ctx, r.db).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select webhook subscriptions, query: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("select webhook subscriptions, Scan: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select webhook subscriptions, rows: %w", err)
+	}
+	return subs, nil
+}
+
+func (r *WebhookRepository) SelectSubscription(ctx context.Context, id int64) (models.WebhookSubscription, error) {
+	sql, args, err := r.builder.Select(webhookSubscriptionColumns...).
+		From("webhook_subscriptions").
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("select webhook subscription: %w", err)
+	}
+	sub, err := scanWebhookSubscription(postgres.Conn(ctx, r.db).QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.WebhookSubscription{}, models.ErrWebhookNotFound
+	}
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("select webhook subscription, Scan: %w", err)
+	}
+	return sub, nil
+}
+
+// UpdateSubscription replaces url, secret, filter and enabled. Enabling resets the failure count.
+func (r *WebhookRepository) UpdateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	filter, err := json.Marshal(sub.Filter)
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("update webhook subscription: %w", err)
+	}
+	query := r.builder.Update("webhook_subscriptions").
+		Set("url", sub.URL).
+		Set("secret", sub.Secret).
+		Set("filter", string(filter)).
+		Set("enabled", sub.Enabled).
+		Set("updated_at", squirrel.Expr("NOW()")).
+		Where(squirrel.Eq{"id": sub.ID}).
+		Suffix("RETURNING " + strings.Join(webhookSubscriptionColumns, ", "))
+	if sub.Enabled {
+		query = query.Set("consecutive_failures", 0).Set("disabled_reason", "")
+	}
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("update webhook subscription: %w", err)
+	}
+	updated, err := scanWebhookSubscription(postgres.Conn(ctx, r.db).QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.WebhookSubscription{}, models.ErrWebhookNotFound
+	}
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("update webhook subscription, Scan: %w", err)
+	}
+	return updated, nil
+}
+
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	sql, args, err := r.builder.Delete("webhook_subscriptions").
+		Where(squirrel.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	tag, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("delete webhook subscription, exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepository) InsertDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	sql, args, err := r.builder.Insert("webhook_deliveries").
+		Columns("subscription_id", "event_id", "payload").
+		Values(delivery.SubscriptionID, delivery.EventID, string(delivery.Payload)).
+		Suffix("ON CONFLICT (subscription_id, event_id) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("insert webhook delivery: %w", err)
+	}
+	if _, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("insert webhook delivery, exec: %w", err)
+	}
+	return nil
+}
+
+// ClaimDueDeliveries claims the oldest undelivered delivery of every enabled subscription
+// when it is due, by moving its next attempt to leaseUntil. Later deliveries wait for it,
+// which keeps every subscription in order, and other instances skip it until the lease ends:
+// a claimed delivery is no longer due when a concurrent claim rechecks it.
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	// Subqueries keep ? placeholders, the statement numbers them all.
+	heads := squirrel.Select("d.id", "d.next_attempt_at").
+		Options("DISTINCT ON (d.subscription_id)").
+		From("webhook_deliveries d").
+		Join("webhook_subscriptions s ON s.id = d.subscription_id").
+		Where(squirrel.Eq{"d.delivered": false, "s.enabled": true}).
+		OrderBy("d.subscription_id", "d.id")
+	due := squirrel.Select("id").
+		FromSelect(heads, "heads").
+		Where(squirrel.LtOrEq{"next_attempt_at": now}).
+		OrderBy("next_attempt_at").
+		Limit(uint64(limit))
+	sql, args, err := r.builder.Update("webhook_deliveries").
+		Set("next_attempt_at", leaseUntil).
+		Where(squirrel.Expr("id IN (?)", due)).
+		Where(squirrel.LtOrEq{"next_attempt_at": now}).
+		Where(squirrel.Eq{"delivered": false}).
+		Suffix("RETURNING id, subscription_id, event_id, payload, attempts, next_attempt_at").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("claim due webhook deliveries: %w", err)
+	}
+	rows, err := postgres.Conn(ctx, r.db).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("claim due webhook deliveries, query: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.Payload, &d.Attempts, &d.NextAttemptAt); err != nil {
+			return nil, fmt.Errorf("claim due webhook deliveries, Scan: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claim due webhook deliveries, rows: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RecordSuccess records the attempt, marks the delivery done and resets the subscription's failure count.
+func (r *WebhookRepository) RecordSuccess(ctx context.Context, attempt models.WebhookAttempt) error {
+	return postgres.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		if err := r.insertAttempt(ctx, attempt); err != nil {
+			return err
+		}
+		if err := r.exec(ctx, "mark webhook delivered", r.builder.Update("webhook_deliveries").
+			Set("delivered", true).
+			Set("attempts", squirrel.Expr("attempts + 1")).
+			Set("last_error", "").
+			Set("delivered_at", attempt.AttemptedAt).
+			Where(squirrel.Eq{"id": attempt.DeliveryID})); err != nil {
+			return err
+		}
+		return r.exec(ctx, "reset webhook failures", r.builder.Update("webhook_subscriptions").
+			Set("consecutive_failures", 0).
+			Where(squirrel.Eq{"id": attempt.SubscriptionID}))
+	})
+}
+
+// RecordFailure records the attempt, reschedules the delivery and disables the subscription
+// once it failed maxFailures times in a row.
+func (r *WebhookRepository) RecordFailure(ctx context.Context, attempt models.WebhookAttempt, nextAttemptAt time.Time, maxFailures int) error {
+	return postgres.RunInTx(ctx, r.db, func(ctx context.Context) error {
+		if err := r.insertAttempt(ctx, attempt); err != nil {
+			return err
+		}
+		if err := r.exec(ctx, "reschedule webhook delivery", r.builder.Update("webhook_deliveries").
+			Set("attempts", squirrel.Expr("attempts + 1")).
+			Set("last_error", attempt.Error).
+			Set("next_attempt_at", nextAttemptAt).
+			Where(squirrel.Eq{"id": attempt.DeliveryID})); err != nil {
+			return err
+		}
+		return r.exec(ctx, "count webhook failure", r.builder.Update("webhook_subscriptions").
+			Set("consecutive_failures", squirrel.Expr("consecutive_failures + 1")).
+			Set("enabled", squirrel.Expr("consecutive_failures + 1 < ?", maxFailures)).
+			Set("disabled_reason", squirrel.Expr("CASE WHEN consecutive_failures + 1 < ? THEN disabled_reason ELSE ? END",
+				maxFailures, fmt.Sprintf("disabled after %d consecutive failures, last: %s", maxFailures, attempt.Error))).
+			Set("updated_at", squirrel.Expr("NOW()")).
+			Where(squirrel.Eq{"id": attempt.SubscriptionID}))
+	})
+}
+
+func (r *WebhookRepository) SelectAttempts(ctx context.Context, subscriptionID int64, offset, limit int) ([]models.WebhookAttempt, error) {
+	sql, args, err := r.builder.Select("id", "delivery_id", "subscription_id", "status_code", "error", "duration_ms", "attempted_at").
+		From("webhook_attempts").
+		Where(squirrel.Eq{"subscription_id": subscriptionID}).
+		OrderBy("attempted_at DESC", "id DESC").
+		Offset(uint64(offset)).
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("select webhook attempts: %w", err)
+	}
+	rows, err := postgres.Conn(ctx, r.db).Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select webhook attempts, query: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []models.WebhookAttempt
+	for rows.Next() {
+		var a models.WebhookAttempt
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.SubscriptionID, &a.StatusCode, &a.Error, &a.DurationMs, &a.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("select webhook attempts, Scan: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select webhook attempts, rows: %w", err)
+	}
+	return attempts, nil
+}
+
+// DeleteBefore prunes attempts made and deliveries delivered before t, and expires the
+// deliveries queued before t for disabled subscriptions. It returns how many rows it deleted.
+func (r *WebhookRepository) DeleteBefore(ctx context.Context, t time.Time) (int64, error) {
+	var deleted int64
+	for _, query := range []struct {
+		op    string
+		query squirrel.DeleteBuilder
+	}{
+		{"delete webhook attempts", r.builder.Delete("webhook_attempts").
+			Where(squirrel.Lt{"attempted_at": t})},
+		{"delete delivered webhooks", r.builder.Delete("webhook_deliveries").
+			Where(squirrel.Eq{"delivered": true}).
+			Where(squirrel.Lt{"delivered_at": t})},
+		{"expire paused webhook deliveries", r.builder.Delete("webhook_deliveries").
+			Where(squirrel.Eq{"delivered": false}).
+			Where(squirrel.Lt{"created_at": t}).
+			Where("subscription_id IN (SELECT id FROM webhook_subscriptions WHERE NOT enabled)")},
+	} {
+		sql, args, err := query.query.ToSql()
+		if err != nil {
+			return deleted, fmt.Errorf("%s: %w", query.op, err)
+		}
+		tag, err := r.db.Exec(ctx, sql, args...)
+		if err != nil {
+			return deleted, fmt.Errorf("%s, exec: %w", query.op, err)
+		}
+		deleted += tag.RowsAffected()
+	}
+	return deleted, nil
+}
+
+func (r *WebhookRepository) insertAttempt(ctx context.Context, attempt models.WebhookAttempt) error {
+	return r.exec(ctx, "insert webhook attempt", r.builder.Insert("webhook_attempts").
+		Columns("delivery_id", "subscription_id", "status_code", "error", "duration_ms", "attempted_at").
+		Values(attempt.DeliveryID, attempt.SubscriptionID, attempt.StatusCode, attempt.Error, attempt.DurationMs, attempt.AttemptedAt))
+}
+
+func (r *WebhookRepository) exec(ctx context.Context, op string, query squirrel.Sqlizer) error {
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := postgres.Conn(ctx, r.db).Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("%s, exec: %w", op, err)
+	}
+	return nil
+}
+
+func scanWebhookSubscription(row pgx.Row) (models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var filter []byte
+	if err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &filter, &sub.Enabled, &sub.ConsecutiveFailures,
+		&sub.DisabledReason, &sub.CreatedAt, &sub.UpdatedAt); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	if err := json.Unmarshal(filter, &sub.Filter); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	return sub, nil
+}
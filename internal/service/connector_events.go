@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"debez/internal/models"
+	debeziumclient "debez/pkg/debezium-client"
+	"debez/pkg/logger"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultConnectorEventsLimit = 100
+	maxConnectorEventsLimit     = 1000
+	defaultConnectorPoll        = 15 * time.Second
+
+	stateFailed     = "FAILED"
+	stateRunning    = "RUNNING"
+	stateRestarting = "RESTARTING"
+
+	redactedValue = "[REDACTED]"
+)
+
+// secretConfigKeys are the parts of connector config keys whose values are never stored,
+// like database.password or sasl.jaas.config.
+var secretConfigKeys = []string{"password", "secret", "token", "credential", "jaas"}
+
+type ConnectorStatusSource interface {
+	GetConnectorsStatuses(ctx context.Context) (debeziumclient.GetConnectorsStatusResponse, error)
+}
+
+type ConnectorEventRepository interface {
+	Insert(ctx context.Context, event models.ConnectorEvent) error
+	Select(ctx context.Context, filter models.ConnectorEventFilter) ([]models.ConnectorEvent, error)
+	SelectLatest(ctx context.Context) ([]models.ConnectorEvent, error)
+}
+
+type connectorSnapshot struct {
+	state    string
+	workerID string
+	config   string
+	tasks    map[int]debeziumclient.TaskInfo
+}
+
+// ConnectorEventService journals every connector change it observes while polling Kafka Connect.
+type ConnectorEventService struct {
+	Repository ConnectorEventRepository
+	source     ConnectorStatusSource
+	known      map[string]connectorSnapshot
+}
+
+func NewConnectorEventService(repo ConnectorEventRepository, source ConnectorStatusSource) *ConnectorEventService {
+	return &ConnectorEventService{
+		Repository: repo,
+		source:     source,
+	}
+}
+
+func (s *ConnectorEventService) GetEvents(ctx context.Context, filter models.ConnectorEventFilter) ([]models.ConnectorEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultConnectorEventsLimit
+	}
+	if filter.Limit > maxConnectorEventsLimit {
+		filter.Limit = maxConnectorEventsLimit
+	}
+	return s.Repository.Select(ctx, filter)
+}
+
+// Watch polls connector statuses every interval until ctx is done. The first poll is compared
+// with the journaled state, so a restart does not observe every connector again.
+func (s *ConnectorEventService) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultConnectorPoll
+	}
+	if s.known == nil {
+		if err := s.Restore(ctx); err != nil {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to restore connector states", zap.Error(err))
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Poll(ctx); err != nil && ctx.Err() == nil {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to poll connectors", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Restore sets the previous poll to the state recorded in the journal.
+func (s *ConnectorEventService) Restore(ctx context.Context) error {
+	events, err := s.Repository.SelectLatest(ctx)
+	if err != nil {
+		return err
+	}
+	s.known = restoreSnapshots(events)
+	return nil
+}
+
+// restoreSnapshots rebuilds the connector snapshots from the last events of every connector
+// and task, connectors and tasks last seen removed are left out.
+func restoreSnapshots(events []models.ConnectorEvent) map[string]connectorSnapshot {
+	latest := make(map[string]models.ConnectorEvent)
+	configs := make(map[string]json.RawMessage)
+	tasks := make(map[string]map[int]debeziumclient.TaskInfo)
+	for _, event := range events {
+		if event.TaskID != nil {
+			if event.Type == models.ConnectorRemoved {
+				continue
+			}
+			if tasks[event.Connector] == nil {
+				tasks[event.Connector] = make(map[int]debeziumclient.TaskInfo)
+			}
+			tasks[event.Connector][*event.TaskID] = debeziumclient.TaskInfo{ID: *event.TaskID, State: event.State, WorkerID: event.WorkerID}
+			continue
+		}
+		if len(event.Config) > 0 {
+			configs[event.Connector] = event.Config
+		}
+		if last, ok := latest[event.Connector]; !ok || event.ID > last.ID {
+			latest[event.Connector] = event
+		}
+	}
+
+	known := make(map[string]connectorSnapshot, len(latest))
+	for name, event := range latest {
+		if event.Type == models.ConnectorRemoved {
+			continue
+		}
+		snapshot := connectorSnapshot{
+			state:    event.State,
+			workerID: event.WorkerID,
+			config:   normalizeConfig(configs[name]),
+			tasks:    tasks[name],
+		}
+		if snapshot.tasks == nil {
+			snapshot.tasks = make(map[int]debeziumclient.TaskInfo)
+		}
+		known[name] = snapshot
+	}
+	return known
+}
+
+// normalizeConfig re-encodes a journaled config like newConnectorSnapshot does, jsonb does not
+// keep the formatting.
+func normalizeConfig(raw json.RawMessage) string {
+	var config map[string]interface{}
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return string(raw)
+	}
+	normalized, _ := json.Marshal(config)
+	return string(normalized)
+}
+
+// Poll fetches connector statuses once and records the differences with the previous poll.
+func (s *ConnectorEventService) Poll(ctx context.Context) error {
+	statuses, err := s.source.GetConnectorsStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	current := make(map[string]connectorSnapshot, len(statuses))
+	for name, connector := range statuses {
+		snapshot := newConnectorSnapshot(connector)
+		current[name] = snapshot
+		previous, ok := s.known[name]
+		for _, event := range diffConnector(name, previous, ok, snapshot, connector) {
+			event.OccurredAt = now
+			s.record(ctx, event)
+		}
+	}
+	for name, previous := range s.known {
+		if _, ok := current[name]; !ok {
+			s.record(ctx, models.ConnectorEvent{
+				Connector:     name,
+				Type:          models.ConnectorRemoved,
+				PreviousState: previous.state,
+				OccurredAt:    now,
+			})
+		}
+	}
+	s.known = current
+	return nil
+}
+
+func (s *ConnectorEventService) record(ctx context.Context, event models.ConnectorEvent) {
+	if err := s.Repository.Insert(ctx, event); err != nil {
+		logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to record connector event",
+			zap.String("connector", event.Connector), zap.String("event_type", string(event.Type)), zap.Error(err))
+	}
+}
+
+func newConnectorSnapshot(connector debeziumclient.ConnectorExpanded) connectorSnapshot {
+	// json.Marshal sorts map keys, so equal configs always produce equal strings.
+	config, _ := json.Marshal(redactConfig(connector.Info.Config))
+	snapshot := connectorSnapshot{
+		state:    connector.Status.Connector.State,
+		workerID: connector.Status.Connector.WorkerID,
+		config:   string(config),
+		tasks:    make(map[int]debeziumclient.TaskInfo, len(connector.Status.Tasks)),
+	}
+	for _, task := range connector.Status.Tasks {
+		snapshot.tasks[task.ID] = task
+	}
+	return snapshot
+}
+
+// redactConfig returns a copy of config with the values of secret keys replaced. A changed
+// secret is therefore not reported as a config change.
+func redactConfig(config map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		if isSecretConfigKey(key) {
+			value = redactedValue
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+func isSecretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range secretConfigKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func diffConnector(
+	name string,
+	previous connectorSnapshot,
+	known bool,
+	current connectorSnapshot,
+	connector debeziumclient.ConnectorExpanded,
+) []models.ConnectorEvent {
+	if !known {
+		return []models.ConnectorEvent{{
+			Connector: name,
+			Type:      models.ConnectorObserved,
+			State:     current.state,
+			WorkerID:  current.workerID,
+			Trace:     connector.Status.Connector.Trace,
+			Config:    json.RawMessage(current.config),
+		}}
+	}
+
+	var events []models.ConnectorEvent
+	if previous.state != current.state {
+		events = append(events, models.ConnectorEvent{
+			Connector:     name,
+			Type:          stateChangeType(previous.state, current.state, models.ConnectorStatusChanged),
+			State:         current.state,
+			PreviousState: previous.state,
+			WorkerID:      current.workerID,
+			Trace:         connector.Status.Connector.Trace,
+		})
+	}
+	if previous.config != current.config {
+		events = append(events, models.ConnectorEvent{
+			Connector: name,
+			Type:      models.ConnectorConfigChanged,
+			State:     current.state,
+			Config:    json.RawMessage(current.config),
+		})
+	}
+	for id, task := range current.tasks {
+		prevTask, ok := previous.tasks[id]
+		eventType := models.ConnectorTaskChanged
+		switch {
+		case !ok:
+		case prevTask.State != task.State:
+			eventType = stateChangeType(prevTask.State, task.State, eventType)
+		case prevTask.WorkerID != task.WorkerID:
+			// A task that moved to another worker has been restarted by a rebalance.
+			eventType = models.ConnectorRestarted
+		default:
+			continue
+		}
+		taskID := id
+		events = append(events, models.ConnectorEvent{
+			Connector:     name,
+			TaskID:        &taskID,
+			Type:          eventType,
+			State:         task.State,
+			PreviousState: prevTask.State,
+			WorkerID:      task.WorkerID,
+			Trace:         task.Trace,
+		})
+	}
+	for id, prevTask := range previous.tasks {
+		if _, ok := current.tasks[id]; ok {
+			continue
+		}
+		taskID := id
+		events = append(events, models.ConnectorEvent{
+			Connector:     name,
+			TaskID:        &taskID,
+			Type:          models.ConnectorRemoved,
+			PreviousState: prevTask.State,
+			WorkerID:      prevTask.WorkerID,
+		})
+	}
+	return events
+}
+
+func stateChangeType(previous, current string, fallback models.ConnectorEventType) models.ConnectorEventType {
+	switch {
+	case current == stateFailed:
+		return models.ConnectorFailed
+	case current == stateRestarting, previous == stateFailed && current == stateRunning:
+		return models.ConnectorRestarted
+	default:
+		return fallback
+	}
+}
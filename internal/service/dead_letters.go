@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/cdc"
+	"errors"
+)
+
+const (
+	defaultDeadLettersLimit = 100
+	maxDeadLettersLimit     = 1000
+)
+
+type DeadLetterRepository interface {
+	Select(ctx context.Context, filter models.DeadLetterFilter) ([]models.DeadLetter, error)
+	SelectByID(ctx context.Context, id int64) (models.DeadLetter, error)
+	Resolve(ctx context.Context, id int64, status models.DeadLetterStatus) error
+	Claim(ctx context.Context, id int64) (models.DeadLetter, error)
+	ResolveReplay(ctx context.Context, id int64) error
+	RecordFailure(ctx context.Context, id int64, cause string) error
+}
+
+// HandlerInvoker runs a single registered handler, *cdc.Dispatcher implements it.
+type HandlerInvoker interface {
+	Invoke(ctx context.Context, name string, e cdc.Event) error
+	InvokeTx(ctx context.Context, name string, tx cdc.Tx) error
+}
+
+// DeadLetterService inspects, replays and discards events handlers failed on.
+type DeadLetterService struct {
+	Repository DeadLetterRepository
+	invoker    HandlerInvoker
+}
+
+func NewDeadLetterService(repo DeadLetterRepository, invoker HandlerInvoker) *DeadLetterService {
+	return &DeadLetterService{
+		Repository: repo,
+		invoker:    invoker,
+	}
+}
+
+func (s *DeadLetterService) GetDeadLetters(ctx context.Context, filter models.DeadLetterFilter) ([]models.DeadLetter, error) {
+	filter.IDs = nil
+	if filter.Limit <= 0 {
+		filter.Limit = defaultDeadLettersLimit
+	}
+	if filter.Limit > maxDeadLettersLimit {
+		filter.Limit = maxDeadLettersLimit
+	}
+	return s.Repository.Select(ctx, filter)
+}
+
+func (s *DeadLetterService) GetDeadLetter(ctx context.Context, id int64) (models.DeadLetter, error) {
+	return s.Repository.SelectByID(ctx, id)
+}
+
+// Replay hands the event to the handler that failed on it again, through the full middleware
+// chain, a failed transaction is replayed whole. The entry is claimed first, so concurrent
+// replays run the handler once and the others get ErrDeadLetterResolved. A failed replay
+// makes the entry pending again and returns the handler's error.
+func (s *DeadLetterService) Replay(ctx context.Context, id int64) error {
+	letter, err := s.Repository.Claim(ctx, id)
+	if err != nil {
+		return err
+	}
+	// The outcome is recorded even when the client went away, or the claim would linger.
+	bookkeeping := context.WithoutCancel(ctx)
+	if err := s.invoke(cdc.WithReplay(ctx), letter); err != nil {
+		var retryErr *cdc.RetryError
+		cause := err
+		if errors.As(err, &retryErr) {
+			cause = retryErr.Err
+		}
+		if recordErr := s.Repository.RecordFailure(bookkeeping, letter.ID, cause.Error()); recordErr != nil {
+			return errors.Join(err, recordErr)
+		}
+		return err
+	}
+	return s.Repository.ResolveReplay(bookkeeping, letter.ID)
+}
+
+func (s *DeadLetterService) invoke(ctx context.Context, letter models.DeadLetter) error {
+	if letter.Tx != nil {
+		return s.invoker.InvokeTx(ctx, letter.Handler, *letter.Tx)
+	}
+	return s.invoker.Invoke(ctx, letter.Handler, letter.Event)
+}
+
+func (s *DeadLetterService) Discard(ctx context.Context, id int64) error {
+	return s.Repository.Resolve(ctx, id, models.DeadLetterDiscarded)
+}
+
+// ReplayAll replays the pending entries selected by filter in id order.
+func (s *DeadLetterService) ReplayAll(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterResult, error) {
+	return s.bulk(ctx, filter, func(ctx context.Context, letter models.DeadLetter) error {
+		return s.Replay(ctx, letter.ID)
+	})
+}
+
+func (s *DeadLetterService) DiscardAll(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterResult, error) {
+	return s.bulk(ctx, filter, func(ctx context.Context, letter models.DeadLetter) error {
+		return s.Repository.Resolve(ctx, letter.ID, models.DeadLetterDiscarded)
+	})
+}
+
+func (s *DeadLetterService) bulk(ctx context.Context, filter models.DeadLetterFilter, fn func(ctx context.Context, letter models.DeadLetter) error) (models.DeadLetterResult, error) {
+	filter.Status = models.DeadLetterPending
+	filter.Offset = 0
+	if filter.Limit <= 0 || filter.Limit > maxDeadLettersLimit {
+		filter.Limit = maxDeadLettersLimit
+	}
+	letters, err := s.Repository.Select(ctx, filter)
+	if err != nil {
+		return models.DeadLetterResult{}, err
+	}
+	result := models.DeadLetterResult{Succeeded: []int64{}}
+	for _, letter := range letters {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if err := fn(ctx, letter); err != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[int64]string)
+			}
+			result.Failed[letter.ID] = err.Error()
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, letter.ID)
+	}
+	return result, nil
+}
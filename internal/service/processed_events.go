@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"debez/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const processedEventsPruneInterval = time.Hour
+
+type ProcessedEventDeleter interface {
+	DeleteBefore(ctx context.Context, t time.Time) (int64, error)
+}
+
+// ProcessedEventPruner deletes dedup markers older than the retention, so the table only
+// covers events a source may still redeliver.
+type ProcessedEventPruner struct {
+	repository ProcessedEventDeleter
+	retention  time.Duration
+}
+
+func NewProcessedEventPruner(repo ProcessedEventDeleter, retention time.Duration) *ProcessedEventPruner {
+	return &ProcessedEventPruner{
+		repository: repo,
+		retention:  retention,
+	}
+}
+
+// Run prunes once an hour until ctx is done. A retention of 0 keeps the markers forever.
+func (p *ProcessedEventPruner) Run(ctx context.Context) {
+	if p.retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(processedEventsPruneInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := p.repository.DeleteBefore(ctx, time.Now().Add(-p.retention))
+		if err != nil && ctx.Err() == nil {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to prune processed events", zap.Error(err))
+		} else if deleted > 0 {
+			logger.GetLoggerFromCtx(ctx).Debug(ctx, "pruned processed events", zap.Int64("deleted", deleted))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/cdc"
+	"debez/pkg/logger"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxReplayJobs is how many replays are kept for their status, finished ones are forgotten first.
+const maxReplayJobs = 100
+
+// ReplayTargets selects the handlers a replay feeds, *cdc.Dispatcher implements it.
+type ReplayTargets interface {
+	Select(names ...string) (*cdc.Dispatcher, error)
+}
+
+// ReplayService replays recordings made with cdc.Record in the background, one at a time,
+// to the handlers the caller picks.
+type ReplayService struct {
+	targets  ReplayTargets
+	defaults []string
+
+	mu     sync.Mutex
+	nextID int64
+	jobs   []*replayJob
+}
+
+type replayJob struct {
+	job      models.ReplayJob
+	replayed atomic.Int64
+	cancel   context.CancelFunc
+}
+
+// NewReplayService returns a service replaying to defaults when a replay names no handlers.
+// They should be handlers without effects outside the service, like the change streams.
+func NewReplayService(targets ReplayTargets, defaults []string) *ReplayService {
+	return &ReplayService{
+		targets:  targets,
+		defaults: defaults,
+	}
+}
+
+// Start replays recording to the named handlers until it ends or ctx is done, and closes it.
+// Speed scales the pauses between the events, see cdc.Replay.
+func (s *ReplayService) Start(ctx context.Context, recording io.ReadCloser, handlers []string, speed float64) (models.ReplayJob, error) {
+	if len(handlers) == 0 {
+		handlers = s.defaults
+	}
+	target, err := s.targets.Select(handlers...)
+	if err != nil {
+		recording.Close()
+		return models.ReplayJob{}, fmt.Errorf("%w: %w", models.ErrReplayHandler, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.job.Status == models.ReplayRunning {
+			recording.Close()
+			return models.ReplayJob{}, models.ErrReplayRunning
+		}
+	}
+	s.nextID++
+	ctx, cancel := context.WithCancel(ctx)
+	j := &replayJob{
+		job: models.ReplayJob{
+			ID:        s.nextID,
+			Status:    models.ReplayRunning,
+			Handlers:  slices.Clone(handlers),
+			Speed:     speed,
+			StartedAt: time.Now().UTC(),
+		},
+		cancel: cancel,
+	}
+	s.jobs = append(s.jobs, j)
+	if len(s.jobs) > maxReplayJobs {
+		// Only the last job can be running, the others are finished.
+		s.jobs = slices.Clone(s.jobs[len(s.jobs)-maxReplayJobs:])
+	}
+	go s.run(ctx, j, target, recording, speed)
+	return j.snapshot(), nil
+}
+
+func (s *ReplayService) run(ctx context.Context, j *replayJob, target *cdc.Dispatcher, recording io.ReadCloser, speed float64) {
+	defer j.cancel()
+	defer recording.Close()
+	replayed, err := cdc.Replay(ctx, recording, &countingHandler{Dispatcher: target, count: &j.replayed}, speed)
+	if err == nil {
+		// A canceled read may look like the end of the recording.
+		err = ctx.Err()
+	}
+	if err == nil {
+		// The recording may end inside a transaction, whatever is buffered goes out as is.
+		err = target.FlushTransactions(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	finished := time.Now().UTC()
+	j.job.FinishedAt = &finished
+	j.job.Replayed = replayed
+	j.job.Status = models.ReplayDone
+	if err != nil {
+		j.job.Status = models.ReplayFailed
+		if errors.Is(err, context.Canceled) {
+			j.job.Status = models.ReplayCanceled
+		}
+		j.job.Error = err.Error()
+		logger.GetLoggerFromCtx(ctx).Info(ctx, "change event replay failed",
+			zap.Int64("replay", j.job.ID), zap.Int("replayed", replayed), zap.Error(err))
+	}
+}
+
+func (s *ReplayService) GetReplay(id int64) (models.ReplayJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.find(id)
+	if j == nil {
+		return models.ReplayJob{}, models.ErrReplayNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// CancelReplay stops a running replay, it is marked canceled once the current event is handled.
+func (s *ReplayService) CancelReplay(id int64) (models.ReplayJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.find(id)
+	if j == nil {
+		return models.ReplayJob{}, models.ErrReplayNotFound
+	}
+	j.cancel()
+	return j.snapshot(), nil
+}
+
+func (s *ReplayService) find(id int64) *replayJob {
+	for _, j := range s.jobs {
+		if j.job.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// snapshot returns the job with its progress, the caller holds the service's lock.
+func (j *replayJob) snapshot() models.ReplayJob {
+	job := j.job
+	if job.Status == models.ReplayRunning {
+		job.Replayed = int(j.replayed.Load())
+	}
+	return job
+}
+
+// countingHandler counts the recording lines a replay handed to the dispatcher.
+type countingHandler struct {
+	*cdc.Dispatcher
+	count *atomic.Int64
+}
+
+func (h *countingHandler) Handle(ctx context.Context, e cdc.Event) error {
+	return h.counted(h.Dispatcher.Handle(ctx, e))
+}
+
+func (h *countingHandler) HandleTransaction(ctx context.Context, tx cdc.TransactionEvent) error {
+	return h.counted(h.Dispatcher.HandleTransaction(ctx, tx))
+}
+
+func (h *countingHandler) counted(err error) error {
+	if err == nil {
+		h.count.Add(1)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/cdc"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRecording = `{"seq":1,"event":{"op":"c","source":{"schema":"public","table":"users"}}}
+{"seq":2,"event":{"op":"u","source":{"schema":"public","table":"users"}}}
+`
+
+func countingDispatcher() (*cdc.Dispatcher, map[string]*atomic.Int64) {
+	d := cdc.NewDispatcher()
+	counts := map[string]*atomic.Int64{"stream": {}, "webhooks": {}}
+	for name, count := range counts {
+		d.RegisterFunc(name, cdc.Route{}, func(context.Context, cdc.Event) error {
+			count.Add(1)
+			return nil
+		})
+	}
+	return d, counts
+}
+
+func waitForReplay(t *testing.T, s *ReplayService, id int64) models.ReplayJob {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		job, err := s.GetReplay(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != models.ReplayRunning || time.Now().After(deadline) {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReplayServiceReplaysToSelectedHandlers(t *testing.T) {
+	tests := []struct {
+		name     string
+		handlers []string
+		want     map[string]int64
+	}{
+		{name: "defaults", handlers: nil, want: map[string]int64{"stream": 2, "webhooks": 0}},
+		{name: "named", handlers: []string{"webhooks"}, want: map[string]int64{"stream": 0, "webhooks": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, counts := countingDispatcher()
+			s := NewReplayService(d, []string{"stream"})
+			job, err := s.Start(context.Background(), io.NopCloser(strings.NewReader(testRecording)), tt.handlers, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			job = waitForReplay(t, s, job.ID)
+			if job.Status != models.ReplayDone || job.Replayed != 2 {
+				t.Fatalf("replay = %+v, want done with 2 replayed", job)
+			}
+			for name, want := range tt.want {
+				if got := counts[name].Load(); got != want {
+					t.Fatalf("%s handled %d events, want %d", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayServiceRejectsUnknownHandler(t *testing.T) {
+	d, _ := countingDispatcher()
+	s := NewReplayService(d, []string{"stream"})
+	_, err := s.Start(context.Background(), io.NopCloser(strings.NewReader(testRecording)), []string{"missing"}, 0)
+	if !errors.Is(err, models.ErrReplayHandler) {
+		t.Fatalf("Start = %v, want %v", err, models.ErrReplayHandler)
+	}
+}
+
+func TestReplayServiceRunsOneReplayAtATime(t *testing.T) {
+	d, _ := countingDispatcher()
+	s := NewReplayService(d, []string{"stream"})
+	// A pipe without a writer keeps the first replay running until it is canceled.
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	first, err := s.Start(context.Background(), reader, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Start(context.Background(), io.NopCloser(strings.NewReader(testRecording)), nil, 0); !errors.Is(err, models.ErrReplayRunning) {
+		t.Fatalf("second Start = %v, want %v", err, models.ErrReplayRunning)
+	}
+	if _, err := s.CancelReplay(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Canceling does not interrupt a blocked read, closing the body does.
+	writer.Close()
+	if job := waitForReplay(t, s, first.ID); job.Status != models.ReplayCanceled {
+		t.Fatalf("replay = %+v, want canceled", job)
+	}
+}
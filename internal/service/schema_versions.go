@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/internal/userevents"
+	"debez/pkg/cdc"
+	"debez/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSchemaVersionsLimit = 50
+	maxSchemaVersionsLimit     = 500
+)
+
+type SchemaVersionRepository interface {
+	Select(ctx context.Context, filter models.SchemaVersionFilter) ([]cdc.TableSchema, error)
+}
+
+// SchemaVersionService serves the detected schema versions of captured tables and alerts on
+// new ones.
+type SchemaVersionService struct {
+	Repository SchemaVersionRepository
+}
+
+func NewSchemaVersionService(repo SchemaVersionRepository) *SchemaVersionService {
+	return &SchemaVersionService{
+		Repository: repo,
+	}
+}
+
+func (s *SchemaVersionService) GetSchemaVersions(ctx context.Context, filter models.SchemaVersionFilter) ([]cdc.TableSchema, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultSchemaVersionsLimit
+	}
+	if filter.Limit > maxSchemaVersionsLimit {
+		filter.Limit = maxSchemaVersionsLimit
+	}
+	return s.Repository.Select(ctx, filter)
+}
+
+// SchemaChanged logs every new version. For users it also reports the columns the event
+// decoder cannot read, so a migration that breaks it is noticed before the events fail.
+func (s *SchemaVersionService) SchemaChanged(ctx context.Context, change cdc.SchemaChange) error {
+	current := change.Current
+	fields := []zap.Field{
+		zap.String("table", current.Schema+"."+current.Table),
+		zap.Int("version", current.Version),
+		zap.String("fingerprint", current.Fingerprint),
+	}
+	if change.Previous == nil {
+		logger.GetLoggerFromCtx(ctx).Info(ctx, "captured table schema recorded", fields...)
+	} else {
+		logger.GetLoggerFromCtx(ctx).Info(ctx, "captured table schema changed", append(fields,
+			zap.Strings("added", change.Added),
+			zap.Strings("removed", change.Removed),
+			zap.Strings("changed", change.Changed),
+		)...)
+	}
+	if current.Schema == userevents.Schema && current.Table == userevents.Table {
+		if problems := userevents.CheckSchema(current); len(problems) > 0 {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "users schema is incompatible with the event decoder", append(fields, zap.Strings("problems", problems))...)
+		} else if unknown := userevents.Unknown(current); len(unknown) > 0 && len(change.Added) > 0 {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "users schema has columns the event decoder ignores", append(fields, zap.Strings("columns", unknown))...)
+		}
+	}
+	return nil
+}
@@ -3,37 +3,96 @@ package service
 import (
 	"context"
 	"debez/internal/models"
+	"encoding/json"
+	"fmt"
+	"strconv"
 )
 
 type UserRepository interface {
 	Select(ctx context.Context, offset, limit int) ([]models.User, error)
 	SelectByID(ctx context.Context, id int64) (models.User, error)
-	Insert(ctx context.Context, user models.User) error
+	Insert(ctx context.Context, user models.User) (int64, error)
 	Update(ctx context.Context, user models.User) error
 	Delete(ctx context.Context, id int64) error
 }
 
+type OutboxRepository interface {
+	Insert(ctx context.Context, event models.OutboxEvent) error
+}
+
+// Transactor runs fn in a database transaction that repositories called with fn's ctx join.
+type Transactor interface {
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
 type UserService struct {
 	Repository UserRepository
+	Outbox     OutboxRepository
+	Tx         Transactor
 }
 
-func NewUserService(repo UserRepository) *UserService {
+// NewUserService creates the service. With a nil outbox writes publish no domain events.
+func NewUserService(repo UserRepository, outbox OutboxRepository, tx Transactor) *UserService {
 	return &UserService{
 		Repository: repo,
+		Outbox:     outbox,
+		Tx:         tx,
 	}
 }
 func (s *UserService) GetUsers(ctx context.Context, offset, limit int) ([]models.User, error) {
 	return s.Repository.Select(ctx, offset, limit)
 }
 func (s *UserService) SaveUser(ctx context.Context, user models.User) error {
-	return s.Repository.Insert(ctx, user)
+	return s.inTx(ctx, func(ctx context.Context) error {
+		id, err := s.Repository.Insert(ctx, user)
+		if err != nil {
+			return err
+		}
+		user.ID = id
+		return s.publish(ctx, models.UserCreated, id, user)
+	})
 }
 func (s *UserService) GetUserByID(ctx context.Context, id int64) (models.User, error) {
 	return s.Repository.SelectByID(ctx, id)
 }
 func (s *UserService) UpdateUser(ctx context.Context, user models.User) error {
-	return s.Repository.Update(ctx, user)
+	return s.inTx(ctx, func(ctx context.Context) error {
+		if err := s.Repository.Update(ctx, user); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.UserUpdated, user.ID, user)
+	})
 }
 func (s *UserService) DeleteUser(ctx context.Context, id int64) error {
-	return s.Repository.Delete(ctx, id)
+	return s.inTx(ctx, func(ctx context.Context) error {
+		if err := s.Repository.Delete(ctx, id); err != nil {
+			return err
+		}
+		return s.publish(ctx, models.UserDeleted, id, struct {
+			ID int64 `json:"id"`
+		}{ID: id})
+	})
+}
+
+func (s *UserService) inTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.Outbox == nil || s.Tx == nil {
+		return fn(ctx)
+	}
+	return s.Tx.RunInTx(ctx, fn)
+}
+
+func (s *UserService) publish(ctx context.Context, eventType models.OutboxEventType, id int64, payload any) error {
+	if s.Outbox == nil {
+		return nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("publish %s: %w", eventType, err)
+	}
+	return s.Outbox.Insert(ctx, models.OutboxEvent{
+		AggregateType: models.AggregateUser,
+		AggregateID:   strconv.FormatInt(id, 10),
+		Type:          eventType,
+		Payload:       data,
+	})
 }
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/internal/userevents"
+	"debez/pkg/cache"
+	"debez/pkg/cdc"
+	"debez/pkg/logger"
+	"debez/pkg/postgres"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type userPage struct {
+	offset int
+	limit  int
+}
+
+// CachedUserRepository is a read-through cache in front of a UserRepository. Writes through
+// it invalidate the entries they touch once their transaction has ended. Users change
+// events fed to Handle invalidate entries too, so writes made outside this service are
+// picked up as well; without a CDC pipeline those are only refreshed by the TTL.
+type CachedUserRepository struct {
+	UserRepository
+	decoder *userevents.Decoder
+	users   *cache.LRU[int64, models.User]
+	pages   *cache.LRU[userPage, []models.User]
+	// generation changes on every invalidation. Reads started before it changed may
+	// have loaded the old row and are not cached.
+	generation atomic.Uint64
+}
+
+func NewCachedUserRepository(repo UserRepository, size, pages int, ttl time.Duration) *CachedUserRepository {
+	return &CachedUserRepository{
+		UserRepository: repo,
+		decoder:        userevents.NewDecoder(userevents.TimePrecisionAdaptive),
+		users:          cache.New[int64, models.User](size, ttl),
+		pages:          cache.New[userPage, []models.User](pages, ttl),
+	}
+}
+
+func (r *CachedUserRepository) Select(ctx context.Context, offset, limit int) ([]models.User, error) {
+	key := userPage{offset: offset, limit: limit}
+	if users, ok := r.pages.Get(key); ok {
+		return slices.Clone(users), nil
+	}
+	generation := r.generation.Load()
+	users, err := r.UserRepository.Select(ctx, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if r.generation.Load() == generation {
+		r.pages.Set(key, slices.Clone(users))
+	}
+	return users, nil
+}
+
+func (r *CachedUserRepository) SelectByID(ctx context.Context, id int64) (models.User, error) {
+	if user, ok := r.users.Get(id); ok {
+		return user, nil
+	}
+	generation := r.generation.Load()
+	user, err := r.UserRepository.SelectByID(ctx, id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if r.generation.Load() == generation {
+		r.users.Set(id, user)
+	}
+	return user, nil
+}
+
+func (r *CachedUserRepository) Insert(ctx context.Context, user models.User) (int64, error) {
+	id, err := r.UserRepository.Insert(ctx, user)
+	postgres.AfterTx(ctx, func() { r.invalidate() })
+	return id, err
+}
+
+func (r *CachedUserRepository) Update(ctx context.Context, user models.User) error {
+	err := r.UserRepository.Update(ctx, user)
+	postgres.AfterTx(ctx, func() { r.invalidate(user.ID) })
+	return err
+}
+
+func (r *CachedUserRepository) Delete(ctx context.Context, id int64) error {
+	err := r.UserRepository.Delete(ctx, id)
+	postgres.AfterTx(ctx, func() { r.invalidate(id) })
+	return err
+}
+
+// invalidate drops the given users and every cached page.
+func (r *CachedUserRepository) invalidate(ids ...int64) {
+	r.generation.Add(1)
+	r.pages.Purge()
+	for _, id := range ids {
+		r.users.Delete(id)
+	}
+}
+
+// Handle invalidates the entries a users change event affects. Any change may move rows
+// between pages, so every cached page is dropped.
+func (r *CachedUserRepository) Handle(ctx context.Context, e cdc.Event) error {
+	if e.Op == cdc.OpTruncate {
+		r.invalidate()
+		r.users.Purge()
+		return nil
+	}
+	change, err := r.decoder.Decode(e)
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Info(ctx, "user cache: undecodable change event, dropping all entries", zap.Error(err))
+		r.invalidate()
+		r.users.Purge()
+		return nil
+	}
+	var ids []int64
+	for _, user := range []*models.User{change.Before, change.After} {
+		if user != nil {
+			ids = append(ids, user.ID)
+		}
+	}
+	r.invalidate(ids...)
+	return nil
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"debez/internal/models"
+	"debez/internal/userevents"
+	"debez/pkg/cdc"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	defaultUserHistoryLimit = 50
+	maxUserHistoryLimit     = 500
+)
+
+type UserHistoryRepository interface {
+	Insert(ctx context.Context, entry models.UserHistoryEntry) error
+	Select(ctx context.Context, filter models.UserHistoryFilter) ([]models.UserHistoryEntry, error)
+	Latest(ctx context.Context, userID int64) (models.UserHistoryEntry, bool, error)
+	StateAt(ctx context.Context, userID int64, t time.Time) (models.UserHistoryEntry, bool, error)
+	StatesAt(ctx context.Context, t time.Time, offset, limit int) ([]models.UserHistoryEntry, error)
+	InsertTruncate(ctx context.Context, lsn cdc.LSN, txID int64, committedAt time.Time) (int64, error)
+}
+
+// UserHistoryService records users change events and serves the history of a user.
+type UserHistoryService struct {
+	Repository UserHistoryRepository
+	decoder    *userevents.Decoder
+}
+
+func NewUserHistoryService(repo UserHistoryRepository) *UserHistoryService {
+	return &UserHistoryService{
+		Repository: repo,
+		decoder:    userevents.NewDecoder(userevents.TimePrecisionAdaptive),
+	}
+}
+
+// Handle records a users change event. Updates without a before image, replica identity
+// DEFAULT, are compared with the previous recorded state of the user. A truncate is
+// recorded as a delete of every user that existed before it.
+func (s *UserHistoryService) Handle(ctx context.Context, e cdc.Event) error {
+	if e.Op == cdc.OpTruncate {
+		return s.truncate(ctx, e)
+	}
+	change, err := s.decoder.Decode(e)
+	if err != nil {
+		return err
+	}
+	lsn, ok := change.Source.LSN()
+	if !ok {
+		return fmt.Errorf("user history: event without lsn")
+	}
+	entry := models.UserHistoryEntry{
+		UserID: change.UserID(),
+		Op:     string(change.Op),
+		Before: change.Before,
+		After:  change.After,
+		LSN:    lsn.String(),
+	}
+	entry.TxID, _ = change.Source.TxID()
+	entry.CommittedAt = time.UnixMilli(change.Source.TsMs).UTC()
+	if change.Source.TsMs == 0 {
+		entry.CommittedAt = time.UnixMilli(change.TsMs).UTC()
+	}
+	if change.Op == cdc.OpUpdate && change.Before == nil {
+		previous, ok, err := s.Repository.Latest(ctx, entry.UserID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			entry.Before = previous.After
+		}
+	}
+	if change.Op != cdc.OpRead {
+		entry.ChangedFields, err = changedFields(entry.Before, entry.After)
+		if err != nil {
+			return fmt.Errorf("user history: %w", err)
+		}
+	}
+	return s.Repository.Insert(ctx, entry)
+}
+
+func (s *UserHistoryService) truncate(ctx context.Context, e cdc.Event) error {
+	lsn, ok := e.Source.LSN()
+	if !ok {
+		return fmt.Errorf("user history: truncate without lsn")
+	}
+	txID, _ := e.Source.TxID()
+	committedAt := time.UnixMilli(e.Source.TsMs).UTC()
+	if e.Source.TsMs == 0 {
+		committedAt = time.UnixMilli(e.TsMs).UTC()
+	}
+	_, err := s.Repository.InsertTruncate(ctx, lsn, txID, committedAt)
+	return err
+}
+
+// HandleTx records the users changes of a transaction. With the dedup middleware they are
+// written in one database transaction, otherwise a retry skips the entries already recorded.
+func (s *UserHistoryService) HandleTx(ctx context.Context, tx cdc.Tx) error {
+	for _, e := range tx.Events {
+		if err := s.Handle(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetHistory returns the changes of a user, newest first, with field-level diffs.
+func (s *UserHistoryService) GetHistory(ctx context.Context, filter models.UserHistoryFilter) ([]models.UserHistoryEntry, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultUserHistoryLimit
+	}
+	if filter.Limit > maxUserHistoryLimit {
+		filter.Limit = maxUserHistoryLimit
+	}
+	entries, err := s.Repository.Select(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Diff, err = diff(entries[i].Before, entries[i].After, entries[i].ChangedFields); err != nil {
+			return nil, fmt.Errorf("user history: %w", err)
+		}
+	}
+	return entries, nil
+}
+
+// GetUserAsOf rebuilds a user as it was at t. Only changes captured since the history
+// started, including its snapshot, are known: earlier states are reported as not found.
+func (s *UserHistoryService) GetUserAsOf(ctx context.Context, id int64, t time.Time) (models.User, error) {
+	entry, ok, err := s.Repository.StateAt(ctx, id, t)
+	if err != nil {
+		return models.User{}, err
+	}
+	if !ok || entry.After == nil {
+		return models.User{}, fmt.Errorf("user %d as of %s: %w", id, t.Format(time.RFC3339), models.ErrUserNotFound)
+	}
+	return *entry.After, nil
+}
+
+// GetUsersAsOf rebuilds the users that existed at t, ordered by id.
+func (s *UserHistoryService) GetUsersAsOf(ctx context.Context, t time.Time, offset, limit int) ([]models.User, error) {
+	entries, err := s.Repository.StatesAt(ctx, t, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]models.User, 0, len(entries))
+	for _, entry := range entries {
+		if entry.After != nil {
+			users = append(users, *entry.After)
+		}
+	}
+	return users, nil
+}
+
+func userFields(user *models.User) (map[string]json.RawMessage, error) {
+	if user == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// changedFields returns the sorted names of the fields that differ between two states.
+func changedFields(before, after *models.User) ([]string, error) {
+	old, err := userFields(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := userFields(after)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for field, value := range updated {
+		if prev, ok := old[field]; !ok || !bytes.Equal(prev, value) {
+			changed = append(changed, field)
+		}
+	}
+	for field := range old {
+		if _, ok := updated[field]; !ok {
+			changed = append(changed, field)
+		}
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+func diff(before, after *models.User, fields []string) ([]models.FieldDiff, error) {
+	old, err := userFields(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := userFields(after)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make([]models.FieldDiff, 0, len(fields))
+	for _, field := range fields {
+		d := models.FieldDiff{Field: field, Old: old[field], New: updated[field]}
+		if d.Old == nil {
+			d.Old = json.RawMessage("null")
+		}
+		if d.New == nil {
+			d.New = json.RawMessage("null")
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
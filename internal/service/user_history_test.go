@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/cdc"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+// memoryUserHistory keeps the inserted entries, Latest returns the last one of a user.
+type memoryUserHistory struct {
+	entries   []models.UserHistoryEntry
+	truncates []cdc.LSN
+}
+
+func (m *memoryUserHistory) Insert(_ context.Context, entry models.UserHistoryEntry) error {
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *memoryUserHistory) Select(context.Context, models.UserHistoryFilter) ([]models.UserHistoryEntry, error) {
+	return slices.Clone(m.entries), nil
+}
+
+func (m *memoryUserHistory) Latest(_ context.Context, userID int64) (models.UserHistoryEntry, bool, error) {
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if m.entries[i].UserID == userID {
+			return m.entries[i], true, nil
+		}
+	}
+	return models.UserHistoryEntry{}, false, nil
+}
+
+func (m *memoryUserHistory) StateAt(context.Context, int64, time.Time) (models.UserHistoryEntry, bool, error) {
+	return models.UserHistoryEntry{}, false, nil
+}
+
+func (m *memoryUserHistory) StatesAt(context.Context, time.Time, int, int) ([]models.UserHistoryEntry, error) {
+	return nil, nil
+}
+
+func (m *memoryUserHistory) InsertTruncate(_ context.Context, lsn cdc.LSN, _ int64, _ time.Time) (int64, error) {
+	m.truncates = append(m.truncates, lsn)
+	return 0, nil
+}
+
+func usersEvent(op cdc.Op, lsn int, before, after string) cdc.Event {
+	e := cdc.Event{Op: op, Source: cdc.Source{Schema: "public", Table: "users", TsMs: 1714979289000}}
+	e.Source.Set("lsn", lsn)
+	e.Source.Set("txId", 9)
+	if before != "" {
+		raw := json.RawMessage(before)
+		e.Before = &raw
+	}
+	if after != "" {
+		raw := json.RawMessage(after)
+		e.After = &raw
+	}
+	return e
+}
+
+func TestChangedFields(t *testing.T) {
+	ann := &models.User{ID: 1, Email: "ann@example.com", Name: "Ann", Role: []string{"user"}}
+	renamed := &models.User{ID: 1, Email: "ann@example.com", Name: "Anna", Role: []string{"user"}}
+	promoted := &models.User{ID: 1, Email: "ann@example.com", Name: "Ann", Role: []string{"user", "admin"}}
+	tests := []struct {
+		name          string
+		before, after *models.User
+		want          []string
+	}{
+		{name: "unchanged", before: ann, after: ann, want: nil},
+		{name: "one field", before: ann, after: renamed, want: []string{"name"}},
+		{name: "array field", before: ann, after: promoted, want: []string{"role"}},
+		{name: "insert", before: nil, after: ann, want: []string{"email", "id", "last_name", "name", "role"}},
+		{name: "delete", before: ann, after: nil, want: []string{"email", "id", "last_name", "name", "role"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := changedFields(tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("changedFields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := &models.User{ID: 1, Name: "Ann"}
+	after := &models.User{ID: 1, Name: "Anna"}
+	tests := []struct {
+		name          string
+		before, after *models.User
+		fields        []string
+		want          []models.FieldDiff
+	}{
+		{
+			name:   "update",
+			before: before, after: after, fields: []string{"name"},
+			want: []models.FieldDiff{{Field: "name", Old: json.RawMessage(`"Ann"`), New: json.RawMessage(`"Anna"`)}},
+		},
+		{
+			name:   "delete",
+			before: before, after: nil, fields: []string{"id"},
+			want: []models.FieldDiff{{Field: "id", Old: json.RawMessage(`1`), New: json.RawMessage(`null`)}},
+		},
+		{
+			name:   "insert",
+			before: nil, after: after, fields: []string{"name"},
+			want: []models.FieldDiff{{Field: "name", Old: json.RawMessage(`null`), New: json.RawMessage(`"Anna"`)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diff(tt.before, tt.after, tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("diff = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Field != tt.want[i].Field || string(got[i].Old) != string(tt.want[i].Old) || string(got[i].New) != string(tt.want[i].New) {
+					t.Fatalf("diff[%d] = %s: %s -> %s, want %s: %s -> %s", i,
+						got[i].Field, got[i].Old, got[i].New, tt.want[i].Field, tt.want[i].Old, tt.want[i].New)
+				}
+			}
+		})
+	}
+}
+
+func TestUserHistoryHandle(t *testing.T) {
+	const (
+		ann   = `{"id":1,"email":"ann@example.com","name":"Ann","last_name":"Lee","role":["user"]}`
+		anna  = `{"id":1,"email":"ann@example.com","name":"Anna","last_name":"Lee","role":["user"]}`
+		other = `{"id":2,"email":"bob@example.com","name":"Bob","last_name":"Ray","role":["user"]}`
+	)
+	tests := []struct {
+		name        string
+		previous    []cdc.Event
+		event       cdc.Event
+		wantChanged []string
+		wantBefore  bool
+	}{
+		{name: "snapshot read", event: usersEvent(cdc.OpRead, 10, "", ann), wantChanged: nil},
+		{name: "insert", event: usersEvent(cdc.OpCreate, 10, "", ann), wantChanged: []string{"email", "id", "last_name", "name", "role"}},
+		{
+			name:        "update with before image",
+			event:       usersEvent(cdc.OpUpdate, 20, ann, anna),
+			wantChanged: []string{"name"},
+			wantBefore:  true,
+		},
+		{
+			name:        "update without before image",
+			previous:    []cdc.Event{usersEvent(cdc.OpCreate, 10, "", ann), usersEvent(cdc.OpCreate, 11, "", other)},
+			event:       usersEvent(cdc.OpUpdate, 20, "", anna),
+			wantChanged: []string{"name"},
+			wantBefore:  true,
+		},
+		{
+			name:        "update of an unknown user",
+			event:       usersEvent(cdc.OpUpdate, 20, "", anna),
+			wantChanged: []string{"email", "id", "last_name", "name", "role"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memoryUserHistory{}
+			s := NewUserHistoryService(repo)
+			ctx := context.Background()
+			for _, e := range append(tt.previous, tt.event) {
+				if err := s.Handle(ctx, e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			entry := repo.entries[len(repo.entries)-1]
+			if !slices.Equal(entry.ChangedFields, tt.wantChanged) {
+				t.Fatalf("changed fields = %v, want %v", entry.ChangedFields, tt.wantChanged)
+			}
+			if (entry.Before != nil) != tt.wantBefore {
+				t.Fatalf("before = %+v, want set %v", entry.Before, tt.wantBefore)
+			}
+			if entry.UserID != 1 || entry.TxID != 9 || !entry.CommittedAt.Equal(time.UnixMilli(1714979289000)) {
+				t.Fatalf("entry = %+v", entry)
+			}
+		})
+	}
+}
+
+func TestUserHistoryHandleTruncate(t *testing.T) {
+	repo := &memoryUserHistory{}
+	s := NewUserHistoryService(repo)
+	if err := s.Handle(context.Background(), usersEvent(cdc.OpTruncate, 30, "", "")); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(repo.truncates, []cdc.LSN{30}) || len(repo.entries) != 0 {
+		t.Fatalf("truncates = %v, entries = %v, want one truncate at 30", repo.truncates, repo.entries)
+	}
+
+	withoutLSN := cdc.Event{Op: cdc.OpTruncate, Source: cdc.Source{Schema: "public", Table: "users"}}
+	if err := s.Handle(context.Background(), withoutLSN); err == nil {
+		t.Fatal("truncate without lsn was recorded")
+	}
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookAddress fails deliveries to addresses that are not public.
+var ErrWebhookAddress = errors.New("webhook address is not public")
+
+// sharedAddressSpace is the carrier-grade NAT range, private but not in netip's IsPrivate.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// newWebhookClient returns the client deliveries are sent with. Unless allowPrivate is set it
+// only connects to public addresses: the check runs on the address actually dialed, after DNS
+// resolution and redirects, so subscription URLs cannot reach the service's own network.
+func newWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = denyPrivateAddress
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed instead of the subscriber, and is usually internal.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func denyPrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddress, addr)
+	}
+	return nil
+}
+
+// isPublicAddr reports whether addr is a global unicast address outside the private ranges:
+// not loopback, link-local like 169.254.169.254, private or shared.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
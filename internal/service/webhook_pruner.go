@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"debez/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const webhookPruneInterval = time.Hour
+
+type WebhookDeleter interface {
+	DeleteBefore(ctx context.Context, t time.Time) (int64, error)
+}
+
+// WebhookPruner deletes delivered deliveries and attempts older than the retention, and the
+// deliveries queued that long for a disabled subscription: a subscription paused longer than
+// the retention misses those changes when it is enabled again.
+type WebhookPruner struct {
+	repository WebhookDeleter
+	retention  time.Duration
+}
+
+func NewWebhookPruner(repo WebhookDeleter, retention time.Duration) *WebhookPruner {
+	return &WebhookPruner{
+		repository: repo,
+		retention:  retention,
+	}
+}
+
+// Run prunes once an hour until ctx is done. A retention of 0 keeps everything.
+func (p *WebhookPruner) Run(ctx context.Context) {
+	if p.retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(webhookPruneInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := p.repository.DeleteBefore(ctx, time.Now().Add(-p.retention))
+		if err != nil && ctx.Err() == nil {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to prune webhooks", zap.Error(err))
+		} else if deleted > 0 {
+			logger.GetLoggerFromCtx(ctx).Debug(ctx, "pruned webhooks", zap.Int64("deleted", deleted))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
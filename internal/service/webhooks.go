@@ -0,0 +1,353 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"debez/internal/models"
+	"debez/internal/userevents"
+	"debez/pkg/cdc"
+	"debez/pkg/logger"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIDHeader        = "X-Webhook-Id"
+
+	defaultWebhookAttemptsLimit = 50
+	maxWebhookAttemptsLimit     = 500
+
+	webhookSecretBytes    = 32
+	webhookMaxResponse    = 64 << 10
+	defaultWebhookTimeout = 10 * time.Second
+	defaultWebhookPoll    = time.Second
+	// webhookLeaseMargin is added to the timeout for the lease on a claimed delivery.
+	webhookLeaseMargin = time.Minute
+)
+
+type WebhookRepository interface {
+	InsertSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	SelectSubscriptions(ctx context.Context, enabledOnly bool) ([]models.WebhookSubscription, error)
+	SelectSubscription(ctx context.Context, id int64) (models.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	InsertDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	ClaimDueDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error)
+	RecordSuccess(ctx context.Context, attempt models.WebhookAttempt) error
+	RecordFailure(ctx context.Context, attempt models.WebhookAttempt, nextAttemptAt time.Time, maxFailures int) error
+	SelectAttempts(ctx context.Context, subscriptionID int64, offset, limit int) ([]models.WebhookAttempt, error)
+}
+
+type WebhookOptions struct {
+	Timeout      time.Duration
+	PollInterval time.Duration
+	// Backoff is the delay after the first failed attempt, it doubles up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MaxFailures consecutive failures disable a subscription.
+	MaxFailures int
+	// Concurrency bounds how many subscriptions are delivered to at once.
+	Concurrency int
+	// AllowPrivateNetworks lets deliveries reach loopback, link-local and private addresses.
+	AllowPrivateNetworks bool
+}
+
+// WebhookService manages webhook subscriptions and delivers user change events to them.
+// Handle queues a delivery per matching subscription, in the transaction of the event when
+// it runs behind cdc.Idempotent, and Run sends them. A subscription only ever has its oldest
+// pending delivery in flight, so subscribers see changes in order. Deliveries are claimed
+// before they are sent, several instances can run the delivery.
+type WebhookService struct {
+	Repository WebhookRepository
+	opts       WebhookOptions
+	client     *http.Client
+	decoder    *userevents.Decoder
+}
+
+func NewWebhookService(repo WebhookRepository, opts WebhookOptions) *WebhookService {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultWebhookTimeout
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultWebhookPoll
+	}
+	return &WebhookService{
+		Repository: repo,
+		opts:       opts,
+		client:     newWebhookClient(opts.Timeout, opts.AllowPrivateNetworks),
+		decoder:    userevents.NewDecoder(userevents.TimePrecisionAdaptive),
+	}
+}
+
+// CreateSubscription stores sub and returns it with its secret, generated when empty.
+func (s *WebhookService) CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	if err := validateWebhook(&sub); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	if sub.Secret == "" {
+		secret := make([]byte, webhookSecretBytes)
+		if _, err := rand.Read(secret); err != nil {
+			return models.WebhookSubscription{}, fmt.Errorf("create webhook: %w", err)
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+	return s.Repository.InsertSubscription(ctx, sub)
+}
+
+func (s *WebhookService) GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	subs, err := s.Repository.SelectSubscriptions(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+func (s *WebhookService) GetSubscription(ctx context.Context, id int64) (models.WebhookSubscription, error) {
+	sub, err := s.Repository.SelectSubscription(ctx, id)
+	sub.Secret = ""
+	return sub, err
+}
+
+// UpdateSubscription replaces a subscription, an empty secret keeps the current one.
+// Enabling a subscription disabled after failures resumes delivery where it stopped, changes
+// are queued for it while it is paused, until they expire. A subscription disabled by its
+// owner gets none.
+func (s *WebhookService) UpdateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	if err := validateWebhook(&sub); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	if sub.Secret == "" {
+		current, err := s.Repository.SelectSubscription(ctx, sub.ID)
+		if err != nil {
+			return models.WebhookSubscription{}, err
+		}
+		sub.Secret = current.Secret
+	}
+	updated, err := s.Repository.UpdateSubscription(ctx, sub)
+	updated.Secret = ""
+	return updated, err
+}
+
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id int64) error {
+	return s.Repository.DeleteSubscription(ctx, id)
+}
+
+func (s *WebhookService) GetAttempts(ctx context.Context, subscriptionID int64, offset, limit int) ([]models.WebhookAttempt, error) {
+	if _, err := s.Repository.SelectSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultWebhookAttemptsLimit
+	}
+	return s.Repository.SelectAttempts(ctx, subscriptionID, offset, min(limit, maxWebhookAttemptsLimit))
+}
+
+func validateWebhook(sub *models.WebhookSubscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http(s) URL", models.ErrInvalidWebhook)
+	}
+	if (sub.Filter.Schema != "" && sub.Filter.Schema != userevents.Schema) || (sub.Filter.Table != "" && sub.Filter.Table != userevents.Table) {
+		return fmt.Errorf("%w: only %s.%s can be subscribed to", models.ErrInvalidWebhook, userevents.Schema, userevents.Table)
+	}
+	for _, op := range sub.Filter.Ops {
+		if op != cdc.OpCreate && op != cdc.OpUpdate && op != cdc.OpDelete {
+			return fmt.Errorf("%w: unsupported op %q", models.ErrInvalidWebhook, op)
+		}
+	}
+	if err := sub.Filter.Validate(); err != nil {
+		return fmt.Errorf("%w: %s", models.ErrInvalidWebhook, err)
+	}
+	return nil
+}
+
+// Handle queues a delivery of a users change event for every subscription it matches that
+// is enabled or was disabled after failures: those only pause until they are enabled again.
+func (s *WebhookService) Handle(ctx context.Context, e cdc.Event) error {
+	var eventType models.WebhookEventType
+	switch e.Op {
+	case cdc.OpCreate:
+		eventType = models.WebhookUserCreated
+	case cdc.OpUpdate:
+		eventType = models.WebhookUserUpdated
+	case cdc.OpDelete:
+		eventType = models.WebhookUserDeleted
+	default:
+		return nil
+	}
+	subs, err := s.Repository.SelectSubscriptions(ctx, false)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+	change, err := s.decoder.Decode(e)
+	if err != nil {
+		return err
+	}
+	payload := models.WebhookPayload{
+		ID:          cdc.EventID(e),
+		Type:        eventType,
+		Before:      change.Before,
+		After:       change.After,
+		CommittedAt: time.UnixMilli(change.Source.TsMs).UTC(),
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("webhook payload: %w", err)
+	}
+	for _, sub := range subs {
+		paused := !sub.Enabled && sub.DisabledReason != ""
+		if (!sub.Enabled && !paused) || !sub.Filter.Match(e) {
+			continue
+		}
+		if err := s.Repository.InsertDelivery(ctx, models.WebhookDelivery{SubscriptionID: sub.ID, EventID: payload.ID, Payload: data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run delivers due deliveries until ctx is done.
+func (s *WebhookService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		sent, err := s.DeliverDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to deliver webhooks", zap.Error(err))
+		}
+		if sent > 0 && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue claims and sends the due head delivery of every subscription once and returns
+// how many were attempted. A claim lasts longer than an attempt, a delivery claimed by an
+// instance that stopped is retried when it ends.
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	leaseUntil := now.Add(s.opts.Timeout + webhookLeaseMargin)
+	deliveries, err := s.Repository.ClaimDueDeliveries(ctx, now, leaseUntil, max(s.opts.Concurrency, 1)*4)
+	if err != nil || len(deliveries) == 0 {
+		return 0, err
+	}
+	subs, err := s.Repository.SelectSubscriptions(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	byID := make(map[int64]models.WebhookSubscription, len(subs))
+	for _, sub := range subs {
+		byID[sub.ID] = sub
+	}
+
+	sem := make(chan struct{}, max(s.opts.Concurrency, 1))
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		sub, ok := byID[d.SubscriptionID]
+		if !ok {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := s.deliver(ctx, sub, d); err != nil {
+				logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to record webhook attempt",
+					zap.Int64("subscription", sub.ID), zap.Int64("delivery", d.ID), zap.Error(err))
+			}
+		}()
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+func (s *WebhookService) deliver(ctx context.Context, sub models.WebhookSubscription, d models.WebhookDelivery) error {
+	start := time.Now()
+	status, err := s.send(ctx, sub, d)
+	attempt := models.WebhookAttempt{
+		DeliveryID:     d.ID,
+		SubscriptionID: sub.ID,
+		StatusCode:     status,
+		DurationMs:     time.Since(start).Milliseconds(),
+		AttemptedAt:    start.UTC(),
+	}
+	if err == nil {
+		return s.Repository.RecordSuccess(ctx, attempt)
+	}
+	attempt.Error = err.Error()
+	return s.Repository.RecordFailure(ctx, attempt, time.Now().Add(s.backoff(d.Attempts)).UTC(), s.opts.MaxFailures)
+}
+
+// backoff is the delay after a delivery failed for the time attempts+1: Backoff doubled for
+// every earlier attempt, capped at MaxBackoff.
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	backoff := s.opts.Backoff << min(attempts, 30)
+	if backoff <= 0 || backoff > s.opts.MaxBackoff {
+		backoff = s.opts.MaxBackoff
+	}
+	return backoff
+}
+
+func (s *WebhookService) send(ctx context.Context, sub models.WebhookSubscription, d models.WebhookDelivery) (int, error) {
+	var payload models.WebhookPayload
+	if err := json.Unmarshal(d.Payload, &payload); err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIDHeader, d.EventID)
+	req.Header.Set(WebhookEventHeader, string(payload.Type))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(sub.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhookMaxResponse))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "timestamp.body" with secret, the value of
+// the signature header after "sha256=". Receivers recompute it and reject old timestamps.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"debez/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			name:   "payload",
+			secret: "secret", timestamp: "1700000000", body: `{"id":"1"}`,
+			want: "086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54",
+		},
+		{
+			name:   "other secret",
+			secret: "other", timestamp: "1700000000", body: `{"id":"1"}`,
+			want: "0c9dcd041b074d1b31727e0c1f821d11366e9db9f94c18bf202eb66cd0bd4d40",
+		},
+		{
+			name:   "other timestamp",
+			secret: "secret", timestamp: "1700000001", body: `{"id":"1"}`,
+			want: "77e81314fc8c5afb5635d42419814023d0925bedaa02744973669da9223a9ca0",
+		},
+		{
+			name:   "empty body",
+			secret: "secret", timestamp: "1700000000", body: ``,
+			want: "4bc5f74d868b97888288889c5d9d65df02526f94c1592a79fdf4fe8b26e311e5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhook(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Fatalf("SignWebhook = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	s := NewWebhookService(nil, WebhookOptions{Backoff: time.Second, MaxBackoff: time.Minute})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Second},
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 5, want: 32 * time.Second},
+		{attempts: 6, want: time.Minute},
+		{attempts: 30, want: time.Minute},
+		// Shifts that overflow are capped too.
+		{attempts: 1000, want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := s.backoff(tt.attempts); got != tt.want {
+			t.Fatalf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// attemptRecorder records the outcome of deliveries, the other repository methods are not used.
+type attemptRecorder struct {
+	WebhookRepository
+	succeeded []models.WebhookAttempt
+	failed    []models.WebhookAttempt
+	next      []time.Time
+}
+
+func (r *attemptRecorder) RecordSuccess(_ context.Context, attempt models.WebhookAttempt) error {
+	r.succeeded = append(r.succeeded, attempt)
+	return nil
+}
+
+func (r *attemptRecorder) RecordFailure(_ context.Context, attempt models.WebhookAttempt, nextAttemptAt time.Time, _ int) error {
+	r.failed = append(r.failed, attempt)
+	r.next = append(r.next, nextAttemptAt)
+	return nil
+}
+
+func TestWebhookDeliverSignsAndRecordsAttempts(t *testing.T) {
+	const payload = `{"id":"1","type":"user.updated"}`
+	status := http.StatusOK
+	var signature, timestamp string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(WebhookSignatureHeader)
+		timestamp = r.Header.Get(WebhookTimestampHeader)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	repo := &attemptRecorder{}
+	s := NewWebhookService(repo, WebhookOptions{Backoff: time.Second, MaxBackoff: time.Minute, AllowPrivateNetworks: true})
+	sub := models.WebhookSubscription{ID: 1, URL: server.URL, Secret: "secret"}
+	delivery := models.WebhookDelivery{ID: 2, SubscriptionID: 1, EventID: "1", Payload: []byte(payload), Attempts: 2}
+
+	if err := s.deliver(context.Background(), sub, delivery); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.succeeded) != 1 || repo.succeeded[0].StatusCode != http.StatusOK {
+		t.Fatalf("succeeded = %+v, want one 200 attempt", repo.succeeded)
+	}
+	if want := "sha256=" + SignWebhook("secret", timestamp, []byte(payload)); signature != want {
+		t.Fatalf("signature = %s, want %s", signature, want)
+	}
+
+	status = http.StatusServiceUnavailable
+	before := time.Now()
+	if err := s.deliver(context.Background(), sub, delivery); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.failed) != 1 || repo.failed[0].StatusCode != status || !strings.Contains(repo.failed[0].Error, "503") {
+		t.Fatalf("failed = %+v, want one 503 attempt", repo.failed)
+	}
+	if wait := repo.next[0].Sub(before); wait < 4*time.Second || wait > 5*time.Second {
+		t.Fatalf("next attempt in %v, want 4s after the third attempt", wait)
+	}
+}
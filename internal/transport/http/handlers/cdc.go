@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"debez/pkg/cdc"
+	"debez/pkg/logger"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const maxChangeEventsBody = 16 << 20
+
+// ChangeEventReceiver accepts events POSTed by the Debezium Server HTTP sink.
+// A 2xx response is only sent when every event of the request was handled, and finished by
+// a cdc.Acknowledger handler like a sink batcher, so a failed request is retried by the
+// sink and delivery stays at-least-once.
+type ChangeEventReceiver struct {
+	ctx          context.Context
+	handler      cdc.Handler
+	secretHeader string
+	secret       string
+}
+
+func NewChangeEventReceiver(ctx context.Context, handler cdc.Handler, secretHeader, secret string) *ChangeEventReceiver {
+	return &ChangeEventReceiver{
+		ctx:          ctx,
+		handler:      handler,
+		secretHeader: secretHeader,
+		secret:       secret,
+	}
+}
+
+// validSecret reports whether the request carries secret in header, any request is valid
+// without a secret.
+func validSecret(r *http.Request, header, secret string) bool {
+	if secret == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(header)), []byte(secret)) == 1
+}
+
+func (h *ChangeEventReceiver) ReceiveEvents(w http.ResponseWriter, r *http.Request) {
+	if !validSecret(r, h.secretHeader, h.secret) {
+		http.Error(w, "Invalid secret", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxChangeEventsBody))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	messages, err := splitBatch(body)
+	if err != nil {
+		http.Error(w, "Failed to unmarshal request body", http.StatusBadRequest)
+		return
+	}
+
+	events := make([]receivedEvent, 0, len(messages))
+	for _, message := range messages {
+		tx, ok, err := cdc.DecodeTransaction(message)
+		if err != nil {
+			http.Error(w, "Failed to decode change event", http.StatusBadRequest)
+			return
+		}
+		if ok {
+			events = append(events, receivedEvent{tx: &tx})
+			continue
+		}
+		event, err := cdc.Decode[json.RawMessage](message)
+		if errors.Is(err, cdc.ErrTombstone) {
+			continue
+		}
+		if err != nil {
+			http.Error(w, "Failed to decode change event", http.StatusBadRequest)
+			return
+		}
+		events = append(events, receivedEvent{event: event})
+	}
+
+	txHandler, _ := h.handler.(cdc.TransactionHandler)
+	for _, received := range events {
+		if received.tx != nil {
+			if txHandler == nil {
+				continue
+			}
+			if err := txHandler.HandleTransaction(h.ctx, *received.tx); err != nil {
+				logger.GetLoggerFromCtx(h.ctx).Info(h.ctx, "failed to handle transaction event",
+					zap.String("id", received.tx.ID), zap.String("status", string(received.tx.Status)), zap.Error(err))
+				http.Error(w, "Failed to handle change event", http.StatusInternalServerError)
+				return
+			}
+			continue
+		}
+		event := received.event
+		if err := h.handler.Handle(h.ctx, event); err != nil {
+			logger.GetLoggerFromCtx(h.ctx).Info(h.ctx, "failed to handle change event",
+				zap.String("table", event.Source.Schema+"."+event.Source.Table), zap.String("op", string(event.Op)), zap.Error(err))
+			http.Error(w, "Failed to handle change event", http.StatusInternalServerError)
+			return
+		}
+	}
+	if acker, ok := h.handler.(cdc.Acknowledger); ok {
+		done := make(chan struct{})
+		acker.AckAfter(func() { close(done) })
+		select {
+		case <-done:
+		case <-r.Context().Done():
+			http.Error(w, "Change events not persisted yet", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// receivedEvent is a change event or, with provide.transaction.metadata, a BEGIN or END.
+type receivedEvent struct {
+	event cdc.Event
+	tx    *cdc.TransactionEvent
+}
+
+// splitBatch returns the messages of a JSON array body or the body itself.
+func splitBatch(body []byte) ([]json.RawMessage, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, err
+		}
+		return batch, nil
+	}
+	if !json.Valid(body) {
+		return nil, errors.New("invalid json")
+	}
+	return []json.RawMessage{body}, nil
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"debez/internal/models"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const maxReplayBody = 256 << 20
+
+type ReplayService interface {
+	Start(ctx context.Context, recording io.ReadCloser, handlers []string, speed float64) (models.ReplayJob, error)
+	GetReplay(id int64) (models.ReplayJob, error)
+	CancelReplay(id int64) (models.ReplayJob, error)
+}
+
+// ChangeEventReplayer replays a recording made with cdc.Record, POSTed as the body, in the
+// background. Events the dedup middleware already saw are skipped by it. Requests must
+// carry the same secret as the ones of the Debezium Server HTTP sink.
+type ChangeEventReplayer struct {
+	ctx          context.Context
+	service      ReplayService
+	secretHeader string
+	secret       string
+}
+
+func NewChangeEventReplayer(ctx context.Context, service ReplayService, secretHeader, secret string) *ChangeEventReplayer {
+	return &ChangeEventReplayer{
+		ctx:          ctx,
+		service:      service,
+		secretHeader: secretHeader,
+		secret:       secret,
+	}
+}
+
+// ReplayEvents starts replaying the body to the handlers listed, comma separated, in the
+// handlers query parameter, by default to the ones without effects outside the service. The
+// speed query parameter sets the pace, 0 by default: as fast as the handlers go. It answers
+// 202 with the replay, whose status GetReplay serves.
+func (h *ChangeEventReplayer) ReplayEvents(w http.ResponseWriter, r *http.Request) {
+	if !validSecret(r, h.secretHeader, h.secret) {
+		http.Error(w, "Invalid secret", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	speed := 0.0
+	if value := query.Get("speed"); value != "" {
+		var err error
+		if speed, err = strconv.ParseFloat(value, 64); err != nil || speed < 0 {
+			http.Error(w, "Invalid speed", http.StatusBadRequest)
+			return
+		}
+	}
+	var handlerNames []string
+	if value := query.Get("handlers"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			handlerNames = append(handlerNames, strings.TrimSpace(name))
+		}
+	}
+
+	// The replay outlives the request, its body is kept in a file until the replay ends.
+	recording, err := spoolRecording(http.MaxBytesReader(w, r.Body, maxReplayBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Recording too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	job, err := h.service.Start(h.ctx, recording, handlerNames, speed)
+	switch {
+	case errors.Is(err, models.ErrReplayHandler):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrReplayRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, "Failed to start replay", http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+func (h *ChangeEventReplayer) GetReplay(w http.ResponseWriter, r *http.Request) {
+	h.writeReplay(w, r, h.service.GetReplay)
+}
+
+// CancelReplay stops a running replay, the events handled so far stay handled.
+func (h *ChangeEventReplayer) CancelReplay(w http.ResponseWriter, r *http.Request) {
+	h.writeReplay(w, r, h.service.CancelReplay)
+}
+
+func (h *ChangeEventReplayer) writeReplay(w http.ResponseWriter, r *http.Request, get func(id int64) (models.ReplayJob, error)) {
+	if !validSecret(r, h.secretHeader, h.secret) {
+		http.Error(w, "Invalid secret", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid replay ID", http.StatusBadRequest)
+		return
+	}
+	job, err := get(id)
+	if errors.Is(err, models.ErrReplayNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get replay", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// spoolRecording copies body to a temporary file, removed when the returned reader is closed.
+func spoolRecording(body io.Reader) (io.ReadCloser, error) {
+	f, err := os.CreateTemp("", "cdc-replay-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	recording := &tempFile{File: f}
+	if _, err := io.Copy(f, body); err != nil {
+		recording.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		recording.Close()
+		return nil, err
+	}
+	return recording, nil
+}
+
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); removeErr != nil && err == nil {
+		err = removeErr
+	}
+	return err
+}
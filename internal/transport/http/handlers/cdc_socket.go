@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"debez/pkg/cdc"
+	"debez/pkg/logger"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	socketWriteTimeout  = 10 * time.Second
+	socketMaxMessage    = 64 << 10
+	socketMaxFilters    = 32
+	socketControlBuffer = 16
+)
+
+// SocketMessage is exchanged over the change event WebSocket. Clients send subscribe (ID, Filter)
+// and unsubscribe (ID). The server answers subscribed, unsubscribed or error and sends matching
+// events as event messages carrying the ID of the subscription.
+type SocketMessage struct {
+	Type   string      `json:"type"`
+	ID     string      `json:"id,omitempty"`
+	Filter *cdc.Filter `json:"filter,omitempty"`
+	Event  *cdc.Event  `json:"event,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+const (
+	socketSubscribe    = "subscribe"
+	socketUnsubscribe  = "unsubscribe"
+	socketSubscribed   = "subscribed"
+	socketUnsubscribed = "unsubscribed"
+	socketEvent        = "event"
+	socketError        = "error"
+)
+
+// ChangeEventSocket serves change events over WebSocket with per-connection filters.
+// Every connection has a bounded send buffer, a client that lets it fill up is disconnected
+// with close code 1008 rather than buffered for.
+type ChangeEventSocket struct {
+	ctx      context.Context
+	hub      *cdc.Hub
+	buffer   int
+	ping     time.Duration
+	upgrader websocket.Upgrader
+}
+
+func NewChangeEventSocket(ctx context.Context, hub *cdc.Hub, buffer int, ping time.Duration) *ChangeEventSocket {
+	if ping <= 0 {
+		ping = defaultKeepalive
+	}
+	return &ChangeEventSocket{
+		ctx:    ctx,
+		hub:    hub,
+		buffer: buffer,
+		ping:   ping,
+	}
+}
+
+type socketConn struct {
+	conn    *websocket.Conn
+	control chan SocketMessage
+
+	mu      sync.RWMutex
+	filters map[string]cdc.Filter
+}
+
+func (h *ChangeEventSocket) Serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(socketMaxMessage)
+
+	sub, _ := h.hub.Subscribe(h.buffer, "")
+	defer sub.Close()
+
+	c := &socketConn{
+		conn:    conn,
+		control: make(chan SocketMessage, socketControlBuffer),
+		filters: make(map[string]cdc.Filter),
+	}
+	readDone := make(chan error, 1)
+	go func() {
+		readDone <- c.read()
+	}()
+
+	err = c.write(sub, h.ping, readDone)
+	var closeErr *websocket.CloseError
+	if err != nil && !errors.As(err, &closeErr) {
+		logger.GetLoggerFromCtx(h.ctx).Info(h.ctx, "change event socket closed", zap.Error(err))
+	}
+}
+
+// read handles client messages until the connection fails. Replies go through the writer.
+func (c *socketConn) read() error {
+	for {
+		var msg SocketMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				if !c.reply(SocketMessage{Type: socketError, Error: "invalid message"}) {
+					return cdc.ErrSlowConsumer
+				}
+				continue
+			}
+			return err
+		}
+		if !c.reply(c.handle(msg)) {
+			return cdc.ErrSlowConsumer
+		}
+	}
+}
+
+func (c *socketConn) handle(msg SocketMessage) SocketMessage {
+	if msg.ID == "" {
+		return SocketMessage{Type: socketError, Error: "id is required"}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch msg.Type {
+	case socketSubscribe:
+		filter := cdc.Filter{}
+		if msg.Filter != nil {
+			filter = *msg.Filter
+		}
+		if err := filter.Validate(); err != nil {
+			return SocketMessage{Type: socketError, ID: msg.ID, Error: err.Error()}
+		}
+		if _, ok := c.filters[msg.ID]; !ok && len(c.filters) >= socketMaxFilters {
+			return SocketMessage{Type: socketError, ID: msg.ID, Error: "too many subscriptions"}
+		}
+		c.filters[msg.ID] = filter
+		return SocketMessage{Type: socketSubscribed, ID: msg.ID}
+	case socketUnsubscribe:
+		delete(c.filters, msg.ID)
+		return SocketMessage{Type: socketUnsubscribed, ID: msg.ID}
+	default:
+		return SocketMessage{Type: socketError, ID: msg.ID, Error: "unknown message type"}
+	}
+}
+
+func (c *socketConn) reply(msg SocketMessage) bool {
+	select {
+	case c.control <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// write is the only writer of the connection. It returns when the reader stops, the hub
+// drops the subscription or a write fails or times out.
+func (c *socketConn) write(sub *cdc.Subscription, ping time.Duration, readDone <-chan error) error {
+	ticker := time.NewTicker(ping)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case err := <-readDone:
+			if errors.Is(err, cdc.ErrSlowConsumer) {
+				c.close(websocket.ClosePolicyViolation, "slow consumer")
+			}
+			return err
+		case <-sub.Done():
+			if errors.Is(sub.Err(), cdc.ErrHubClosed) {
+				c.close(websocket.CloseGoingAway, "server shutting down")
+				return nil
+			}
+			c.close(websocket.ClosePolicyViolation, "slow consumer")
+			return sub.Err()
+		case msg := <-c.control:
+			err = c.send(msg)
+		case <-ticker.C:
+			err = c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteTimeout))
+		case e := <-sub.Events():
+			err = c.dispatch(e.Event)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *socketConn) dispatch(e cdc.Event) error {
+	c.mu.RLock()
+	var ids []string
+	for id, filter := range c.filters {
+		if filter.Match(e) {
+			ids = append(ids, id)
+		}
+	}
+	c.mu.RUnlock()
+	for _, id := range ids {
+		if err := c.send(SocketMessage{Type: socketEvent, ID: id, Event: &e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *socketConn) send(msg SocketMessage) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout)); err != nil {
+		return err
+	}
+	return c.conn.WriteJSON(msg)
+}
+
+func (c *socketConn) close(code int, text string) {
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(socketWriteTimeout))
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"debez/internal/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ConnectorEventService interface {
+	GetEvents(ctx context.Context, filter models.ConnectorEventFilter) ([]models.ConnectorEvent, error)
+}
+
+type ConnectorHandler struct {
+	ctx     context.Context
+	service ConnectorEventService
+}
+
+func NewConnectorHandler(ctx context.Context, service ConnectorEventService) *ConnectorHandler {
+	return &ConnectorHandler{
+		ctx:     ctx,
+		service: service,
+	}
+}
+
+// GetEvents returns the journal of a connector. from and to are RFC3339 timestamps, to is exclusive.
+func (h *ConnectorHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	filter := models.ConnectorEventFilter{Connector: r.PathValue("name")}
+	query := r.URL.Query()
+	var err error
+	if from := query.Get("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			http.Error(w, "Invalid from", http.StatusBadRequest)
+			return
+		}
+	}
+	if to := query.Get("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			http.Error(w, "Invalid to", http.StatusBadRequest)
+			return
+		}
+	}
+	if filter.Offset, filter.Limit, err = pagination(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.service.GetEvents(h.ctx, filter)
+	if err != nil {
+		http.Error(w, "Failed to get connector events", http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []models.ConnectorEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		http.Error(w, "Failed to encode connector events", http.StatusInternalServerError)
+		return
+	}
+}
+
+func pagination(r *http.Request) (offset, limit int, err error) {
+	query := r.URL.Query()
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, errInvalidParam("offset")
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return 0, 0, errInvalidParam("limit")
+		}
+	}
+	return offset, limit, nil
+}
+
+type errInvalidParam string
+
+func (e errInvalidParam) Error() string {
+	return "Invalid " + string(e)
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/internal/transport/http/modelsDTO"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type DeadLetterService interface {
+	GetDeadLetters(ctx context.Context, filter models.DeadLetterFilter) ([]models.DeadLetter, error)
+	GetDeadLetter(ctx context.Context, id int64) (models.DeadLetter, error)
+	Replay(ctx context.Context, id int64) error
+	Discard(ctx context.Context, id int64) error
+	ReplayAll(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterResult, error)
+	DiscardAll(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterResult, error)
+}
+
+type DeadLetterHandler struct {
+	ctx     context.Context
+	service DeadLetterService
+}
+
+func NewDeadLetterHandler(ctx context.Context, service DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		ctx:     ctx,
+		service: service,
+	}
+}
+
+// GetDeadLetters lists entries, filtered by the handler and status query parameters.
+func (h *DeadLetterHandler) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.DeadLetterFilter{
+		Handler: query.Get("handler"),
+		Status:  models.DeadLetterStatus(query.Get("status")),
+	}
+	switch filter.Status {
+	case "", models.DeadLetterPending, models.DeadLetterReplaying, models.DeadLetterReplayed, models.DeadLetterDiscarded:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if filter.Offset, filter.Limit, err = pagination(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	letters, err := h.service.GetDeadLetters(h.ctx, filter)
+	if err != nil {
+		http.Error(w, "Failed to get dead letters", http.StatusInternalServerError)
+		return
+	}
+	if letters == nil {
+		letters = []models.DeadLetter{}
+	}
+	writeJSON(w, http.StatusOK, letters)
+}
+
+func (h *DeadLetterHandler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, ok := deadLetterID(w, r)
+	if !ok {
+		return
+	}
+	letter, err := h.service.GetDeadLetter(h.ctx, id)
+	if h.writeError(w, err, "Failed to get dead letter") {
+		return
+	}
+	writeJSON(w, http.StatusOK, letter)
+}
+
+func (h *DeadLetterHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	id, ok := deadLetterID(w, r)
+	if !ok {
+		return
+	}
+	if h.writeError(w, h.service.Replay(h.ctx, id), "Failed to replay dead letter") {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DeadLetterHandler) Discard(w http.ResponseWriter, r *http.Request) {
+	id, ok := deadLetterID(w, r)
+	if !ok {
+		return
+	}
+	if h.writeError(w, h.service.Discard(h.ctx, id), "Failed to discard dead letter") {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DeadLetterHandler) ReplayAll(w http.ResponseWriter, r *http.Request) {
+	h.bulk(w, r, h.service.ReplayAll)
+}
+
+func (h *DeadLetterHandler) DiscardAll(w http.ResponseWriter, r *http.Request) {
+	h.bulk(w, r, h.service.DiscardAll)
+}
+
+func (h *DeadLetterHandler) bulk(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, filter models.DeadLetterFilter) (models.DeadLetterResult, error)) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	var dto modelsDTO.DeadLetterBulkDTO
+	if err := json.Unmarshal(body, &dto); err != nil {
+		http.Error(w, "Failed to unmarshal request body", http.StatusBadRequest)
+		return
+	}
+	if len(dto.IDs) == 0 && dto.Handler == "" && !dto.All {
+		http.Error(w, "Either ids, handler or all is required", http.StatusBadRequest)
+		return
+	}
+	result, err := fn(h.ctx, models.DeadLetterFilter{IDs: dto.IDs, Handler: dto.Handler})
+	if err != nil {
+		http.Error(w, "Failed to process dead letters", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *DeadLetterHandler) writeError(w http.ResponseWriter, err error, message string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, models.ErrDeadLetterNotFound):
+		http.Error(w, "Dead letter not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrDeadLetterResolved):
+		http.Error(w, "Dead letter already resolved", http.StatusConflict)
+	default:
+		http.Error(w, message+": "+err.Error(), http.StatusInternalServerError)
+	}
+	return true
+}
+
+func deadLetterID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid dead letter ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
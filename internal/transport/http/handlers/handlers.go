@@ -5,6 +5,7 @@ import (
 	"debez/internal/models"
 	"debez/internal/transport/http/modelsDTO"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -117,7 +118,10 @@ func (h *HandlerFacade) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		Role:     user.Role,
 	}
 	err = h.service.UpdateUser(h.ctx, updatedUser)
-
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to update user", http.StatusInternalServerError)
 		return
@@ -131,6 +135,10 @@ func (h *HandlerFacade) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	err = h.service.DeleteUser(h.ctx, int64(userID))
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
 		return
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"debez/internal/transport/http/modelsDTO"
+	"debez/pkg/postgres"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type ReplicationInspector interface {
+	ListReplicationSlots(ctx context.Context) ([]postgres.ReplicationSlot, error)
+	ListPublications(ctx context.Context) ([]postgres.Publication, error)
+	GetReplicaIdentity(ctx context.Context, table string) (postgres.TableReplicaIdentity, error)
+	SetReplicaIdentity(ctx context.Context, table string, identity postgres.ReplicaIdentity, index string) error
+}
+
+// ReplicationHandler serves the replication state. Replica identities may only be changed for
+// the allowed tables, by requests carrying the secret in secretHeader.
+type ReplicationHandler struct {
+	ctx          context.Context
+	inspector    ReplicationInspector
+	tables       []string
+	secretHeader string
+	secret       string
+}
+
+func NewReplicationHandler(ctx context.Context, inspector ReplicationInspector, tables []string, secretHeader, secret string) *ReplicationHandler {
+	qualified := make([]string, 0, len(tables))
+	for _, table := range tables {
+		qualified = append(qualified, qualifiedTable(table))
+	}
+	return &ReplicationHandler{
+		ctx:          ctx,
+		inspector:    inspector,
+		tables:       qualified,
+		secretHeader: secretHeader,
+		secret:       secret,
+	}
+}
+
+// qualifiedTable prefixes a table without a schema with public, the default search path.
+func qualifiedTable(table string) string {
+	if strings.Contains(table, ".") {
+		return table
+	}
+	return "public." + table
+}
+
+func (h *ReplicationHandler) GetSlots(w http.ResponseWriter, r *http.Request) {
+	slots, err := h.inspector.ListReplicationSlots(h.ctx)
+	if err != nil {
+		http.Error(w, "Failed to list replication slots", http.StatusInternalServerError)
+		return
+	}
+	if slots == nil {
+		slots = []postgres.ReplicationSlot{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(slots); err != nil {
+		http.Error(w, "Failed to encode replication slots", http.StatusInternalServerError)
+		return
+	}
+}
+func (h *ReplicationHandler) GetPublications(w http.ResponseWriter, r *http.Request) {
+	publications, err := h.inspector.ListPublications(h.ctx)
+	if err != nil {
+		http.Error(w, "Failed to list publications", http.StatusInternalServerError)
+		return
+	}
+	if publications == nil {
+		publications = []postgres.Publication{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(publications); err != nil {
+		http.Error(w, "Failed to encode publications", http.StatusInternalServerError)
+		return
+	}
+}
+func (h *ReplicationHandler) GetReplicaIdentity(w http.ResponseWriter, r *http.Request) {
+	table := r.PathValue("table")
+	identity, err := h.inspector.GetReplicaIdentity(h.ctx, table)
+	if errors.Is(err, postgres.ErrTableNotFound) {
+		http.Error(w, "Table not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get replica identity", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(identity); err != nil {
+		http.Error(w, "Failed to encode replica identity", http.StatusInternalServerError)
+		return
+	}
+}
+
+// SetReplicaIdentity is refused when no secret is configured.
+func (h *ReplicationHandler) SetReplicaIdentity(w http.ResponseWriter, r *http.Request) {
+	if h.secret == "" {
+		http.Error(w, "Replica identity changes are disabled", http.StatusForbidden)
+		return
+	}
+	if !validSecret(r, h.secretHeader, h.secret) {
+		http.Error(w, "Invalid secret", http.StatusUnauthorized)
+		return
+	}
+	table := r.PathValue("table")
+	if !slices.Contains(h.tables, qualifiedTable(table)) {
+		http.Error(w, "Table is not captured", http.StatusForbidden)
+		return
+	}
+
+	var request modelsDTO.SetReplicaIdentityDTO
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, "Failed to unmarshal request body", http.StatusBadRequest)
+		return
+	}
+	identity := postgres.ReplicaIdentity(strings.ToUpper(request.Identity))
+	if identity == "INDEX" {
+		identity = postgres.ReplicaIdentityIndex
+	}
+	switch identity {
+	case postgres.ReplicaIdentityDefault, postgres.ReplicaIdentityFull, postgres.ReplicaIdentityNothing:
+	case postgres.ReplicaIdentityIndex:
+		if request.Index == "" {
+			http.Error(w, "Index is required for USING INDEX", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Invalid replica identity", http.StatusBadRequest)
+		return
+	}
+	if err := h.inspector.SetReplicaIdentity(h.ctx, table, identity, request.Index); err != nil {
+		http.Error(w, "Failed to set replica identity", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/pkg/cdc"
+	"net/http"
+)
+
+type SchemaVersionService interface {
+	GetSchemaVersions(ctx context.Context, filter models.SchemaVersionFilter) ([]cdc.TableSchema, error)
+}
+
+type SchemaVersionHandler struct {
+	ctx     context.Context
+	service SchemaVersionService
+}
+
+func NewSchemaVersionHandler(ctx context.Context, service SchemaVersionService) *SchemaVersionHandler {
+	return &SchemaVersionHandler{
+		ctx:     ctx,
+		service: service,
+	}
+}
+
+// GetSchemaVersions lists the detected schema versions of captured tables, newest first,
+// filtered by the schema and table query parameters.
+func (h *SchemaVersionHandler) GetSchemaVersions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.SchemaVersionFilter{Schema: query.Get("schema"), Table: query.Get("table")}
+	var err error
+	if filter.Offset, filter.Limit, err = pagination(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	versions, err := h.service.GetSchemaVersions(h.ctx, filter)
+	if err != nil {
+		http.Error(w, "Failed to get schema versions", http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []cdc.TableSchema{}
+	}
+	writeJSON(w, http.StatusOK, versions)
+}
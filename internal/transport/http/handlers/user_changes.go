@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"debez/internal/transport/http/modelsDTO"
+	"debez/internal/userevents"
+	"debez/pkg/cdc"
+	"debez/pkg/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	userChangesBuffer = 256
+	// defaultKeepalive is the ping interval of change streams configured without one.
+	defaultKeepalive = 15 * time.Second
+)
+
+// UserChangesHandler streams users change events as Server-Sent Events.
+type UserChangesHandler struct {
+	ctx       context.Context
+	hub       *cdc.Hub
+	decoder   *userevents.Decoder
+	keepalive time.Duration
+}
+
+func NewUserChangesHandler(ctx context.Context, hub *cdc.Hub, keepalive time.Duration) *UserChangesHandler {
+	if keepalive <= 0 {
+		keepalive = defaultKeepalive
+	}
+	return &UserChangesHandler{
+		ctx:       ctx,
+		hub:       hub,
+		decoder:   userevents.NewDecoder(userevents.TimePrecisionAdaptive),
+		keepalive: keepalive,
+	}
+}
+
+// StreamChanges sends every users change matching the optional id and op (comma separated
+// c, u, d, t) filters. A reconnecting client gets the buffered events published after the
+// one in Last-Event-ID. A client too slow to keep up is disconnected and resumes the same way.
+func (h *UserChangesHandler) StreamChanges(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	query := r.URL.Query()
+	var userID int64
+	if v := query.Get("id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid id", http.StatusBadRequest)
+			return
+		}
+		userID = id
+	}
+	var ops []cdc.Op
+	if v := query.Get("op"); v != "" {
+		for _, op := range strings.Split(v, ",") {
+			switch op := cdc.Op(strings.TrimSpace(op)); op {
+			case cdc.OpCreate, cdc.OpUpdate, cdc.OpDelete, cdc.OpTruncate:
+				ops = append(ops, op)
+			default:
+				http.Error(w, "Invalid op", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = query.Get("last_event_id")
+	}
+
+	sub, replay := h.hub.Subscribe(userChangesBuffer, lastID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(published cdc.HubEvent) error {
+		e := published.Event
+		if len(ops) > 0 && !slices.Contains(ops, e.Op) {
+			return nil
+		}
+		change, err := h.decoder.Decode(e)
+		if err != nil {
+			return nil
+		}
+		if userID != 0 && e.Op != cdc.OpTruncate && change.UserID() != userID {
+			return nil
+		}
+		data, err := json.Marshal(modelsDTO.UserChangeDTO{
+			ID:     published.ID,
+			Op:     string(change.Op),
+			Before: change.Before,
+			After:  change.After,
+			TsMs:   change.TsMs,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: change\ndata: %s\n\n", published.ID, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	for _, e := range replay {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	keepalive := time.NewTicker(h.keepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done():
+			if !errors.Is(sub.Err(), cdc.ErrHubClosed) {
+				logger.GetLoggerFromCtx(h.ctx).Info(h.ctx, "user changes stream closed", zap.Error(sub.Err()))
+			}
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e := <-sub.Events():
+			if err := send(e); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/internal/transport/http/modelsDTO"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultUsersLimit matches the page size of the users list.
+const defaultUsersLimit = 10
+
+type UserHistoryService interface {
+	GetHistory(ctx context.Context, filter models.UserHistoryFilter) ([]models.UserHistoryEntry, error)
+	GetUserAsOf(ctx context.Context, id int64, t time.Time) (models.User, error)
+	GetUsersAsOf(ctx context.Context, t time.Time, offset, limit int) ([]models.User, error)
+}
+
+type UserHistoryHandler struct {
+	ctx     context.Context
+	service UserHistoryService
+}
+
+func NewUserHistoryHandler(ctx context.Context, service UserHistoryService) *UserHistoryHandler {
+	return &UserHistoryHandler{
+		ctx:     ctx,
+		service: service,
+	}
+}
+
+// GetHistory returns the captured changes of a user, newest first.
+func (h *UserHistoryHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	filter := models.UserHistoryFilter{UserID: userID}
+	if filter.Offset, filter.Limit, err = pagination(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.service.GetHistory(h.ctx, filter)
+	if err != nil {
+		http.Error(w, "Failed to get user history", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []models.UserHistoryEntry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		http.Error(w, "Failed to encode user history", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetUserAsOf returns a user as it was at the RFC3339 as_of timestamp.
+func (h *UserHistoryHandler) GetUserAsOf(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	asOf, err := time.Parse(time.RFC3339, r.URL.Query().Get("as_of"))
+	if err != nil {
+		http.Error(w, "Invalid as_of", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.GetUserAsOf(h.ctx, userID, asOf)
+	if errors.Is(err, models.ErrUserNotFound) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		return
+	}
+	userDTO := modelsDTO.UserDTO{
+		ID:       user.ID,
+		Name:     user.Name,
+		Email:    user.Email,
+		LastName: user.LastName,
+		Role:     user.Role,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(userDTO); err != nil {
+		http.Error(w, "Failed to encode users", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetUsersAsOf returns the users that existed at the RFC3339 as_of timestamp.
+func (h *UserHistoryHandler) GetUsersAsOf(w http.ResponseWriter, r *http.Request) {
+	asOf, err := time.Parse(time.RFC3339, r.URL.Query().Get("as_of"))
+	if err != nil {
+		http.Error(w, "Invalid as_of", http.StatusBadRequest)
+		return
+	}
+	offset, limit, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		limit = defaultUsersLimit
+	}
+
+	users, err := h.service.GetUsersAsOf(h.ctx, asOf, offset, limit)
+	if err != nil {
+		http.Error(w, "Failed to get users", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(users); err != nil {
+		http.Error(w, "Failed to encode users", http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"debez/internal/models"
+	"debez/internal/transport/http/modelsDTO"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (models.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	GetAttempts(ctx context.Context, subscriptionID int64, offset, limit int) ([]models.WebhookAttempt, error)
+}
+
+type WebhookHandler struct {
+	ctx     context.Context
+	service WebhookService
+}
+
+func NewWebhookHandler(ctx context.Context, service WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		ctx:     ctx,
+		service: service,
+	}
+}
+
+// CreateSubscription answers with the subscription including its secret, the only time it is returned.
+func (h *WebhookHandler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.readSubscription(w, r)
+	if !ok {
+		return
+	}
+	created, err := h.service.CreateSubscription(h.ctx, sub)
+	if h.writeError(w, err, "Failed to create webhook") {
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *WebhookHandler) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.service.GetSubscriptions(h.ctx)
+	if h.writeError(w, err, "Failed to get webhooks") {
+		return
+	}
+	if subs == nil {
+		subs = []models.WebhookSubscription{}
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+func (h *WebhookHandler) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	sub, err := h.service.GetSubscription(h.ctx, id)
+	if h.writeError(w, err, "Failed to get webhook") {
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	sub, ok := h.readSubscription(w, r)
+	if !ok {
+		return
+	}
+	sub.ID = id
+	updated, err := h.service.UpdateSubscription(h.ctx, sub)
+	if h.writeError(w, err, "Failed to update webhook") {
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *WebhookHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	if h.writeError(w, h.service.DeleteSubscription(h.ctx, id), "Failed to delete webhook") {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAttempts returns the delivery attempts of a subscription, newest first.
+func (h *WebhookHandler) GetAttempts(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+	offset, limit, err := pagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	attempts, err := h.service.GetAttempts(h.ctx, id, offset, limit)
+	if h.writeError(w, err, "Failed to get webhook attempts") {
+		return
+	}
+	if attempts == nil {
+		attempts = []models.WebhookAttempt{}
+	}
+	writeJSON(w, http.StatusOK, attempts)
+}
+
+func (h *WebhookHandler) readSubscription(w http.ResponseWriter, r *http.Request) (models.WebhookSubscription, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return models.WebhookSubscription{}, false
+	}
+	var dto modelsDTO.WebhookDTO
+	if err := json.Unmarshal(body, &dto); err != nil {
+		http.Error(w, "Failed to unmarshal request body", http.StatusBadRequest)
+		return models.WebhookSubscription{}, false
+	}
+	sub := models.WebhookSubscription{
+		URL:     dto.URL,
+		Secret:  dto.Secret,
+		Filter:  dto.Filter,
+		Enabled: dto.Enabled == nil || *dto.Enabled,
+	}
+	return sub, true
+}
+
+func (h *WebhookHandler) writeError(w http.ResponseWriter, err error, message string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, models.ErrWebhookNotFound):
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrInvalidWebhook):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+	return true
+}
+
+func webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,9 @@
+package modelsDTO
+
+// DeadLetterBulkDTO selects the pending entries of a bulk replay or discard: the listed IDs,
+// the entries of Handler, or every pending entry when All is set.
+type DeadLetterBulkDTO struct {
+	IDs     []int64 `json:"ids"`
+	Handler string  `json:"handler"`
+	All     bool    `json:"all"`
+}
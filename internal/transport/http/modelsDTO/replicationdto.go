@@ -0,0 +1,6 @@
+package modelsDTO
+
+type SetReplicaIdentityDTO struct {
+	Identity string `json:"identity"`
+	Index    string `json:"index"`
+}
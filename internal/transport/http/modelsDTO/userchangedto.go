@@ -0,0 +1,13 @@
+package modelsDTO
+
+import "debez/internal/models"
+
+// UserChangeDTO is a users change event as streamed to clients. ID is its stream position,
+// sent back in Last-Event-ID to resume.
+type UserChangeDTO struct {
+	ID     string       `json:"id"`
+	Op     string       `json:"op"`
+	Before *models.User `json:"before"`
+	After  *models.User `json:"after"`
+	TsMs   int64        `json:"ts_ms"`
+}
@@ -0,0 +1,11 @@
+package modelsDTO
+
+import "debez/pkg/cdc"
+
+type WebhookDTO struct {
+	URL    string     `json:"url"`
+	Secret string     `json:"secret"`
+	Filter cdc.Filter `json:"filter"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled"`
+}
@@ -5,7 +5,9 @@ import (
 	"debez/internal/repository"
 	"debez/internal/service"
 	"debez/internal/transport/http/handlers"
+	"debez/pkg/cdc"
 	"debez/pkg/logger"
+	"debez/pkg/postgres"
 	"net/http"
 	"strconv"
 	"time"
@@ -16,6 +18,44 @@ import (
 type Server struct {
 	srv *http.Server
 	db  *pgxpool.Pool
+
+	changeEvents *ChangeEvents
+	userRepo     service.UserRepository
+	admin        Admin
+}
+
+// Admin guards the endpoints that change the database.
+type Admin struct {
+	// Tables are the ones whose replica identity may be changed, the captured ones.
+	Tables []string
+	// Secret must be sent in SecretHeader, changes are refused when it is empty.
+	SecretHeader string
+	Secret       string
+}
+
+// ChangeEvents wires the service's CDC pipeline into the HTTP API.
+type ChangeEvents struct {
+	// Handler receives events POSTed by the Debezium Server HTTP sink.
+	Handler cdc.Handler
+	// SinkSecret must be sent in SinkHeader by the sink when it is not empty.
+	SinkHeader string
+	SinkSecret string
+	Metrics    *cdc.Metrics
+	// Hub feeds the users change stream, Keepalive is the interval of its pings.
+	Hub       *cdc.Hub
+	Keepalive time.Duration
+	// SocketHub feeds the WebSocket API with events of every table, SocketBuffer
+	// is how many events a connection may lag behind before it is dropped.
+	SocketHub    *cdc.Hub
+	SocketBuffer int
+	// Dispatcher replays dead letters through the handlers that failed on them.
+	Dispatcher *cdc.Dispatcher
+	// Replay replays POSTed recordings to the handlers the caller names, to ReplayHandlers
+	// when it names none. Nil keeps the endpoints off.
+	Replay         *cdc.Dispatcher
+	ReplayHandlers []string
+	// SchemaVersions serves the schema versions detected from change events.
+	SchemaVersions *service.SchemaVersionService
 }
 
 const (
@@ -33,10 +73,30 @@ func NewServer(port int, db *pgxpool.Pool) *Server {
 		db:  db,
 	}
 }
+
+// SetChangeEvents enables the change event endpoints. Call it before RegisterHandler.
+func (s *Server) SetChangeEvents(changeEvents ChangeEvents) {
+	s.changeEvents = &changeEvents
+}
+
+// SetAdmin configures the endpoints that change the database. Call it before RegisterHandler.
+func (s *Server) SetAdmin(admin Admin) {
+	s.admin = admin
+}
+
+// SetUserRepository replaces the default users repository, e.g. with a cached one. Call it before RegisterHandler.
+func (s *Server) SetUserRepository(repo service.UserRepository) {
+	s.userRepo = repo
+}
+
 func (s *Server) RegisterHandler(ctx context.Context) error {
-	userRepo := repository.NewUserRepository(s.db)
-	userService := service.NewUserService(userRepo)
+	userRepo := s.userRepo
+	if userRepo == nil {
+		userRepo = repository.NewUserRepository(s.db)
+	}
+	userService := service.NewUserService(userRepo, repository.NewOutboxRepository(s.db), &postgres.DataBase{Pool: s.db})
 	handler := handlers.NewHandlerFacade(ctx, userService)
+	userHistoryHandler := handlers.NewUserHistoryHandler(ctx, service.NewUserHistoryService(repository.NewUserHistoryRepository(s.db)))
 
 	mux := http.NewServeMux()
 
@@ -45,6 +105,10 @@ func (s *Server) RegisterHandler(ctx context.Context) error {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
+		if r.URL.Query().Has("as_of") {
+			userHistoryHandler.GetUsersAsOf(w, r)
+			return
+		}
 		handler.GetUsers(w, r)
 	}))
 	mux.HandleFunc("/api/v1/users/{id}", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
@@ -52,8 +116,19 @@ func (s *Server) RegisterHandler(ctx context.Context) error {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
+		if r.URL.Query().Has("as_of") {
+			userHistoryHandler.GetUserAsOf(w, r)
+			return
+		}
 		handler.GetUserByID(w, r)
 	}))
+	mux.HandleFunc("/api/v1/users/{id}/history", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		userHistoryHandler.GetHistory(w, r)
+	}))
 	mux.HandleFunc("/api/v1/create_user", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -75,11 +150,196 @@ func (s *Server) RegisterHandler(ctx context.Context) error {
 		}
 		handler.DeleteUser(w, r)
 	}))
+
+	replicationHandler := handlers.NewReplicationHandler(ctx, &postgres.DataBase{Pool: s.db}, s.admin.Tables, s.admin.SecretHeader, s.admin.Secret)
+	mux.HandleFunc("/api/v1/replication/slots", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		replicationHandler.GetSlots(w, r)
+	}))
+	mux.HandleFunc("/api/v1/replication/publications", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		replicationHandler.GetPublications(w, r)
+	}))
+	mux.HandleFunc("/api/v1/replication/replica_identity/{table}", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			replicationHandler.GetReplicaIdentity(w, r)
+		case http.MethodPut:
+			replicationHandler.SetReplicaIdentity(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+
+	connectorEventRepo := repository.NewConnectorEventRepository(s.db)
+	connectorHandler := handlers.NewConnectorHandler(ctx, service.NewConnectorEventService(connectorEventRepo, nil))
+	mux.HandleFunc("/api/v1/connectors/{name}/events", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		connectorHandler.GetEvents(w, r)
+	}))
+	webhookHandler := handlers.NewWebhookHandler(ctx, service.NewWebhookService(repository.NewWebhookRepository(s.db), service.WebhookOptions{}))
+	mux.HandleFunc("/api/v1/webhooks", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			webhookHandler.GetSubscriptions(w, r)
+		case http.MethodPost:
+			webhookHandler.CreateSubscription(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/v1/webhooks/{id}", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			webhookHandler.GetSubscription(w, r)
+		case http.MethodPut:
+			webhookHandler.UpdateSubscription(w, r)
+		case http.MethodDelete:
+			webhookHandler.DeleteSubscription(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	mux.HandleFunc("/api/v1/webhooks/{id}/attempts", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		webhookHandler.GetAttempts(w, r)
+	}))
+	if s.changeEvents != nil {
+		s.registerChangeEvents(ctx, mux)
+	}
 	s.srv.Handler = LoggingMiddleware(ctx)(mux)
 
 	return nil
 }
 
+func (s *Server) registerChangeEvents(ctx context.Context, mux *http.ServeMux) {
+	changeEvents := s.changeEvents
+	if changeEvents.Handler != nil {
+		receiver := handlers.NewChangeEventReceiver(ctx, changeEvents.Handler, changeEvents.SinkHeader, changeEvents.SinkSecret)
+		mux.HandleFunc("/api/v1/cdc/events", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			receiver.ReceiveEvents(w, r)
+		}))
+	}
+	if changeEvents.Metrics != nil {
+		mux.HandleFunc("/api/v1/cdc/metrics", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			changeEvents.Metrics.ServeHTTP(w, r)
+		}))
+	}
+	// Shutdown does not cancel request contexts, closing the hubs ends the open streams.
+	for _, hub := range []*cdc.Hub{changeEvents.Hub, changeEvents.SocketHub} {
+		if hub != nil {
+			s.srv.RegisterOnShutdown(hub.Close)
+		}
+	}
+	if changeEvents.Hub != nil {
+		userChangesHandler := handlers.NewUserChangesHandler(ctx, changeEvents.Hub, changeEvents.Keepalive)
+		mux.HandleFunc("/api/v1/users/changes", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			userChangesHandler.StreamChanges(w, r)
+		}))
+	}
+	if changeEvents.SocketHub != nil {
+		socket := handlers.NewChangeEventSocket(ctx, changeEvents.SocketHub, changeEvents.SocketBuffer, changeEvents.Keepalive)
+		mux.HandleFunc("/api/v1/cdc/ws", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			socket.Serve(w, r)
+		}))
+	}
+	if changeEvents.Dispatcher != nil {
+		s.registerDeadLetters(ctx, mux, changeEvents.Dispatcher)
+	}
+	if changeEvents.Replay != nil {
+		replays := service.NewReplayService(changeEvents.Replay, changeEvents.ReplayHandlers)
+		replayer := handlers.NewChangeEventReplayer(ctx, replays, changeEvents.SinkHeader, changeEvents.SinkSecret)
+		mux.HandleFunc("/api/v1/cdc/replay", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			replayer.ReplayEvents(w, r)
+		}))
+		mux.HandleFunc("/api/v1/cdc/replay/{id}", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				replayer.GetReplay(w, r)
+			case http.MethodDelete:
+				replayer.CancelReplay(w, r)
+			default:
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			}
+		}))
+	}
+	if changeEvents.SchemaVersions != nil {
+		schemaVersionHandler := handlers.NewSchemaVersionHandler(ctx, changeEvents.SchemaVersions)
+		mux.HandleFunc("/api/v1/cdc/schemas", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			schemaVersionHandler.GetSchemaVersions(w, r)
+		}))
+	}
+}
+
+func (s *Server) registerDeadLetters(ctx context.Context, mux *http.ServeMux, dispatcher *cdc.Dispatcher) {
+	deadLetterHandler := handlers.NewDeadLetterHandler(ctx, service.NewDeadLetterService(repository.NewDeadLetterRepository(s.db), dispatcher))
+	mux.HandleFunc("/api/v1/cdc/dlq", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		deadLetterHandler.GetDeadLetters(w, r)
+	}))
+	mux.HandleFunc("/api/v1/cdc/dlq/{id}", logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		deadLetterHandler.GetDeadLetter(w, r)
+	}))
+	post := map[string]http.HandlerFunc{
+		"/api/v1/cdc/dlq/{id}/replay":  deadLetterHandler.Replay,
+		"/api/v1/cdc/dlq/{id}/discard": deadLetterHandler.Discard,
+		"/api/v1/cdc/dlq/replay":       deadLetterHandler.ReplayAll,
+		"/api/v1/cdc/dlq/discard":      deadLetterHandler.DiscardAll,
+	}
+	for path, handle := range post {
+		mux.HandleFunc(path, logger.LoggerMiddleware(ctx, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			handle(w, r)
+		}))
+	}
+}
+
 func (s *Server) Start() error {
 	return s.srv.ListenAndServe()
 }
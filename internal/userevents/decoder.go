@@ -0,0 +1,249 @@
+package userevents
+
+import (
+	"bytes"
+	"debez/internal/models"
+	"debez/pkg/cdc"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	Schema = "public"
+	Table  = "users"
+
+	// DefaultUnavailablePlaceholder is Debezium's unavailable.value.placeholder for unchanged TOAST columns.
+	DefaultUnavailablePlaceholder = cdc.UnavailablePlaceholder
+)
+
+// TimePrecision mirrors the connector's time.precision.mode. It is only used when
+// the event has no schema, otherwise the semantic type of the field decides.
+type TimePrecision int
+
+const (
+	// TimePrecisionAdaptive covers adaptive and adaptive_time_microseconds:
+	// TIMESTAMP columns are io.debezium.time.MicroTimestamp (microseconds since epoch).
+	TimePrecisionAdaptive TimePrecision = iota
+	// TimePrecisionConnect is connect: org.apache.kafka.connect.data.Timestamp (milliseconds since epoch).
+	TimePrecisionConnect
+)
+
+const (
+	microTimestamp   = "io.debezium.time.MicroTimestamp"
+	nanoTimestamp    = "io.debezium.time.NanoTimestamp"
+	milliTimestamp   = "io.debezium.time.Timestamp"
+	connectTimestamp = "org.apache.kafka.connect.data.Timestamp"
+)
+
+var ErrNotUsersEvent = errors.New("userevents: event is not for public.users")
+
+// Change is a users-table change event decoded into models.User.
+type Change struct {
+	Op          cdc.Op
+	Before      *models.User
+	After       *models.User
+	Source      cdc.Source
+	TsMs        int64
+	Transaction *cdc.Transaction
+	// Unavailable lists columns of After that held an unchanged TOAST placeholder
+	// and could not be filled from Before.
+	Unavailable []string
+}
+
+// User returns the state after the change, the old row for deletes.
+func (c Change) User() *models.User {
+	if c.Op == cdc.OpDelete {
+		return c.Before
+	}
+	return c.After
+}
+
+func (c Change) UserID() int64 {
+	if u := c.User(); u != nil {
+		return u.ID
+	}
+	return 0
+}
+
+type Decoder struct {
+	TimePrecision TimePrecision
+	Placeholder   string
+}
+
+func NewDecoder(precision TimePrecision) *Decoder {
+	return &Decoder{
+		TimePrecision: precision,
+		Placeholder:   DefaultUnavailablePlaceholder,
+	}
+}
+
+func IsUsersEvent(e cdc.Event) bool {
+	return e.Source.Table == Table && (e.Source.Schema == "" || e.Source.Schema == Schema)
+}
+
+// DecodeBytes decodes a raw Debezium message with or without the schema/payload wrapper.
+func (d *Decoder) DecodeBytes(data []byte) (Change, error) {
+	event, err := cdc.Decode[json.RawMessage](data)
+	if err != nil {
+		return Change{}, err
+	}
+	return d.Decode(event)
+}
+
+func (d *Decoder) Decode(e cdc.Event) (Change, error) {
+	if !IsUsersEvent(e) {
+		return Change{}, ErrNotUsersEvent
+	}
+	change := Change{
+		Op:          e.Op,
+		Source:      e.Source,
+		TsMs:        e.TsMs,
+		Transaction: e.Transaction,
+	}
+	rowSchema := e.Schema.RowSchema()
+
+	before, err := d.decodeRow(e.Before, rowSchema)
+	if err != nil {
+		return Change{}, fmt.Errorf("userevents.Decode before: %w", err)
+	}
+	after, err := d.decodeRow(e.After, rowSchema)
+	if err != nil {
+		return Change{}, fmt.Errorf("userevents.Decode after: %w", err)
+	}
+	change.Before, change.After = before.user, after.user
+	change.Unavailable = fillUnavailable(after, before)
+	return change, nil
+}
+
+type decodedRow struct {
+	user *models.User
+	// missing holds columns that were null, absent or an unchanged TOAST placeholder.
+	missing map[string]bool
+}
+
+func (d *Decoder) decodeRow(raw *json.RawMessage, schema *cdc.Schema) (decodedRow, error) {
+	if raw == nil || bytes.Equal(*raw, []byte("null")) {
+		return decodedRow{}, nil
+	}
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(*raw, &row); err != nil {
+		return decodedRow{}, err
+	}
+
+	var user models.User
+	missing := make(map[string]bool)
+	if _, err := decodeField(row, "id", &user.ID); err != nil {
+		return decodedRow{}, err
+	}
+	for column, dst := range map[string]*string{"email": &user.Email, "name": &user.Name, "last_name": &user.LastName} {
+		found, err := decodeField(row, column, dst)
+		if err != nil {
+			return decodedRow{}, err
+		}
+		if !found || d.isPlaceholder(*dst) {
+			*dst = ""
+			missing[column] = true
+		}
+	}
+	found, err := decodeField(row, "role", &user.Role)
+	if err != nil {
+		return decodedRow{}, err
+	}
+	if !found || slices.ContainsFunc(user.Role, d.isPlaceholder) {
+		user.Role = nil
+		missing["role"] = true
+	}
+	if user.CreatedAt, err = d.decodeTimestamp(row["created_at"], schema.FieldSchema("created_at")); err != nil {
+		return decodedRow{}, fmt.Errorf("created_at: %w", err)
+	}
+	if user.UpdatedAt, err = d.decodeTimestamp(row["updated_at"], schema.FieldSchema("updated_at")); err != nil {
+		return decodedRow{}, fmt.Errorf("updated_at: %w", err)
+	}
+	return decodedRow{user: &user, missing: missing}, nil
+}
+
+func (d *Decoder) isPlaceholder(value string) bool {
+	placeholder := d.Placeholder
+	if placeholder == "" {
+		placeholder = DefaultUnavailablePlaceholder
+	}
+	return value == placeholder
+}
+
+func decodeField(row map[string]json.RawMessage, column string, dst any) (bool, error) {
+	raw, ok := row[column]
+	if !ok || bytes.Equal(raw, []byte("null")) {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return false, fmt.Errorf("%s: %w", column, err)
+	}
+	return true, nil
+}
+
+// decodeTimestamp decodes a TIMESTAMP column. Values are wall clock time of the database
+// and are returned in UTC, the way Debezium encodes them.
+func (d *Decoder) decodeTimestamp(raw json.RawMessage, schema *cdc.Schema) (time.Time, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return time.Time{}, nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return time.Time{}, err
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	var v int64
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return time.Time{}, err
+	}
+
+	name := microTimestamp
+	if d.TimePrecision == TimePrecisionConnect {
+		name = connectTimestamp
+	}
+	if schema != nil && schema.Name != "" {
+		name = schema.Name
+	}
+	switch name {
+	case microTimestamp:
+		return time.UnixMicro(v).UTC(), nil
+	case nanoTimestamp:
+		return time.Unix(0, v).UTC(), nil
+	case milliTimestamp, connectTimestamp:
+		return time.UnixMilli(v).UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported timestamp type %q", name)
+	}
+}
+
+// fillUnavailable copies missing columns of the new row from the old one, which is complete
+// with REPLICA IDENTITY FULL, and returns the columns it could not fill.
+func fillUnavailable(after, before decodedRow) []string {
+	if after.user == nil {
+		return nil
+	}
+	var unavailable []string
+	for column := range after.missing {
+		if before.user == nil || before.missing[column] {
+			unavailable = append(unavailable, column)
+			continue
+		}
+		switch column {
+		case "email":
+			after.user.Email = before.user.Email
+		case "name":
+			after.user.Name = before.user.Name
+		case "last_name":
+			after.user.LastName = before.user.LastName
+		case "role":
+			after.user.Role = slices.Clone(before.user.Role)
+		}
+	}
+	slices.Sort(unavailable)
+	return unavailable
+}
@@ -0,0 +1,122 @@
+package userevents
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDecoderTimePrecisions(t *testing.T) {
+	want := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	tests := []struct {
+		name      string
+		precision TimePrecision
+		// schemaName is the semantic type of created_at, empty sends the event without schema.
+		schemaName string
+		value      string
+		want       time.Time
+	}{
+		{name: "adaptive without schema", precision: TimePrecisionAdaptive, value: "1714979289123456", want: want},
+		{name: "connect without schema", precision: TimePrecisionConnect, value: "1714979289123", want: want.Truncate(time.Millisecond)},
+		{name: "micro schema", precision: TimePrecisionConnect, schemaName: microTimestamp, value: "1714979289123456", want: want},
+		{name: "nano schema", precision: TimePrecisionAdaptive, schemaName: nanoTimestamp, value: "1714979289123456000", want: want},
+		{name: "milli schema", precision: TimePrecisionAdaptive, schemaName: milliTimestamp, value: "1714979289123", want: want.Truncate(time.Millisecond)},
+		{name: "connect schema", precision: TimePrecisionAdaptive, schemaName: connectTimestamp, value: "1714979289123", want: want.Truncate(time.Millisecond)},
+		{name: "ISO string", precision: TimePrecisionAdaptive, value: `"2024-05-06T07:08:09.123456Z"`, want: want},
+		{name: "null", precision: TimePrecisionAdaptive, value: "null", want: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := `{"before":null,"after":{"id":1,"email":"a@b.c","name":"A","last_name":"B","role":["user"],"created_at":` +
+				tt.value + `},"source":{"schema":"public","table":"users"},"op":"c"}`
+			data := payload
+			if tt.schemaName != "" {
+				data = `{"schema":{"type":"struct","fields":[{"type":"struct","field":"after","fields":[
+					{"type":"int64","field":"created_at","name":"` + tt.schemaName + `"}]}]},"payload":` + payload + `}`
+			}
+			change, err := NewDecoder(tt.precision).DecodeBytes([]byte(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := change.After.CreatedAt; !got.Equal(tt.want) {
+				t.Fatalf("created_at = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoderUnsupportedTimestamp(t *testing.T) {
+	data := `{"schema":{"type":"struct","fields":[{"type":"struct","field":"after","fields":[
+		{"type":"int32","field":"created_at","name":"io.debezium.time.Date"}]}]},
+		"payload":{"after":{"id":1,"created_at":19849},"source":{"table":"users"},"op":"c"}}`
+	if _, err := NewDecoder(TimePrecisionAdaptive).DecodeBytes([]byte(data)); err == nil {
+		t.Fatal("decoded a date column as a timestamp")
+	}
+}
+
+func TestDecoderFillsUnavailableColumns(t *testing.T) {
+	const placeholder = DefaultUnavailablePlaceholder
+	tests := []struct {
+		name            string
+		before          string
+		after           string
+		wantName        string
+		wantRole        []string
+		wantUnavailable []string
+	}{
+		{
+			name:     "filled from before",
+			before:   `{"id":1,"email":"a@b.c","name":"A","last_name":"B","role":["admin"]}`,
+			after:    `{"id":1,"email":"x@b.c","name":"` + placeholder + `","last_name":"B","role":["` + placeholder + `"]}`,
+			wantName: "A",
+			wantRole: []string{"admin"},
+		},
+		{
+			name:            "no before",
+			before:          `null`,
+			after:           `{"id":1,"email":"x@b.c","name":"` + placeholder + `","last_name":"B","role":["user"]}`,
+			wantRole:        []string{"user"},
+			wantUnavailable: []string{"name"},
+		},
+		{
+			name:            "missing in both",
+			before:          `{"id":1,"email":"a@b.c","last_name":"B"}`,
+			after:           `{"id":1,"email":"x@b.c","last_name":"B"}`,
+			wantUnavailable: []string{"name", "role"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"before":` + tt.before + `,"after":` + tt.after + `,"source":{"schema":"public","table":"users"},"op":"u"}`
+			change, err := NewDecoder(TimePrecisionAdaptive).DecodeBytes([]byte(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if change.After.Name != tt.wantName || !slices.Equal(change.After.Role, tt.wantRole) {
+				t.Fatalf("after = %+v, want name %q and role %v", change.After, tt.wantName, tt.wantRole)
+			}
+			if !slices.Equal(change.Unavailable, tt.wantUnavailable) {
+				t.Fatalf("unavailable = %v, want %v", change.Unavailable, tt.wantUnavailable)
+			}
+		})
+	}
+}
+
+func TestDecoderRejectsOtherTables(t *testing.T) {
+	data := `{"after":{"id":1},"source":{"schema":"public","table":"orders"},"op":"c"}`
+	if _, err := NewDecoder(TimePrecisionAdaptive).DecodeBytes([]byte(data)); !errors.Is(err, ErrNotUsersEvent) {
+		t.Fatalf("DecodeBytes = %v, want %v", err, ErrNotUsersEvent)
+	}
+}
+
+func TestChangeUser(t *testing.T) {
+	data := `{"before":{"id":7,"email":"a@b.c"},"after":null,"source":{"table":"users"},"op":"d"}`
+	change, err := NewDecoder(TimePrecisionAdaptive).DecodeBytes([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.UserID() != 7 {
+		t.Fatalf("user of a delete = %d, want the old row's 7", change.UserID())
+	}
+}
@@ -0,0 +1,50 @@
+package userevents
+
+import (
+	"debez/pkg/cdc"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// columns are the users columns the decoder reads and the schema types it can decode.
+var columns = map[string][]string{
+	"id":         {"int16", "int32", "int64"},
+	"email":      {"string"},
+	"name":       {"string"},
+	"last_name":  {"string"},
+	"role":       {"array"},
+	"created_at": {"int64", "string"},
+	"updated_at": {"int64", "string"},
+}
+
+var timestampNames = []string{"", microTimestamp, nanoTimestamp, milliTimestamp, connectTimestamp, "io.debezium.time.ZonedTimestamp"}
+
+// CheckSchema reports the columns of a users schema version the decoder would fail on or
+// lose. Columns the decoder does not know are ignored and not reported.
+func CheckSchema(schema cdc.TableSchema) []string {
+	var problems []string
+	for _, column := range slices.Sorted(maps.Keys(columns)) {
+		field := schema.Field(column)
+		switch {
+		case field == nil:
+			problems = append(problems, fmt.Sprintf("column %s was removed", column))
+		case !slices.Contains(columns[column], field.Type):
+			problems = append(problems, fmt.Sprintf("column %s has unsupported type %s", column, field.Type))
+		case (column == "created_at" || column == "updated_at") && !slices.Contains(timestampNames, field.Semantic):
+			problems = append(problems, fmt.Sprintf("column %s has unsupported timestamp type %s", column, field.Semantic))
+		}
+	}
+	return problems
+}
+
+// Unknown returns the columns of a users schema version the decoder does not read.
+func Unknown(schema cdc.TableSchema) []string {
+	var unknown []string
+	for _, f := range schema.Fields {
+		if _, ok := columns[f.Name]; !ok {
+			unknown = append(unknown, f.Name)
+		}
+	}
+	return unknown
+}
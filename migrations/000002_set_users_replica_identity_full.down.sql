@@ -0,0 +1,2 @@
+-- Only the primary key is logged for the old row
+ALTER TABLE users REPLICA IDENTITY DEFAULT;
@@ -0,0 +1,2 @@
+-- Log the whole old row so Debezium update and delete events for users carry a full "before" image
+ALTER TABLE users REPLICA IDENTITY FULL;
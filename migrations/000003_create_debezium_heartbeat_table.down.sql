@@ -0,0 +1,2 @@
+-- Drop heartbeat table
+DROP TABLE IF EXISTS debezium_heartbeat;
@@ -0,0 +1,9 @@
+-- Single-row table written on an interval so the replication slot keeps advancing
+-- even when the captured tables are quiet
+CREATE TABLE IF NOT EXISTS debezium_heartbeat (
+    id INT PRIMARY KEY,
+    ts TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO debezium_heartbeat (id, ts) VALUES (1, NOW())
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,5 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_connector_events_connector_occurred_at;
+
+-- Drop connector events table
+DROP TABLE IF EXISTS connector_events;
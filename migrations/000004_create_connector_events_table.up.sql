@@ -0,0 +1,16 @@
+-- Journal of connector state changes observed by the service
+CREATE TABLE IF NOT EXISTS connector_events (
+    id BIGSERIAL PRIMARY KEY,
+    connector VARCHAR(255) NOT NULL,
+    task_id INT,
+    event_type VARCHAR(64) NOT NULL,
+    state VARCHAR(64) NOT NULL DEFAULT '',
+    previous_state VARCHAR(64) NOT NULL DEFAULT '',
+    worker_id VARCHAR(255) NOT NULL DEFAULT '',
+    trace TEXT NOT NULL DEFAULT '',
+    config JSONB,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Create index for per-connector time range queries
+CREATE INDEX IF NOT EXISTS idx_connector_events_connector_occurred_at ON connector_events(connector, occurred_at);
@@ -0,0 +1,5 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_processed_events_processed_at;
+
+-- Drop processed events table
+DROP TABLE IF EXISTS processed_events;
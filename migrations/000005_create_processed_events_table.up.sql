@@ -0,0 +1,13 @@
+-- Change events already processed by a handler, used to skip redelivered events
+CREATE TABLE IF NOT EXISTS processed_events (
+    handler VARCHAR(255) NOT NULL,
+    lsn BIGINT NOT NULL,
+    tx_id BIGINT NOT NULL,
+    schema_name VARCHAR(255) NOT NULL,
+    table_name VARCHAR(255) NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (handler, lsn, tx_id, schema_name, table_name)
+);
+
+-- Create index for pruning old entries
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events(processed_at);
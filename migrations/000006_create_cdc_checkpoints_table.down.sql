@@ -0,0 +1,2 @@
+-- Drop checkpoints table
+DROP TABLE IF EXISTS cdc_checkpoints;
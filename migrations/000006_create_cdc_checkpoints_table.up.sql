@@ -0,0 +1,8 @@
+-- Positions change event consumers have fully handled, per source and partition
+CREATE TABLE IF NOT EXISTS cdc_checkpoints (
+    source VARCHAR(255) NOT NULL,
+    partition_name VARCHAR(255) NOT NULL,
+    position VARCHAR(255) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source, partition_name)
+);
@@ -0,0 +1,10 @@
+-- Domain events published by Debezium's outbox EventRouter, column names are its defaults.
+-- Rows are deleted in the transaction that inserts them, Debezium reads the inserts from the WAL
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    aggregatetype VARCHAR(255) NOT NULL,
+    aggregateid VARCHAR(255) NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_user_history_user_id_committed_at;
+DROP INDEX IF EXISTS idx_user_history_user_id_lsn;
+
+-- Drop user history table
+DROP TABLE IF EXISTS user_history;
@@ -0,0 +1,19 @@
+-- Change history of users captured from change events
+CREATE TABLE IF NOT EXISTS user_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    op VARCHAR(1) NOT NULL,
+    before JSONB,
+    after JSONB,
+    changed_fields TEXT[] NOT NULL DEFAULT '{}',
+    lsn BIGINT NOT NULL,
+    tx_id BIGINT,
+    committed_at TIMESTAMPTZ NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A change is recorded once even when its event is redelivered
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_history_user_id_lsn ON user_history(user_id, lsn);
+
+-- Create index for history and point-in-time lookups
+CREATE INDEX IF NOT EXISTS idx_user_history_user_id_committed_at ON user_history(user_id, committed_at);
@@ -0,0 +1,10 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_webhook_attempts_attempted_at;
+DROP INDEX IF EXISTS idx_webhook_attempts_subscription_id;
+DROP INDEX IF EXISTS idx_webhook_deliveries_delivered_at;
+DROP INDEX IF EXISTS idx_webhook_deliveries_pending;
+
+-- Drop webhook tables
+DROP TABLE IF EXISTS webhook_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
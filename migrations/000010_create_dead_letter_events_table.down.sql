@@ -0,0 +1,5 @@
+-- Drop index
+DROP INDEX IF EXISTS idx_dead_letter_events_status_handler;
+
+-- Drop dead letter events table
+DROP TABLE IF EXISTS dead_letter_events;
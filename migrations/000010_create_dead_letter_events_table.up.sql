@@ -0,0 +1,21 @@
+-- Change events a handler kept failing on
+CREATE TABLE IF NOT EXISTS dead_letter_events (
+    id BIGSERIAL PRIMARY KEY,
+    handler VARCHAR(255) NOT NULL,
+    schema_name VARCHAR(255) NOT NULL,
+    table_name VARCHAR(255) NOT NULL,
+    op VARCHAR(1) NOT NULL,
+    lsn BIGINT,
+    event JSONB NOT NULL,
+    event_schema JSONB,
+    -- Every event of a failed transaction of several events, event is the last one
+    tx JSONB,
+    error TEXT NOT NULL,
+    attempts INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+
+-- Create index for listing entries
+CREATE INDEX IF NOT EXISTS idx_dead_letter_events_status_handler ON dead_letter_events(status, handler, id);
@@ -0,0 +1,5 @@
+-- Drop indexes
+DROP INDEX IF EXISTS idx_cdc_schema_versions_table_version;
+
+-- Drop schema versions table
+DROP TABLE IF EXISTS cdc_schema_versions;
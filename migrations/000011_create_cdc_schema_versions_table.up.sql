@@ -0,0 +1,13 @@
+-- Versions of the row schemas of captured tables, detected from change events
+CREATE TABLE IF NOT EXISTS cdc_schema_versions (
+    id BIGSERIAL PRIMARY KEY,
+    schema_name VARCHAR(255) NOT NULL,
+    table_name VARCHAR(255) NOT NULL,
+    version INT NOT NULL,
+    fields JSONB NOT NULL,
+    fingerprint VARCHAR(64) NOT NULL,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A version is saved once even when two consumers detect it
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cdc_schema_versions_table_version ON cdc_schema_versions(schema_name, table_name, version);
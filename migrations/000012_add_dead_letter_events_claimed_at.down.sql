@@ -0,0 +1,3 @@
+-- Release running replays and drop the claim time
+UPDATE dead_letter_events SET status = 'pending' WHERE status = 'replaying';
+ALTER TABLE dead_letter_events DROP COLUMN IF EXISTS claimed_at;
@@ -0,0 +1,2 @@
+-- Time a replay claimed the entry, stale claims are taken over
+ALTER TABLE dead_letter_events ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ;
@@ -0,0 +1,156 @@
+package cdc
+
+import (
+	"context"
+	"debez/pkg/logger"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Checkpoint is the position a consumer has fully handled in one partition of a source:
+// an LSN for a replication slot, an offset for a Kafka partition.
+type Checkpoint struct {
+	Source    string    `json:"source"`
+	Partition string    `json:"partition"`
+	Position  string    `json:"position"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+}
+
+type checkpointKey struct {
+	source    string
+	partition string
+}
+
+func (c Checkpoint) key() checkpointKey {
+	return checkpointKey{source: c.Source, partition: c.Partition}
+}
+
+type CheckpointStore interface {
+	// Load returns the stored checkpoint, ok is false when there is none.
+	Load(ctx context.Context, source, partition string) (cp Checkpoint, ok bool, err error)
+	Save(ctx context.Context, checkpoints ...Checkpoint) error
+}
+
+// MemoryCheckpointStore keeps checkpoints in memory, for tests and consumers that
+// do not need to survive a restart.
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[checkpointKey]Checkpoint
+}
+
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[checkpointKey]Checkpoint)}
+}
+
+func (s *MemoryCheckpointStore) Load(_ context.Context, source, partition string) (Checkpoint, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.checkpoints[checkpointKey{source: source, partition: partition}]
+	return cp, ok, nil
+}
+
+func (s *MemoryCheckpointStore) Save(_ context.Context, checkpoints ...Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cp := range checkpoints {
+		s.checkpoints[cp.key()] = cp
+	}
+	return nil
+}
+
+// Checkpointer batches checkpoint updates. Consumers Mark every handled position and the
+// latest one per partition is saved every interval, so the store is not hit per event.
+// Positions marked after the last flush are redelivered after a crash.
+type Checkpointer struct {
+	store    CheckpointStore
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[checkpointKey]Checkpoint
+}
+
+const defaultCheckpointInterval = 5 * time.Second
+
+// NewCheckpointer saves every interval, every 5 seconds when it is not positive.
+func NewCheckpointer(store CheckpointStore, interval time.Duration) *Checkpointer {
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+	return &Checkpointer{
+		store:    store,
+		interval: interval,
+		pending:  make(map[checkpointKey]Checkpoint),
+	}
+}
+
+// Load returns the position to resume from, a pending one wins over the stored one.
+func (c *Checkpointer) Load(ctx context.Context, source, partition string) (Checkpoint, bool, error) {
+	c.mu.Lock()
+	cp, ok := c.pending[checkpointKey{source: source, partition: partition}]
+	c.mu.Unlock()
+	if ok {
+		return cp, true, nil
+	}
+	return c.store.Load(ctx, source, partition)
+}
+
+// Mark records a handled position. Positions must be marked in order per partition.
+func (c *Checkpointer) Mark(cp Checkpoint) {
+	if cp.UpdatedAt.IsZero() {
+		cp.UpdatedAt = time.Now().UTC()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[cp.key()] = cp
+}
+
+// Flush saves the pending checkpoints. They stay pending when the store fails.
+func (c *Checkpointer) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	batch := make([]Checkpoint, 0, len(c.pending))
+	for _, cp := range c.pending {
+		batch = append(batch, cp)
+	}
+	clear(c.pending)
+	c.mu.Unlock()
+
+	if err := c.store.Save(ctx, batch...); err != nil {
+		c.mu.Lock()
+		for _, cp := range batch {
+			// A newer position may have been marked while saving.
+			if _, ok := c.pending[cp.key()]; !ok {
+				c.pending[cp.key()] = cp
+			}
+		}
+		c.mu.Unlock()
+		return fmt.Errorf("cdc: save checkpoints: %w", err)
+	}
+	return nil
+}
+
+// Run flushes every interval until ctx is done and once more on the way out.
+// Failed flushes are logged and retried on the next tick.
+func (c *Checkpointer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.Flush(context.WithoutCancel(ctx)); err != nil {
+				logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to flush checkpoints", zap.Error(err))
+			}
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil && ctx.Err() == nil {
+				logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to flush checkpoints", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DeadLetter is an event a handler failed on. Tx is set when the handler failed on a
+// transaction of several events, Event is then its last one.
+type DeadLetter struct {
+	Handler  string
+	Event    Event
+	Tx       *Tx
+	Error    string
+	Attempts int
+}
+
+type DeadLetterStore interface {
+	Put(ctx context.Context, letter DeadLetter) error
+}
+
+type replayKey struct{}
+
+// WithReplay marks ctx as replaying a dead letter, failures are then returned instead of
+// being dead-lettered again.
+func WithReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayKey{}, true)
+}
+
+func IsReplay(ctx context.Context) bool {
+	replay, _ := ctx.Value(replayKey{}).(bool)
+	return replay
+}
+
+// DeadLetters stores events whose handler failed and reports them as handled, so a
+// failing handler does not stop the stream. Put it outside Retry, the stored attempt
+// count comes from its RetryError. The event is only acknowledged when it was stored.
+// A failed transaction of several events is stored as one dead letter holding all of them.
+func DeadLetters(store DeadLetterStore) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, e Event) error {
+			err := next.Handle(ctx, e)
+			if err == nil || IsReplay(ctx) || ctx.Err() != nil {
+				return err
+			}
+			letter := DeadLetter{Handler: HandlerName(ctx), Event: e, Error: err.Error(), Attempts: 1}
+			if tx, ok := TxFromContext(ctx); ok && len(tx.Events) > 1 {
+				letter.Tx = &tx
+			}
+			var retryErr *RetryError
+			if errors.As(err, &retryErr) {
+				letter.Attempts = retryErr.Attempts
+				letter.Error = retryErr.Err.Error()
+			}
+			if putErr := store.Put(ctx, letter); putErr != nil {
+				return errors.Join(err, fmt.Errorf("cdc: dead letter: %w", putErr))
+			}
+			return nil
+		})
+	}
+}
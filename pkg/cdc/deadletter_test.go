@@ -0,0 +1,82 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+type memoryDeadLetters struct {
+	letters []DeadLetter
+}
+
+func (m *memoryDeadLetters) Put(_ context.Context, letter DeadLetter) error {
+	m.letters = append(m.letters, letter)
+	return nil
+}
+
+func txLSNs(tx Tx) []uint64 {
+	lsns := make([]uint64, 0, len(tx.Events))
+	for _, e := range tx.Events {
+		lsn, _ := e.Source.LSN()
+		lsns = append(lsns, uint64(lsn))
+	}
+	return lsns
+}
+
+func TestDeadLettersStoreWholeTransaction(t *testing.T) {
+	var handled []Tx
+	fail := true
+	d := NewDispatcher()
+	store := &memoryDeadLetters{}
+	d.Use(DeadLetters(store))
+	d.RegisterTx("tx", Route{Ops: []Op{OpRead}}, TxHandlerFunc(func(_ context.Context, tx Tx) error {
+		handled = append(handled, tx)
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+
+	tx := Tx{ID: "1", Events: []Event{lsnEvent(1), lsnEvent(2), lsnEvent(3)}}
+	for i := range tx.Events {
+		tx.Events[i].Op = OpRead
+	}
+	if err := d.HandleTx(context.Background(), tx); err != nil {
+		t.Fatalf("HandleTx = %v, want the transaction dead-lettered", err)
+	}
+	if len(store.letters) != 1 || store.letters[0].Tx == nil {
+		t.Fatalf("dead letters = %+v, want one holding the transaction", store.letters)
+	}
+	letter := store.letters[0]
+	if got := txLSNs(*letter.Tx); !slices.Equal(got, []uint64{1, 2, 3}) {
+		t.Fatalf("dead-lettered %v, want [1 2 3]", got)
+	}
+
+	fail = false
+	if err := d.InvokeTx(WithReplay(context.Background()), letter.Handler, *letter.Tx); err != nil {
+		t.Fatal(err)
+	}
+	if got := txLSNs(handled[len(handled)-1]); !slices.Equal(got, []uint64{1, 2, 3}) {
+		t.Fatalf("replayed %v, want [1 2 3]", got)
+	}
+}
+
+func TestDeadLettersStoreSingleEventWithoutTransaction(t *testing.T) {
+	d := NewDispatcher()
+	store := &memoryDeadLetters{}
+	d.Use(DeadLetters(store))
+	d.RegisterTx("tx", Route{Ops: []Op{OpRead}}, TxHandlerFunc(func(context.Context, Tx) error {
+		return errors.New("failed")
+	}))
+
+	e := lsnEvent(1)
+	e.Op = OpRead
+	if err := d.Handle(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.letters) != 1 || store.letters[0].Tx != nil {
+		t.Fatalf("dead letters = %+v, want one event without a transaction", store.letters)
+	}
+}
@@ -0,0 +1,50 @@
+package cdc
+
+import "context"
+
+// EventKey identifies a change event of a Postgres source.
+type EventKey struct {
+	LSN    LSN
+	TxID   int64
+	Schema string
+	Table  string
+}
+
+// KeyOf returns the deduplication key of e. Snapshot reads share the LSN of the snapshot
+// and events without an LSN cannot be told apart, neither has a key.
+func KeyOf(e Event) (EventKey, bool) {
+	if e.IsSnapshot() {
+		return EventKey{}, false
+	}
+	lsn, ok := e.Source.LSN()
+	if !ok || lsn == 0 {
+		return EventKey{}, false
+	}
+	txID, _ := e.Source.TxID()
+	return EventKey{LSN: lsn, TxID: txID, Schema: e.Source.Schema, Table: e.Source.Table}, true
+}
+
+// ProcessedStore remembers which handler processed which event.
+type ProcessedStore interface {
+	// RunOnce calls fn unless handler already processed key. The key is marked processed in the
+	// same unit of work as fn's own writes, which fn reaches through the context it is given.
+	RunOnce(ctx context.Context, handler string, key EventKey, fn func(ctx context.Context) error) (bool, error)
+}
+
+// Idempotent turns redelivered events into no-ops. Events without a key are always handled.
+// A transaction handler is keyed on the last event of its transaction: a redelivered
+// transaction is skipped as a whole, but only when it is grouped into the same parts again.
+func Idempotent(store ProcessedStore) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, e Event) error {
+			key, ok := KeyOf(e)
+			if !ok {
+				return next.Handle(ctx, e)
+			}
+			_, err := store.RunOnce(ctx, HandlerName(ctx), key, func(ctx context.Context) error {
+				return next.Handle(ctx, e)
+			})
+			return err
+		})
+	}
+}
@@ -0,0 +1,515 @@
+package cdc
+
+import (
+	"context"
+	"debez/pkg/logger"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Any matches every schema or table in a Route.
+const Any = "*"
+
+// Route selects the events a handler receives. Empty Schema or Table match anything.
+// Empty Ops match live changes (create, update, delete, truncate) but not snapshot reads,
+// those only go to routes that list OpRead, see Dispatcher.RegisterSnapshot.
+type Route struct {
+	Schema string
+	Table  string
+	Ops    []Op
+}
+
+var liveOps = []Op{OpCreate, OpUpdate, OpDelete, OpTruncate}
+
+func (r Route) matches(e Event) bool {
+	if r.Schema != "" && r.Schema != Any && r.Schema != e.Source.Schema {
+		return false
+	}
+	if r.Table != "" && r.Table != Any && r.Table != e.Source.Table {
+		return false
+	}
+	ops := r.Ops
+	if len(ops) == 0 {
+		ops = liveOps
+	}
+	return slices.Contains(ops, e.Op)
+}
+
+// matchesTable reports whether the route selects any events of the table.
+func (r Route) matchesTable(schema, table string) bool {
+	return (r.Schema == "" || r.Schema == Any || r.Schema == schema) &&
+		(r.Table == "" || r.Table == Any || r.Table == table)
+}
+
+// Middleware wraps a handler. The name of the wrapped handler is available through HandlerName.
+type Middleware func(next Handler) Handler
+
+type registration struct {
+	name    string
+	route   Route
+	handler Handler
+	// tx is set for handlers registered with RegisterTx, handler then calls it with the
+	// transaction stored in the context.
+	tx bool
+}
+
+const (
+	// defaultMaxTxEvents bounds a buffered transaction when GroupTransactions gets no limit.
+	defaultMaxTxEvents = 10000
+	// defaultTxTimeout is how long a transaction is buffered when GroupTransactions gets no timeout.
+	defaultTxTimeout = time.Minute
+)
+
+// Dispatcher routes events to handlers registered by (schema, table, op). It is a Handler
+// itself, so the HTTP sink, the in-process replication source or a Kafka consumer
+// can all feed the same registry.
+type Dispatcher struct {
+	mu            sync.RWMutex
+	registrations []registration
+	middlewares   []Middleware
+
+	// dispatchMu serializes dispatching grouped transactions between the source and RunTxTimeouts.
+	dispatchMu  sync.Mutex
+	txMu        sync.Mutex
+	maxTxEvents int
+	txTimeout   time.Duration
+	pending     map[string]*pendingTx
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// GroupTransactions makes the dispatcher buffer events that carry transaction metadata
+// until the END event of their transaction and dispatch the transaction as a whole, so
+// no handler sees half of it. Sources must pass BEGIN and END through HandleTransaction.
+// A transaction of more than maxEvents events is dispatched in parts marked Tx.Partial,
+// so is one still incomplete after timeout, see RunTxTimeouts.
+// Only the tables some handler is registered for are buffered and counted against END.
+// Buffered events are acknowledged to the source before they are handled: use it with
+// sources that resume from the last transaction they confirmed, like pgoutput.
+func (d *Dispatcher) GroupTransactions(maxEvents int, timeout time.Duration) {
+	if maxEvents <= 0 {
+		maxEvents = defaultMaxTxEvents
+	}
+	if timeout <= 0 {
+		timeout = defaultTxTimeout
+	}
+	d.txMu.Lock()
+	defer d.txMu.Unlock()
+	d.maxTxEvents = maxEvents
+	d.txTimeout = timeout
+	d.pending = make(map[string]*pendingTx)
+}
+
+// Use appends middlewares. The first one is the outermost.
+func (d *Dispatcher) Use(middlewares ...Middleware) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.middlewares = append(d.middlewares, middlewares...)
+}
+
+// Register adds a handler. Names must be unique, they identify the handler in logs, metrics and retries.
+func (d *Dispatcher) Register(name string, route Route, h Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, r := range d.registrations {
+		if r.name == name {
+			panic(fmt.Sprintf("cdc: handler %q registered twice", name))
+		}
+	}
+	d.registrations = append(d.registrations, registration{name: name, route: route, handler: h})
+}
+
+func (d *Dispatcher) RegisterFunc(name string, route Route, f func(ctx context.Context, e Event) error) {
+	d.Register(name, route, HandlerFunc(f))
+}
+
+// RegisterTx adds a handler that receives the events of a transaction matching route in
+// one call. Middlewares see the last of those events, the whole transaction is in
+// TxFromContext. Middlewares keyed on the event, like Idempotent, therefore key the
+// transaction, or each part of a partial one, on its last event. Without GroupTransactions
+// every event is a transaction of its own.
+func (d *Dispatcher) RegisterTx(name string, route Route, h TxHandler) {
+	d.Register(name, route, HandlerFunc(func(ctx context.Context, e Event) error {
+		tx, ok := TxFromContext(ctx)
+		if !ok {
+			tx = Tx{Events: []Event{e}}
+		}
+		return h.HandleTx(ctx, tx)
+	}))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.registrations[len(d.registrations)-1].tx = true
+}
+
+// RegisterSnapshot adds a handler for snapshot reads of a table.
+func (d *Dispatcher) RegisterSnapshot(name, schema, table string, h Handler) {
+	d.Register(name, Route{Schema: schema, Table: table, Ops: []Op{OpRead}}, h)
+}
+
+// Handle passes e to every matching handler in registration order. All handlers run
+// even when one fails, the returned error joins the failures. With GroupTransactions,
+// events of a transaction are buffered and dispatched when it is complete.
+func (d *Dispatcher) Handle(ctx context.Context, e Event) error {
+	if e.Transaction != nil && e.Transaction.ID != "" && d.routed(e.Source.Schema, e.Source.Table) {
+		if buffered, err := d.bufferAndDispatch(ctx, e); buffered {
+			return err
+		}
+	}
+	return d.HandleTx(ctx, Tx{Events: []Event{e}})
+}
+
+func (d *Dispatcher) handleTxs(ctx context.Context, txs []*Tx) error {
+	var errs []error
+	for _, tx := range txs {
+		if err := d.HandleTx(ctx, *tx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// routed reports whether any handler is registered for events of the table.
+func (d *Dispatcher) routed(schema, table string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, r := range d.registrations {
+		if r.route.matchesTable(schema, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedEvents is the number of events of the routed tables in an END marker. Markers
+// without data collections only have the total.
+func (d *Dispatcher) expectedEvents(m TransactionEvent) int64 {
+	if len(m.DataCollections) == 0 {
+		return m.EventCount
+	}
+	var expected int64
+	for _, c := range m.DataCollections {
+		schema, table, _ := strings.Cut(c.DataCollection, ".")
+		if d.routed(schema, table) {
+			expected += c.EventCount
+		}
+	}
+	return expected
+}
+
+// HandleTx dispatches the events of a transaction. Event handlers get every matching
+// event in order, transaction handlers the matching events at once.
+func (d *Dispatcher) HandleTx(ctx context.Context, tx Tx) error {
+	d.mu.RLock()
+	registrations := d.registrations
+	middlewares := d.middlewares
+	d.mu.RUnlock()
+
+	var errs []error
+	for _, r := range registrations {
+		if r.tx {
+			part := Tx{ID: tx.ID, Partial: tx.Partial}
+			for _, e := range tx.Events {
+				if r.route.matches(e) {
+					part.Events = append(part.Events, e)
+				}
+			}
+			if len(part.Events) == 0 {
+				continue
+			}
+			if err := d.invoke(WithTx(ctx, part), r, middlewares, part.Events[len(part.Events)-1]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", r.name, err))
+			}
+			continue
+		}
+		for _, e := range tx.Events {
+			if !r.route.matches(e) {
+				continue
+			}
+			if err := d.invoke(ctx, r, middlewares, e); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", r.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HandleTransaction tracks BEGIN and END of buffered transactions and dispatches a
+// transaction when END arrives after all its events. It does nothing without GroupTransactions.
+func (d *Dispatcher) HandleTransaction(ctx context.Context, m TransactionEvent) error {
+	var expected int64
+	if m.Status == TxEnd {
+		expected = d.expectedEvents(m)
+	}
+	d.dispatchMu.Lock()
+	defer d.dispatchMu.Unlock()
+	d.txMu.Lock()
+	if d.pending == nil {
+		d.txMu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	txs := d.stale(now)
+	switch m.Status {
+	case TxBegin:
+		// A source redelivering a transaction starts it over.
+		d.pending[m.ID] = &pendingTx{started: now}
+	case TxEnd:
+		p := d.pending[m.ID]
+		if p == nil {
+			// END may overtake the events when they come from different topics.
+			p = &pendingTx{started: now}
+			d.pending[m.ID] = p
+		}
+		p.expected = expected
+		if expected == 0 {
+			// No routed events: whatever was buffered goes out as is.
+			if len(p.events) > 0 {
+				txs = append(txs, d.take(m.ID, p))
+			}
+			delete(d.pending, m.ID)
+		} else if p.complete() {
+			txs = append(txs, d.take(m.ID, p))
+		}
+	}
+	d.txMu.Unlock()
+	return d.handleTxs(ctx, txs)
+}
+
+func (d *Dispatcher) bufferAndDispatch(ctx context.Context, e Event) (bool, error) {
+	d.dispatchMu.Lock()
+	defer d.dispatchMu.Unlock()
+	txs, buffered := d.buffer(e)
+	if !buffered {
+		return false, nil
+	}
+	return true, d.handleTxs(ctx, txs)
+}
+
+// buffer adds e to its pending transaction and returns the transactions, or parts of them,
+// that are ready to dispatch. buffered is false when transactions are not grouped.
+func (d *Dispatcher) buffer(e Event) (txs []*Tx, buffered bool) {
+	d.txMu.Lock()
+	defer d.txMu.Unlock()
+	if d.pending == nil {
+		return nil, false
+	}
+	now := time.Now()
+	txs = d.stale(now)
+	id := e.Transaction.ID
+	p := d.pending[id]
+	if p == nil {
+		p = &pendingTx{started: now}
+		d.pending[id] = p
+	}
+	p.events = append(p.events, e)
+	switch {
+	case p.complete():
+		txs = append(txs, d.take(id, p))
+	case len(p.events) >= d.maxTxEvents:
+		txs = append(txs, d.part(id, p))
+	}
+	return txs, true
+}
+
+// RunTxTimeouts dispatches the buffered events of transactions older than the GroupTransactions
+// timeout until ctx is done, so they are not held back until the next event arrives.
+// It returns at once without GroupTransactions.
+func (d *Dispatcher) RunTxTimeouts(ctx context.Context) {
+	d.txMu.Lock()
+	timeout := d.txTimeout
+	d.txMu.Unlock()
+	if timeout <= 0 {
+		return
+	}
+	// Checking twice per timeout dispatches a transaction at most half a timeout late.
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.flushStale(ctx); err != nil && ctx.Err() == nil {
+				logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to dispatch timed out transactions", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) flushStale(ctx context.Context) error {
+	d.dispatchMu.Lock()
+	defer d.dispatchMu.Unlock()
+	d.txMu.Lock()
+	txs := d.stale(time.Now())
+	d.txMu.Unlock()
+	return d.handleTxs(ctx, txs)
+}
+
+// FlushTransactions dispatches the events of every buffered transaction as parts, for when
+// no more events will arrive, like at the end of a replay.
+func (d *Dispatcher) FlushTransactions(ctx context.Context) error {
+	d.dispatchMu.Lock()
+	defer d.dispatchMu.Unlock()
+	d.txMu.Lock()
+	var txs []*Tx
+	for id, p := range d.pending {
+		if len(p.events) > 0 {
+			txs = append(txs, d.part(id, p))
+		}
+		delete(d.pending, id)
+	}
+	d.txMu.Unlock()
+	return d.handleTxs(ctx, txs)
+}
+
+// stale returns the buffered events of transactions older than the timeout as parts and
+// forgets transactions that stay empty, e.g. because their END never came.
+func (d *Dispatcher) stale(now time.Time) []*Tx {
+	var txs []*Tx
+	for id, p := range d.pending {
+		if now.Sub(p.started) < d.txTimeout {
+			continue
+		}
+		if len(p.events) > 0 {
+			txs = append(txs, d.part(id, p))
+		}
+		delete(d.pending, id)
+	}
+	return txs
+}
+
+func (d *Dispatcher) part(id string, p *pendingTx) *Tx {
+	part := &Tx{ID: id, Events: p.events, Partial: true}
+	p.delivered += int64(len(p.events))
+	p.events = nil
+	return part
+}
+
+func (d *Dispatcher) take(id string, p *pendingTx) *Tx {
+	delete(d.pending, id)
+	return &Tx{ID: id, Events: p.events, Partial: p.delivered > 0}
+}
+
+// AckAfter calls ack once every registered handler that is an Acknowledger, like a sink
+// batcher, finished the events it was given, so sources confirm only what they persisted.
+func (d *Dispatcher) AckAfter(ack func()) {
+	d.mu.RLock()
+	var ackers []Acknowledger
+	for _, r := range d.registrations {
+		if a, ok := r.handler.(Acknowledger); ok {
+			ackers = append(ackers, a)
+		}
+	}
+	d.mu.RUnlock()
+	if len(ackers) == 0 {
+		ack()
+		return
+	}
+	var remaining atomic.Int32
+	remaining.Store(int32(len(ackers)))
+	for _, a := range ackers {
+		a.AckAfter(func() {
+			if remaining.Add(-1) == 0 {
+				ack()
+			}
+		})
+	}
+}
+
+// Select returns a dispatcher of the named handlers only, with the same middlewares and
+// transaction grouping, e.g. to replay events without the handlers that reach other systems.
+// Its transactions are buffered apart from d's.
+func (d *Dispatcher) Select(names ...string) (*Dispatcher, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	selected := &Dispatcher{middlewares: slices.Clone(d.middlewares)}
+	for _, name := range names {
+		i := slices.IndexFunc(d.registrations, func(r registration) bool { return r.name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("cdc: handler %q is not registered", name)
+		}
+		if !slices.ContainsFunc(selected.registrations, func(r registration) bool { return r.name == name }) {
+			selected.registrations = append(selected.registrations, d.registrations[i])
+		}
+	}
+	d.txMu.Lock()
+	defer d.txMu.Unlock()
+	if d.pending != nil {
+		selected.maxTxEvents = d.maxTxEvents
+		selected.txTimeout = d.txTimeout
+		selected.pending = make(map[string]*pendingTx)
+	}
+	return selected, nil
+}
+
+// Invoke runs a single registered handler with the middleware chain, ignoring its route.
+func (d *Dispatcher) Invoke(ctx context.Context, name string, e Event) error {
+	d.mu.RLock()
+	registrations := d.registrations
+	middlewares := d.middlewares
+	d.mu.RUnlock()
+
+	for _, r := range registrations {
+		if r.name == name {
+			return d.invoke(ctx, r, middlewares, e)
+		}
+	}
+	return fmt.Errorf("cdc: handler %q is not registered", name)
+}
+
+// InvokeTx runs a single registered handler with the middleware chain on a transaction, in
+// one call for a transaction handler and event by event for the others.
+func (d *Dispatcher) InvokeTx(ctx context.Context, name string, tx Tx) error {
+	d.mu.RLock()
+	registrations := d.registrations
+	middlewares := d.middlewares
+	d.mu.RUnlock()
+
+	for _, r := range registrations {
+		if r.name != name {
+			continue
+		}
+		if len(tx.Events) == 0 {
+			return nil
+		}
+		if r.tx {
+			return d.invoke(WithTx(ctx, tx), r, middlewares, tx.Events[len(tx.Events)-1])
+		}
+		for _, e := range tx.Events {
+			if err := d.invoke(ctx, r, middlewares, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("cdc: handler %q is not registered", name)
+}
+
+func (d *Dispatcher) invoke(ctx context.Context, r registration, middlewares []Middleware, e Event) error {
+	h := r.handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h.Handle(WithHandlerName(ctx, r.name), e)
+}
+
+type handlerNameKey struct{}
+
+func WithHandlerName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, handlerNameKey{}, name)
+}
+
+// HandlerName returns the name of the registered handler being invoked.
+func HandlerName(ctx context.Context) string {
+	name, _ := ctx.Value(handlerNameKey{}).(string)
+	return name
+}
@@ -0,0 +1,187 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func routedEvent(schema, table string, op Op) Event {
+	return Event{Op: op, Source: Source{Schema: schema, Table: table}}
+}
+
+func TestRouteMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Route
+		event Event
+		want  bool
+	}{
+		{name: "empty route, live change", route: Route{}, event: routedEvent("public", "users", OpUpdate), want: true},
+		{name: "empty route, snapshot read", route: Route{}, event: routedEvent("public", "users", OpRead), want: false},
+		{name: "table", route: Route{Schema: "public", Table: "users"}, event: routedEvent("public", "users", OpDelete), want: true},
+		{name: "other table", route: Route{Schema: "public", Table: "users"}, event: routedEvent("public", "orders", OpCreate), want: false},
+		{name: "other schema", route: Route{Schema: "public", Table: "users"}, event: routedEvent("audit", "users", OpCreate), want: false},
+		{name: "any schema", route: Route{Schema: Any, Table: "users"}, event: routedEvent("audit", "users", OpCreate), want: true},
+		{name: "any table", route: Route{Schema: "public", Table: Any}, event: routedEvent("public", "orders", OpTruncate), want: true},
+		{name: "listed op", route: Route{Ops: []Op{OpCreate, OpRead}}, event: routedEvent("public", "users", OpRead), want: true},
+		{name: "unlisted op", route: Route{Ops: []Op{OpCreate}}, event: routedEvent("public", "users", OpUpdate), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.route.matches(tt.event); got != tt.want {
+				t.Fatalf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcherRoutesToMatchingHandlers(t *testing.T) {
+	d := NewDispatcher()
+	var calls []string
+	record := func(name string) func(context.Context, Event) error {
+		return func(context.Context, Event) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	d.RegisterFunc("users", Route{Schema: "public", Table: "users"}, record("users"))
+	d.RegisterFunc("all", Route{}, record("all"))
+	d.RegisterSnapshot("users-snapshot", "public", "users", HandlerFunc(record("users-snapshot")))
+
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{name: "users change", event: routedEvent("public", "users", OpCreate), want: []string{"users", "all"}},
+		{name: "other table", event: routedEvent("public", "orders", OpCreate), want: []string{"all"}},
+		{name: "users snapshot", event: routedEvent("public", "users", OpRead), want: []string{"users-snapshot"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			if err := d.Handle(context.Background(), tt.event); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(calls, tt.want) {
+				t.Fatalf("called %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcherRunsEveryHandlerAndJoinsErrors(t *testing.T) {
+	d := NewDispatcher()
+	failure := errors.New("failed")
+	var called []string
+	d.RegisterFunc("failing", Route{}, func(context.Context, Event) error {
+		called = append(called, "failing")
+		return failure
+	})
+	d.RegisterFunc("next", Route{}, func(context.Context, Event) error {
+		called = append(called, "next")
+		return nil
+	})
+	err := d.Handle(context.Background(), routedEvent("public", "users", OpCreate))
+	if !errors.Is(err, failure) || !strings.Contains(err.Error(), "failing") {
+		t.Fatalf("Handle = %v, want the failure with the handler name", err)
+	}
+	if !slices.Equal(called, []string{"failing", "next"}) {
+		t.Fatalf("called %v, want both handlers", called)
+	}
+}
+
+func TestDispatcherMiddlewareOrder(t *testing.T) {
+	d := NewDispatcher()
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, e Event) error {
+				calls = append(calls, name+" "+HandlerName(ctx))
+				return next.Handle(ctx, e)
+			})
+		}
+	}
+	d.Use(trace("outer"), trace("middle"))
+	d.Use(trace("inner"))
+	d.Use(Only(trace("only"), "b"))
+	d.RegisterFunc("a", Route{}, func(context.Context, Event) error {
+		calls = append(calls, "handler a")
+		return nil
+	})
+	d.RegisterFunc("b", Route{}, func(context.Context, Event) error {
+		calls = append(calls, "handler b")
+		return nil
+	})
+
+	if err := d.Handle(context.Background(), routedEvent("public", "users", OpCreate)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"outer a", "middle a", "inner a", "handler a",
+		"outer b", "middle b", "inner b", "only b", "handler b",
+	}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		attempts     int
+		wantCalls    int
+		wantAttempts int
+	}{
+		{name: "succeeds at once", failures: 0, attempts: 3, wantCalls: 1},
+		{name: "succeeds on retry", failures: 2, attempts: 3, wantCalls: 3},
+		{name: "gives up", failures: 5, attempts: 3, wantCalls: 3, wantAttempts: 3},
+		{name: "single attempt", failures: 1, attempts: 1, wantCalls: 1, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			h := Retry(tt.attempts, time.Microsecond)(HandlerFunc(func(context.Context, Event) error {
+				calls++
+				if calls <= tt.failures {
+					return errors.New("failed")
+				}
+				return nil
+			}))
+			err := h.Handle(context.Background(), Event{})
+			if calls != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			var retryErr *RetryError
+			if tt.wantAttempts == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if !errors.As(err, &retryErr) || retryErr.Attempts != tt.wantAttempts {
+				t.Fatalf("Handle = %v, want a RetryError after %d attempts", err, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestDispatcherInvokeIgnoresRoute(t *testing.T) {
+	d := NewDispatcher()
+	called := false
+	d.RegisterFunc("users", Route{Schema: "public", Table: "users"}, func(context.Context, Event) error {
+		called = true
+		return nil
+	})
+	if err := d.Invoke(context.Background(), "users", routedEvent("public", "orders", OpCreate)); err != nil || !called {
+		t.Fatalf("Invoke = %v, called %v", err, called)
+	}
+	if err := d.Invoke(context.Background(), "missing", Event{}); err == nil {
+		t.Fatal("Invoke of an unregistered handler succeeded")
+	}
+}
@@ -0,0 +1,122 @@
+package cdc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+type Op string
+
+const (
+	OpCreate   Op = "c"
+	OpUpdate   Op = "u"
+	OpDelete   Op = "d"
+	OpRead     Op = "r"
+	OpTruncate Op = "t"
+	OpMessage  Op = "m"
+)
+
+// ErrTombstone is returned for the null value Debezium emits after a delete.
+var ErrTombstone = errors.New("cdc: tombstone event")
+
+// Envelope is a Debezium change event. T is the row type of before and after.
+type Envelope[T any] struct {
+	Before      *T           `json:"before"`
+	After       *T           `json:"after"`
+	Source      Source       `json:"source"`
+	Op          Op           `json:"op"`
+	TsMs        int64        `json:"ts_ms"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	// Schema is the value schema, set only when the event came with the schema/payload wrapper.
+	Schema *Schema `json:"-"`
+}
+
+// Event is an envelope with undecoded rows, the form handlers and sources pass around.
+type Event = Envelope[json.RawMessage]
+
+type Transaction struct {
+	ID                  string `json:"id"`
+	TotalOrder          int64  `json:"total_order"`
+	DataCollectionOrder int64  `json:"data_collection_order"`
+}
+
+type wrapper struct {
+	Schema  *Schema         `json:"schema"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Decode parses a Debezium JSON event produced with or without schemas.enable.
+func Decode[T any](data []byte) (Envelope[T], error) {
+	var env Envelope[T]
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return env, ErrTombstone
+	}
+
+	var w wrapper
+	if err := json.Unmarshal(data, &w); err != nil {
+		return env, fmt.Errorf("cdc.Decode: %w", err)
+	}
+	payload := data
+	if w.Payload != nil {
+		if bytes.Equal(w.Payload, []byte("null")) {
+			return env, ErrTombstone
+		}
+		payload = w.Payload
+	}
+
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return env, fmt.Errorf("cdc.Decode payload: %w", err)
+	}
+	if w.Payload != nil {
+		env.Schema = w.Schema
+	}
+	if env.Op == "" {
+		return env, errors.New("cdc.Decode: event has no op")
+	}
+	return env, nil
+}
+
+// As decodes the raw rows of e into T.
+func As[T any](e Event) (Envelope[T], error) {
+	env := Envelope[T]{
+		Source:      e.Source,
+		Op:          e.Op,
+		TsMs:        e.TsMs,
+		Transaction: e.Transaction,
+		Schema:      e.Schema,
+	}
+	var err error
+	if env.Before, err = decodeRow[T](e.Before); err != nil {
+		return env, fmt.Errorf("cdc.As before: %w", err)
+	}
+	if env.After, err = decodeRow[T](e.After); err != nil {
+		return env, fmt.Errorf("cdc.As after: %w", err)
+	}
+	return env, nil
+}
+
+func decodeRow[T any](raw *json.RawMessage) (*T, error) {
+	if raw == nil || bytes.Equal(*raw, []byte("null")) {
+		return nil, nil
+	}
+	row := new(T)
+	if err := json.Unmarshal(*raw, row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// Row returns the row that describes the state after the event, before for deletes.
+func (e Envelope[T]) Row() *T {
+	if e.Op == OpDelete {
+		return e.Before
+	}
+	return e.After
+}
+
+func (e Envelope[T]) IsSnapshot() bool {
+	return e.Op == OpRead || e.Source.Snapshot.Active()
+}
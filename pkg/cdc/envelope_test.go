@@ -0,0 +1,220 @@
+package cdc
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+type testRow struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantErr    error
+		wantOp     Op
+		wantAfter  *testRow
+		wantBefore *testRow
+		wantSchema bool
+	}{
+		{
+			name:      "without schema",
+			data:      `{"before":null,"after":{"id":1,"name":"a"},"source":{"schema":"public","table":"users"},"op":"c"}`,
+			wantOp:    OpCreate,
+			wantAfter: &testRow{ID: 1, Name: "a"},
+		},
+		{
+			name: "with schema",
+			data: `{"schema":{"type":"struct","fields":[{"type":"struct","field":"after","fields":[{"type":"int32","field":"id"}]}]},
+				"payload":{"before":{"id":1,"name":"a"},"after":{"id":1,"name":"b"},"source":{},"op":"u"}}`,
+			wantOp:     OpUpdate,
+			wantBefore: &testRow{ID: 1, Name: "a"},
+			wantAfter:  &testRow{ID: 1, Name: "b"},
+			wantSchema: true,
+		},
+		{name: "tombstone", data: `null`, wantErr: ErrTombstone},
+		{name: "empty", data: "  \n", wantErr: ErrTombstone},
+		{name: "tombstone with schema", data: `{"schema":null,"payload":null}`, wantErr: ErrTombstone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := Decode[testRow]([]byte(tt.data))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Decode = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if env.Op != tt.wantOp {
+				t.Fatalf("op = %q, want %q", env.Op, tt.wantOp)
+			}
+			if !equalRows(env.After, tt.wantAfter) || !equalRows(env.Before, tt.wantBefore) {
+				t.Fatalf("before, after = %v, %v, want %v, %v", env.Before, env.After, tt.wantBefore, tt.wantAfter)
+			}
+			if (env.Schema != nil) != tt.wantSchema {
+				t.Fatalf("schema = %v, want set %v", env.Schema, tt.wantSchema)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "invalid JSON", data: `{"op":`},
+		{name: "no op", data: `{"after":{"id":1},"source":{}}`},
+		{name: "invalid row", data: `{"after":{"id":"one"},"source":{},"op":"c"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode[testRow]([]byte(tt.data))
+			if err == nil || errors.Is(err, ErrTombstone) {
+				t.Fatalf("Decode = %v, want a decoding error", err)
+			}
+		})
+	}
+}
+
+func equalRows(a, b *testRow) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestAsAndRow(t *testing.T) {
+	e, err := Decode[json.RawMessage]([]byte(`{"before":{"id":1,"name":"a"},"after":null,"source":{},"op":"d"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := As[testRow](e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.After != nil || !equalRows(env.Row(), &testRow{ID: 1, Name: "a"}) {
+		t.Fatalf("row of a delete = %v, want the before row", env.Row())
+	}
+}
+
+func TestSourceFields(t *testing.T) {
+	var s Source
+	data := `{"connector":"postgresql","schema":"public","table":"users","snapshot":"last",
+		"lsn":24023128,"txId":555,"sequence":"[\"24023000\",\"24023128\"]"}`
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		t.Fatal(err)
+	}
+	if lsn, ok := s.LSN(); !ok || lsn != 24023128 {
+		t.Fatalf("LSN = %v, %v", lsn, ok)
+	}
+	if txID, ok := s.TxID(); !ok || txID != 555 {
+		t.Fatalf("TxID = %v, %v", txID, ok)
+	}
+	if lastCommit, lsn, ok := s.Sequence(); !ok || lastCommit != 24023000 || lsn != 24023128 {
+		t.Fatalf("Sequence = %v, %v, %v", lastCommit, lsn, ok)
+	}
+	if !s.Snapshot.Active() || !s.Snapshot.Last() {
+		t.Fatalf("snapshot %q is not the last one", s.Snapshot)
+	}
+	if _, ok := s.Xmin(); ok {
+		t.Fatal("Xmin found in a source without it")
+	}
+
+	// Connector specific fields survive a round trip.
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again Source
+	if err := json.Unmarshal(encoded, &again); err != nil {
+		t.Fatal(err)
+	}
+	if lsn, _ := again.LSN(); lsn != 24023128 || again.Table != "users" {
+		t.Fatalf("round trip = %+v", again)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	tests := []struct {
+		data   string
+		active bool
+	}{
+		{`true`, true},
+		{`false`, false},
+		{`"true"`, true},
+		{`"false"`, false},
+		{`"incremental"`, true},
+		{`"last"`, true},
+		{`null`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var s Snapshot
+			if err := json.Unmarshal([]byte(tt.data), &s); err != nil {
+				t.Fatal(err)
+			}
+			if s.Active() != tt.active {
+				t.Fatalf("Active(%s) = %v, want %v", tt.data, s.Active(), tt.active)
+			}
+		})
+	}
+}
+
+func TestParseLSN(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    LSN
+		wantErr bool
+	}{
+		{s: "16/B374D848", want: 0x16_B374D848},
+		{s: "0/0", want: 0},
+		{s: "24023128", want: 24023128},
+		{s: "16/", wantErr: true},
+		{s: "x", wantErr: true},
+		{s: "1FFFFFFFF/0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseLSN(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLSN(%q) error = %v, want error %v", tt.s, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Fatalf("ParseLSN(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+			if !tt.wantErr && tt.s != "24023128" && got.String() != tt.s {
+				t.Fatalf("String() = %q, want %q", got.String(), tt.s)
+			}
+		})
+	}
+}
+
+func TestSchemaRowFields(t *testing.T) {
+	var s Schema
+	data := `{"type":"struct","fields":[
+		{"type":"struct","field":"before","optional":true,"fields":[{"type":"int32","field":"id"}]},
+		{"type":"struct","field":"after","optional":true,"fields":[{"type":"int32","field":"id"},{"type":"string","field":"name"}]},
+		{"type":"string","field":"op"}]}`
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.RowSchema().FieldNames(); !slices.Equal(got, []string{"id", "name"}) {
+		t.Fatalf("row fields = %v, want the after row's", got)
+	}
+	if s.FieldSchema("missing") != nil {
+		t.Fatal("FieldSchema found a missing field")
+	}
+	var none *Schema
+	if none.RowSchema() != nil || none.FieldNames() != nil {
+		t.Fatal("nil schema has rows")
+	}
+}
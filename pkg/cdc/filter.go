@@ -0,0 +1,154 @@
+package cdc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PredicateOp compares a row field with a value.
+type PredicateOp string
+
+const (
+	PredicateEq PredicateOp = "eq"
+	PredicateNe PredicateOp = "ne"
+	// PredicateContains matches arrays holding the value and strings containing it.
+	PredicateContains PredicateOp = "contains"
+	PredicateIn       PredicateOp = "in"
+	PredicateGt       PredicateOp = "gt"
+	PredicateLt       PredicateOp = "lt"
+)
+
+// Predicate tests a field of the event's row, the after image or the before image of deletes.
+type Predicate struct {
+	Field string          `json:"field"`
+	Op    PredicateOp     `json:"op"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Filter selects events by table, op and row fields. Empty fields match anything, all
+// predicates must hold.
+type Filter struct {
+	Schema string      `json:"schema,omitempty"`
+	Table  string      `json:"table,omitempty"`
+	Ops    []Op        `json:"ops,omitempty"`
+	Where  []Predicate `json:"where,omitempty"`
+}
+
+func (f Filter) Validate() error {
+	for _, p := range f.Where {
+		if p.Field == "" {
+			return fmt.Errorf("cdc: predicate without field")
+		}
+		if !json.Valid(p.Value) {
+			return fmt.Errorf("cdc: predicate on %q: invalid value", p.Field)
+		}
+		switch p.Op {
+		case PredicateEq, PredicateNe, PredicateContains, PredicateGt, PredicateLt:
+		case PredicateIn:
+			var values []json.RawMessage
+			if err := json.Unmarshal(p.Value, &values); err != nil {
+				return fmt.Errorf("cdc: predicate on %q: in needs an array", p.Field)
+			}
+		default:
+			return fmt.Errorf("cdc: predicate on %q: unknown op %q", p.Field, p.Op)
+		}
+	}
+	return nil
+}
+
+func (f Filter) Match(e Event) bool {
+	if f.Schema != "" && f.Schema != e.Source.Schema {
+		return false
+	}
+	if f.Table != "" && f.Table != e.Source.Table {
+		return false
+	}
+	if len(f.Ops) > 0 && !slices.Contains(f.Ops, e.Op) {
+		return false
+	}
+	if len(f.Where) == 0 {
+		return true
+	}
+	row := e.Row()
+	if row == nil {
+		return false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(*row, &fields); err != nil {
+		return false
+	}
+	for _, p := range f.Where {
+		if !p.matches(fields[p.Field]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Predicate) matches(field json.RawMessage) bool {
+	switch p.Op {
+	case PredicateEq:
+		return field != nil && jsonEqual(field, p.Value)
+	case PredicateNe:
+		return field == nil || !jsonEqual(field, p.Value)
+	case PredicateIn:
+		var values []json.RawMessage
+		if field == nil || json.Unmarshal(p.Value, &values) != nil {
+			return false
+		}
+		return slices.ContainsFunc(values, func(v json.RawMessage) bool { return jsonEqual(field, v) })
+	case PredicateContains:
+		var items []json.RawMessage
+		if json.Unmarshal(field, &items) == nil {
+			return slices.ContainsFunc(items, func(v json.RawMessage) bool { return jsonEqual(v, p.Value) })
+		}
+		var s, sub string
+		if json.Unmarshal(field, &s) != nil || json.Unmarshal(p.Value, &sub) != nil {
+			return false
+		}
+		return strings.Contains(s, sub)
+	case PredicateGt, PredicateLt:
+		c, ok := compare(field, p.Value)
+		if !ok {
+			return false
+		}
+		return (p.Op == PredicateGt && c > 0) || (p.Op == PredicateLt && c < 0)
+	}
+	return false
+}
+
+// jsonEqual compares two JSON values, numbers by value.
+func jsonEqual(a, b json.RawMessage) bool {
+	if c, ok := compare(a, b); ok {
+		return c == 0
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	ea, _ := json.Marshal(va)
+	eb, _ := json.Marshal(vb)
+	return bytes.Equal(ea, eb)
+}
+
+// compare orders two numbers or two strings.
+func compare(a, b json.RawMessage) (int, bool) {
+	var fa, fb float64
+	if json.Unmarshal(a, &fa) == nil && json.Unmarshal(b, &fb) == nil {
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	var sa, sb string
+	if json.Unmarshal(a, &sa) == nil && json.Unmarshal(b, &sb) == nil {
+		return strings.Compare(sa, sb), true
+	}
+	return 0, false
+}
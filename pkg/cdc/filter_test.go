@@ -0,0 +1,97 @@
+package cdc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func rowEvent(op Op, row string) Event {
+	raw := json.RawMessage(row)
+	e := Event{Op: op, Source: Source{Schema: "public", Table: "users"}}
+	if op == OpDelete {
+		e.Before = &raw
+	} else {
+		e.After = &raw
+	}
+	return e
+}
+
+func where(field string, op PredicateOp, value string) Filter {
+	return Filter{Where: []Predicate{{Field: field, Op: op, Value: json.RawMessage(value)}}}
+}
+
+func TestFilterMatch(t *testing.T) {
+	const row = `{"id":7,"email":"ann@example.com","role":["admin","user"],"score":2.5,"deleted":null,"meta":{"a":1,"b":2}}`
+	tests := []struct {
+		name   string
+		filter Filter
+		event  Event
+		want   bool
+	}{
+		{name: "empty", filter: Filter{}, event: rowEvent(OpCreate, row), want: true},
+		{name: "table", filter: Filter{Schema: "public", Table: "users"}, event: rowEvent(OpCreate, row), want: true},
+		{name: "other table", filter: Filter{Table: "orders"}, event: rowEvent(OpCreate, row), want: false},
+		{name: "op", filter: Filter{Ops: []Op{OpUpdate}}, event: rowEvent(OpCreate, row), want: false},
+
+		{name: "eq number", filter: where("id", PredicateEq, `7.0`), event: rowEvent(OpCreate, row), want: true},
+		{name: "eq string", filter: where("email", PredicateEq, `"ann@example.com"`), event: rowEvent(OpCreate, row), want: true},
+		{name: "eq object in any key order", filter: where("meta", PredicateEq, `{"b":2,"a":1}`), event: rowEvent(OpCreate, row), want: true},
+		{name: "eq null", filter: where("deleted", PredicateEq, `null`), event: rowEvent(OpCreate, row), want: true},
+		{name: "eq missing field", filter: where("missing", PredicateEq, `null`), event: rowEvent(OpCreate, row), want: false},
+		{name: "ne", filter: where("id", PredicateNe, `8`), event: rowEvent(OpCreate, row), want: true},
+		{name: "ne missing field", filter: where("missing", PredicateNe, `1`), event: rowEvent(OpCreate, row), want: true},
+		{name: "ne equal", filter: where("id", PredicateNe, `7`), event: rowEvent(OpCreate, row), want: false},
+		{name: "in", filter: where("id", PredicateIn, `[1,7]`), event: rowEvent(OpCreate, row), want: true},
+		{name: "not in", filter: where("id", PredicateIn, `[1,2]`), event: rowEvent(OpCreate, row), want: false},
+		{name: "contains array item", filter: where("role", PredicateContains, `"admin"`), event: rowEvent(OpCreate, row), want: true},
+		{name: "array without item", filter: where("role", PredicateContains, `"owner"`), event: rowEvent(OpCreate, row), want: false},
+		{name: "contains substring", filter: where("email", PredicateContains, `"@example"`), event: rowEvent(OpCreate, row), want: true},
+		{name: "contains on number", filter: where("id", PredicateContains, `"7"`), event: rowEvent(OpCreate, row), want: false},
+		{name: "gt", filter: where("score", PredicateGt, `2`), event: rowEvent(OpCreate, row), want: true},
+		{name: "lt", filter: where("score", PredicateLt, `2`), event: rowEvent(OpCreate, row), want: false},
+		{name: "gt strings", filter: where("email", PredicateGt, `"a"`), event: rowEvent(OpCreate, row), want: true},
+		{name: "gt mixed types", filter: where("email", PredicateGt, `1`), event: rowEvent(OpCreate, row), want: false},
+
+		{name: "delete uses before", filter: where("id", PredicateEq, `7`), event: rowEvent(OpDelete, row), want: true},
+		{name: "no row", filter: where("id", PredicateEq, `7`), event: Event{Op: OpTruncate}, want: false},
+		{
+			name: "all predicates hold",
+			filter: Filter{Where: []Predicate{
+				{Field: "id", Op: PredicateEq, Value: json.RawMessage(`7`)},
+				{Field: "role", Op: PredicateContains, Value: json.RawMessage(`"owner"`)},
+			}},
+			event: rowEvent(OpCreate, row),
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.event); got != tt.want {
+				t.Fatalf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr bool
+	}{
+		{name: "empty", filter: Filter{}},
+		{name: "valid", filter: where("id", PredicateGt, `1`)},
+		{name: "in array", filter: where("id", PredicateIn, `[1,2]`)},
+		{name: "in without array", filter: where("id", PredicateIn, `1`), wantErr: true},
+		{name: "no field", filter: where("", PredicateEq, `1`), wantErr: true},
+		{name: "invalid value", filter: where("id", PredicateEq, `{`), wantErr: true},
+		{name: "unknown op", filter: where("id", "like", `"a%"`), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package cdc
+
+import "context"
+
+// UnavailablePlaceholder is what Debezium puts into unchanged TOAST columns.
+const UnavailablePlaceholder = "__debezium_unavailable_value"
+
+// Handler consumes change events. A source must not treat an event as delivered until Handle returns nil.
+type Handler interface {
+	Handle(ctx context.Context, e Event) error
+}
+
+type HandlerFunc func(ctx context.Context, e Event) error
+
+func (f HandlerFunc) Handle(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
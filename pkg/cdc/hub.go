@@ -0,0 +1,187 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrSlowConsumer is the reason a subscription is closed when it does not keep up.
+var ErrSlowConsumer = errors.New("cdc: subscriber too slow")
+
+// ErrHubClosed is the reason subscriptions are closed by Hub.Close.
+var ErrHubClosed = errors.New("cdc: hub closed")
+
+// Hub fans events out to live subscribers, e.g. SSE or WebSocket clients. It keeps the last
+// events in a ring buffer so a reconnecting client can resume from the last event it saw.
+// Subscribers never block the pipeline, one whose buffer is full is closed with ErrSlowConsumer.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+
+	// epoch tells the IDs of this hub from the ones of a previous process.
+	epoch  string
+	seq    uint64
+	ring   []HubEvent
+	next   int
+	full   bool
+	closed bool
+}
+
+// HubEvent is an event published by a hub. ID is its position in the hub, in publishing
+// order: LSNs are not, events of interleaved transactions or parallel workers come out of order.
+type HubEvent struct {
+	ID    string
+	Event Event
+
+	seq uint64
+}
+
+func NewHub(history int) *Hub {
+	return &Hub{
+		subs:  make(map[*Subscription]struct{}),
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring:  make([]HubEvent, max(history, 0)),
+	}
+}
+
+type Subscription struct {
+	hub    *Hub
+	events chan HubEvent
+	done   chan struct{}
+	once   sync.Once
+	err    error
+}
+
+// Events delivers the events published after Subscribe returned.
+func (s *Subscription) Events() <-chan HubEvent {
+	return s.events
+}
+
+// Done is closed when the subscription is closed by Close or by the hub.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the hub closed the subscription, nil before Done is closed or after Close.
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.closeLocked(nil)
+}
+
+func (s *Subscription) closeLocked(err error) {
+	s.once.Do(func() {
+		s.err = err
+		delete(s.hub.subs, s)
+		close(s.done)
+	})
+}
+
+// Subscribe registers a subscriber with room for buffer pending events. When lastID is the
+// ID of an event of this hub the buffered events published after it are returned for replay,
+// all of them when it already left the buffer. The buffer is in memory: IDs from before a
+// restart, or unknown ones, replay nothing.
+func (h *Hub) Subscribe(buffer int, lastID string) (*Subscription, []HubEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub := &Subscription{
+		hub:    h,
+		events: make(chan HubEvent, max(buffer, 1)),
+		done:   make(chan struct{}),
+	}
+	if h.closed {
+		sub.closeLocked(ErrHubClosed)
+		return sub, nil
+	}
+	h.subs[sub] = struct{}{}
+	if lastID == "" {
+		return sub, nil
+	}
+	last, ok := h.parseID(lastID)
+	if !ok {
+		return sub, nil
+	}
+	var replay []HubEvent
+	for _, e := range h.history() {
+		if e.seq > last {
+			replay = append(replay, e)
+		}
+	}
+	return sub, replay
+}
+
+// parseID returns the sequence of an ID made by this hub, false for any other ID.
+func (h *Hub) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, "-")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || n > h.seq {
+		return 0, false
+	}
+	return n, true
+}
+
+// Close closes every subscription with ErrHubClosed, and the ones made later at once, so
+// long-lived streams end on shutdown. Events are still buffered for resuming clients.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		sub.closeLocked(ErrHubClosed)
+	}
+}
+
+// Handle publishes e to every subscriber. It never fails.
+func (h *Hub) Handle(_ context.Context, event Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	e := HubEvent{ID: h.epoch + "-" + strconv.FormatUint(h.seq, 10), Event: event, seq: h.seq}
+	if len(h.ring) > 0 {
+		h.ring[h.next] = e
+		h.next = (h.next + 1) % len(h.ring)
+		h.full = h.full || h.next == 0
+	}
+	for sub := range h.subs {
+		select {
+		case sub.events <- e:
+		default:
+			sub.closeLocked(ErrSlowConsumer)
+		}
+	}
+	return nil
+}
+
+// history returns the buffered events, oldest first.
+func (h *Hub) history() []HubEvent {
+	if !h.full {
+		return append([]HubEvent(nil), h.ring[:h.next]...)
+	}
+	return append(append([]HubEvent(nil), h.ring[h.next:]...), h.ring[:h.next]...)
+}
+
+// EventID identifies e across consumers, e.g. for deduplicating webhooks: its LSN, empty
+// when the source has none. Streams resume with HubEvent.ID instead.
+func EventID(e Event) string {
+	lsn, ok := e.Source.LSN()
+	if !ok {
+		return ""
+	}
+	return lsn.String()
+}
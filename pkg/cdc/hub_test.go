@@ -0,0 +1,90 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func lsnEvent(lsn int) Event {
+	return Event{Source: Source{Fields: map[string]json.RawMessage{"lsn": json.RawMessage(strconv.Itoa(lsn))}}}
+}
+
+func publish(t *testing.T, h *Hub, lsns ...int) []HubEvent {
+	t.Helper()
+	sub, _ := h.Subscribe(len(lsns), "")
+	defer sub.Close()
+	for _, lsn := range lsns {
+		if err := h.Handle(context.Background(), lsnEvent(lsn)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	published := make([]HubEvent, 0, len(lsns))
+	for range lsns {
+		published = append(published, <-sub.Events())
+	}
+	return published
+}
+
+func replayedLSNs(events []HubEvent) []uint64 {
+	lsns := make([]uint64, 0, len(events))
+	for _, e := range events {
+		lsn, _ := e.Event.Source.LSN()
+		lsns = append(lsns, uint64(lsn))
+	}
+	return lsns
+}
+
+func TestHubResumesInPublishingOrder(t *testing.T) {
+	h := NewHub(10)
+	// Interleaved transactions and parallel workers publish lower LSNs after higher ones.
+	published := publish(t, h, 300, 100, 400, 200)
+
+	tests := []struct {
+		name   string
+		lastID string
+		want   []uint64
+	}{
+		{name: "after the first", lastID: published[0].ID, want: []uint64{100, 400, 200}},
+		{name: "after a higher LSN", lastID: published[2].ID, want: []uint64{200}},
+		{name: "after the last", lastID: published[3].ID, want: []uint64{}},
+		{name: "no id", lastID: "", want: []uint64{}},
+		{name: "LSN", lastID: "0/12C", want: []uint64{}},
+		{name: "other hub", lastID: "abc-1", want: []uint64{}},
+		{name: "not published yet", lastID: h.epoch + "-99", want: []uint64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, replay := h.Subscribe(1, tt.lastID)
+			defer sub.Close()
+			if got := replayedLSNs(replay); !slices.Equal(got, tt.want) {
+				t.Fatalf("replayed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubReplaysWholeBufferWhenLastEventLeftIt(t *testing.T) {
+	h := NewHub(2)
+	published := publish(t, h, 1, 2, 3, 4)
+	sub, replay := h.Subscribe(1, published[0].ID)
+	defer sub.Close()
+	if got := replayedLSNs(replay); !slices.Equal(got, []uint64{3, 4}) {
+		t.Fatalf("replayed %v, want [3 4]", got)
+	}
+}
+
+func TestHubIgnoresIDsOfPreviousHub(t *testing.T) {
+	previous := NewHub(1)
+	previous.epoch = "previous"
+	first := publish(t, previous, 1)
+	h := NewHub(1)
+	publish(t, h, 1)
+	sub, replay := h.Subscribe(1, first[0].ID)
+	defer sub.Close()
+	if len(replay) != 0 {
+		t.Fatalf("replayed %d events for an ID of a previous hub", len(replay))
+	}
+}
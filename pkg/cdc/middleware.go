@@ -0,0 +1,156 @@
+package cdc
+
+import (
+	"context"
+	"debez/pkg/logger"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Logging logs every failed handler call and, at debug level, every handled event.
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, e Event) error {
+			start := time.Now()
+			err := next.Handle(ctx, e)
+			fields := []zap.Field{
+				zap.String("handler", HandlerName(ctx)),
+				zap.String("table", e.Source.Schema+"."+e.Source.Table),
+				zap.String("op", string(e.Op)),
+				zap.Duration("duration", time.Since(start)),
+			}
+			if lsn, ok := e.Source.LSN(); ok {
+				fields = append(fields, zap.Stringer("lsn", lsn))
+			}
+			if err != nil {
+				logger.GetLoggerFromCtx(ctx).Info(ctx, "change event handler failed", append(fields, zap.Error(err))...)
+				return err
+			}
+			logger.GetLoggerFromCtx(ctx).Debug(ctx, "change event handled", fields...)
+			return nil
+		})
+	}
+}
+
+// Only applies m to the named handlers and passes the other handlers' events straight on.
+func Only(m Middleware, names ...string) Middleware {
+	return func(next Handler) Handler {
+		wrapped := m(next)
+		return HandlerFunc(func(ctx context.Context, e Event) error {
+			if slices.Contains(names, HandlerName(ctx)) {
+				return wrapped.Handle(ctx, e)
+			}
+			return next.Handle(ctx, e)
+		})
+	}
+}
+
+// RetryError is returned by Retry when every attempt failed.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Retry calls the handler up to attempts times, doubling backoff after every failure.
+func Retry(attempts int, backoff time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, e Event) error {
+			delay := backoff
+			var err error
+			for attempt := 1; ; attempt++ {
+				if err = next.Handle(ctx, e); err == nil {
+					return nil
+				}
+				if attempt >= attempts {
+					return &RetryError{Attempts: attempt, Err: err}
+				}
+				select {
+				case <-ctx.Done():
+					return &RetryError{Attempts: attempt, Err: err}
+				case <-time.After(delay):
+				}
+				delay *= 2
+			}
+		})
+	}
+}
+
+type HandlerStats struct {
+	Handled       int64         `json:"handled"`
+	Failed        int64         `json:"failed"`
+	TotalDuration time.Duration `json:"total_duration_ns"`
+	LastError     string        `json:"last_error,omitempty"`
+	LastEventAt   time.Time     `json:"last_event_at,omitzero"`
+}
+
+// Metrics collects per handler counters. It serves them as JSON.
+type Metrics struct {
+	mu       sync.Mutex
+	handlers map[string]*HandlerStats
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{handlers: make(map[string]*HandlerStats)}
+}
+
+// Middleware records the outcome of every handler call.
+func (m *Metrics) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, e Event) error {
+			start := time.Now()
+			err := next.Handle(ctx, e)
+			m.record(HandlerName(ctx), time.Since(start), err)
+			return err
+		})
+	}
+}
+
+func (m *Metrics) record(name string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, ok := m.handlers[name]
+	if !ok {
+		stats = &HandlerStats{}
+		m.handlers[name] = stats
+	}
+	stats.TotalDuration += d
+	stats.LastEventAt = time.Now().UTC()
+	if err != nil {
+		stats.Failed++
+		stats.LastError = err.Error()
+		return
+	}
+	stats.Handled++
+}
+
+func (m *Metrics) Snapshot() map[string]HandlerStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := make(map[string]HandlerStats, len(m.handlers))
+	for name, stats := range m.handlers {
+		snapshot[name] = *stats
+	}
+	return snapshot
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.Snapshot()); err != nil {
+		http.Error(w, "Failed to encode metrics", http.StatusInternalServerError)
+		return
+	}
+}
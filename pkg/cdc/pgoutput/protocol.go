@@ -0,0 +1,296 @@
+package pgoutput
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Messages of the pgoutput plugin, protocol version 1.
+// See https://www.postgresql.org/docs/current/protocol-logicalrep-message-formats.html
+const (
+	msgBegin    = 'B'
+	msgCommit   = 'C'
+	msgOrigin   = 'O'
+	msgRelation = 'R'
+	msgType     = 'Y'
+	msgInsert   = 'I'
+	msgUpdate   = 'U'
+	msgDelete   = 'D'
+	msgTruncate = 'T'
+	msgMessage  = 'M'
+
+	tupleNew = 'N'
+	tupleKey = 'K'
+	tupleOld = 'O'
+
+	columnNull      = 'n'
+	columnUnchanged = 'u'
+	columnText      = 't'
+	columnBinary    = 'b'
+
+	// Replication protocol CopyData payloads.
+	xLogDataByte          = 'w'
+	keepaliveByte         = 'k'
+	standbyStatusByte     = 'r'
+	columnFlagPrimaryKey  = 1
+	microsecFromUnixEpoch = 946684800 * 1000000
+)
+
+var errShortMessage = errors.New("pgoutput: message too short")
+
+type beginMessage struct {
+	FinalLSN   uint64
+	CommitTime time.Time
+	Xid        uint32
+}
+
+type commitMessage struct {
+	CommitLSN  uint64
+	EndLSN     uint64
+	CommitTime time.Time
+}
+
+type relationColumn struct {
+	Key      bool
+	Name     string
+	TypeOID  uint32
+	TypeMode int32
+}
+
+type relationMessage struct {
+	ID              uint32
+	Namespace       string
+	Name            string
+	ReplicaIdentity byte
+	Columns         []relationColumn
+}
+
+type tupleColumn struct {
+	Kind byte
+	Data []byte
+}
+
+type insertMessage struct {
+	RelationID uint32
+	New        []tupleColumn
+}
+
+type updateMessage struct {
+	RelationID uint32
+	// OldKind is tupleKey, tupleOld or 0 when the old row was not sent.
+	OldKind byte
+	Old     []tupleColumn
+	New     []tupleColumn
+}
+
+type deleteMessage struct {
+	RelationID uint32
+	OldKind    byte
+	Old        []tupleColumn
+}
+
+type truncateMessage struct {
+	RelationIDs []uint32
+}
+
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < n {
+		r.err = errShortMessage
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *reader) time() time.Time {
+	return pgTime(int64(r.uint64()))
+}
+
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.buf, 0)
+	if i < 0 {
+		r.err = errShortMessage
+		return ""
+	}
+	s := string(r.buf[:i])
+	r.buf = r.buf[i+1:]
+	return s
+}
+
+func (r *reader) tuple() []tupleColumn {
+	n := int(r.uint16())
+	columns := make([]tupleColumn, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		col := tupleColumn{Kind: r.byte()}
+		switch col.Kind {
+		case columnNull, columnUnchanged:
+		case columnText, columnBinary:
+			size := int(r.uint32())
+			col.Data = r.take(size)
+		default:
+			r.err = fmt.Errorf("pgoutput: unknown tuple column kind %q", col.Kind)
+		}
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+func parseBegin(data []byte) (beginMessage, error) {
+	r := reader{buf: data}
+	m := beginMessage{FinalLSN: r.uint64(), CommitTime: r.time(), Xid: r.uint32()}
+	return m, r.err
+}
+
+func parseCommit(data []byte) (commitMessage, error) {
+	r := reader{buf: data}
+	r.byte() // flags, unused
+	m := commitMessage{CommitLSN: r.uint64(), EndLSN: r.uint64(), CommitTime: r.time()}
+	return m, r.err
+}
+
+func parseRelation(data []byte) (relationMessage, error) {
+	r := reader{buf: data}
+	m := relationMessage{ID: r.uint32(), Namespace: r.string(), Name: r.string(), ReplicaIdentity: r.byte()}
+	n := int(r.uint16())
+	for i := 0; i < n && r.err == nil; i++ {
+		m.Columns = append(m.Columns, relationColumn{
+			Key:      r.byte()&columnFlagPrimaryKey != 0,
+			Name:     r.string(),
+			TypeOID:  r.uint32(),
+			TypeMode: int32(r.uint32()),
+		})
+	}
+	return m, r.err
+}
+
+func parseInsert(data []byte) (insertMessage, error) {
+	r := reader{buf: data}
+	m := insertMessage{RelationID: r.uint32()}
+	if kind := r.byte(); r.err == nil && kind != tupleNew {
+		return m, fmt.Errorf("pgoutput: unexpected insert tuple %q", kind)
+	}
+	m.New = r.tuple()
+	return m, r.err
+}
+
+func parseUpdate(data []byte) (updateMessage, error) {
+	r := reader{buf: data}
+	m := updateMessage{RelationID: r.uint32()}
+	kind := r.byte()
+	if kind == tupleKey || kind == tupleOld {
+		m.OldKind = kind
+		m.Old = r.tuple()
+		kind = r.byte()
+	}
+	if r.err == nil && kind != tupleNew {
+		return m, fmt.Errorf("pgoutput: unexpected update tuple %q", kind)
+	}
+	m.New = r.tuple()
+	return m, r.err
+}
+
+func parseDelete(data []byte) (deleteMessage, error) {
+	r := reader{buf: data}
+	m := deleteMessage{RelationID: r.uint32(), OldKind: r.byte()}
+	if r.err == nil && m.OldKind != tupleKey && m.OldKind != tupleOld {
+		return m, fmt.Errorf("pgoutput: unexpected delete tuple %q", m.OldKind)
+	}
+	m.Old = r.tuple()
+	return m, r.err
+}
+
+func parseTruncate(data []byte) (truncateMessage, error) {
+	r := reader{buf: data}
+	n := int(r.uint32())
+	r.byte() // options, unused
+	var m truncateMessage
+	for i := 0; i < n && r.err == nil; i++ {
+		m.RelationIDs = append(m.RelationIDs, r.uint32())
+	}
+	return m, r.err
+}
+
+type xLogData struct {
+	WALStart     uint64
+	ServerWALEnd uint64
+	Data         []byte
+}
+
+func parseXLogData(data []byte) (xLogData, error) {
+	r := reader{buf: data}
+	m := xLogData{WALStart: r.uint64(), ServerWALEnd: r.uint64()}
+	r.uint64() // server time, unused
+	m.Data = r.buf
+	return m, r.err
+}
+
+type keepalive struct {
+	ServerWALEnd   uint64
+	ReplyRequested bool
+}
+
+func parseKeepalive(data []byte) (keepalive, error) {
+	r := reader{buf: data}
+	m := keepalive{ServerWALEnd: r.uint64()}
+	r.uint64() // server time, unused
+	m.ReplyRequested = r.byte() == 1
+	return m, r.err
+}
+
+func encodeStandbyStatus(written, flushed uint64, now time.Time) []byte {
+	buf := make([]byte, 0, 34)
+	buf = append(buf, standbyStatusByte)
+	buf = binary.BigEndian.AppendUint64(buf, written)
+	buf = binary.BigEndian.AppendUint64(buf, flushed)
+	buf = binary.BigEndian.AppendUint64(buf, flushed)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(now.UnixMicro()-microsecFromUnixEpoch))
+	return append(buf, 0)
+}
+
+// pgTime converts microseconds since 2000-01-01 to time.
+func pgTime(micros int64) time.Time {
+	return time.UnixMicro(micros + microsecFromUnixEpoch).UTC()
+}
@@ -0,0 +1,502 @@
+package pgoutput
+
+import (
+	"context"
+	"debez/pkg/cdc"
+	"debez/pkg/logger"
+	"debez/pkg/postgres"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+const (
+	connectorName         = "postgresql"
+	sourceVersion         = "pgoutput-inprocess"
+	defaultStatusInterval = 10 * time.Second
+	duplicateObjectCode   = "42710"
+)
+
+type Config struct {
+	Postgres    postgres.Config
+	Slot        string
+	Publication string
+	// Tables limits events to these "schema.table" names. Empty means every table of the publication.
+	Tables []string
+	// ServerName is source.name, Debezium's topic.prefix.
+	ServerName string
+	// CreateSlot creates the slot on start when it does not exist.
+	CreateSlot     bool
+	StatusInterval time.Duration
+	// Checkpoints, when set, get every confirmed position under source ServerName and
+	// partition Slot. The source resumes from the stored checkpoint on startup.
+	Checkpoints *cdc.Checkpointer
+}
+
+// Source streams changes from a logical replication slot with the pgoutput plugin and
+// hands them to a cdc.Handler as Debezium envelopes. The slot position is confirmed
+// only up to the end of the last transaction whose events were all handled.
+type Source struct {
+	cfg       Config
+	typeMap   *pgtype.Map
+	relations map[uint32]*relation
+
+	tx        *beginMessage
+	written   uint64
+	confirmed uint64
+	// acked is the position a cdc.Acknowledger handler finished, applied by the stream loop.
+	acked atomic.Uint64
+
+	// txEvents and txCollections count the events emitted in tx, BEGIN is only sent once
+	// the transaction has an event of a captured table.
+	txEvents      int64
+	txCollections []cdc.DataCollection
+}
+
+type relation struct {
+	msg    relationMessage
+	schema *cdc.Schema
+	skip   bool
+}
+
+func NewSource(cfg Config) *Source {
+	if cfg.StatusInterval <= 0 {
+		cfg.StatusInterval = defaultStatusInterval
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = cfg.Postgres.DataBase
+	}
+	return &Source{
+		cfg:       cfg,
+		typeMap:   pgtype.NewMap(),
+		relations: make(map[uint32]*relation),
+	}
+}
+
+// Run streams until ctx is done or handler fails. On restart the slot resends
+// everything after the last confirmed transaction.
+func (s *Source) Run(ctx context.Context, handler cdc.Handler) error {
+	conn, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	s.tx = nil
+
+	if err := s.resume(ctx); err != nil {
+		return err
+	}
+	if s.cfg.CreateSlot {
+		if err := s.createSlot(ctx, conn); err != nil {
+			return err
+		}
+	}
+	if err := s.startReplication(ctx, conn); err != nil {
+		return err
+	}
+	logger.GetLoggerFromCtx(ctx).Info(ctx, "logical replication started",
+		zap.String("slot", s.cfg.Slot), zap.String("publication", s.cfg.Publication))
+
+	err = s.stream(ctx, conn, handler)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// resume starts from the stored checkpoint the first time the source runs. The server starts
+// from the slot's confirmed position when the checkpoint is behind it.
+func (s *Source) resume(ctx context.Context) error {
+	if s.cfg.Checkpoints == nil || s.confirmed != 0 {
+		return nil
+	}
+	cp, ok, err := s.cfg.Checkpoints.Load(ctx, s.cfg.ServerName, s.cfg.Slot)
+	if err != nil {
+		return fmt.Errorf("pgoutput.resume: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+	lsn, err := cdc.ParseLSN(cp.Position)
+	if err != nil {
+		return fmt.Errorf("pgoutput.resume: checkpoint %q: %w", cp.Position, err)
+	}
+	s.confirmed = uint64(lsn)
+	s.written = max(s.written, s.confirmed)
+	logger.GetLoggerFromCtx(ctx).Info(ctx, "resuming logical replication from checkpoint", zap.Stringer("lsn", lsn))
+	return nil
+}
+
+// commit confirms lsn once handler processed every event before it. A cdc.Acknowledger
+// handler, like cdc.Pool, finishes them later and the stream loop confirms lsn then.
+func (s *Source) commit(handler cdc.Handler, lsn uint64) {
+	acker, ok := handler.(cdc.Acknowledger)
+	if !ok {
+		s.confirm(lsn)
+		return
+	}
+	acker.AckAfter(func() {
+		for {
+			acked := s.acked.Load()
+			if lsn <= acked || s.acked.CompareAndSwap(acked, lsn) {
+				return
+			}
+		}
+	})
+	s.applyAcks()
+}
+
+func (s *Source) applyAcks() {
+	if lsn := s.acked.Load(); lsn > s.confirmed {
+		s.confirm(lsn)
+	}
+}
+
+// confirm advances the position reported to the server and the checkpoint.
+func (s *Source) confirm(lsn uint64) {
+	if lsn <= s.confirmed {
+		return
+	}
+	s.confirmed = lsn
+	if s.cfg.Checkpoints != nil {
+		s.cfg.Checkpoints.Mark(cdc.Checkpoint{Source: s.cfg.ServerName, Partition: s.cfg.Slot, Position: cdc.LSN(lsn).String()})
+	}
+}
+
+func (s *Source) connect(ctx context.Context) (*pgconn.PgConn, error) {
+	connConfig, err := pgconn.ParseConfig(s.cfg.Postgres.ConnString())
+	if err != nil {
+		return nil, fmt.Errorf("pgoutput.connect parse config: %w", err)
+	}
+	connConfig.RuntimeParams["replication"] = "database"
+	conn, err := pgconn.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		return nil, fmt.Errorf("pgoutput.connect: %w", err)
+	}
+	return conn, nil
+}
+
+func (s *Source) createSlot(ctx context.Context, conn *pgconn.PgConn) error {
+	sql := fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL pgoutput NOEXPORT_SNAPSHOT", pgx.Identifier{s.cfg.Slot}.Sanitize())
+	_, err := conn.Exec(ctx, sql).ReadAll()
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == duplicateObjectCode {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("pgoutput.createSlot: %w", err)
+	}
+	return nil
+}
+
+func (s *Source) startReplication(ctx context.Context, conn *pgconn.PgConn) error {
+	sql := fmt.Sprintf(`START_REPLICATION SLOT %s LOGICAL %s (proto_version '1', publication_names '%s')`,
+		pgx.Identifier{s.cfg.Slot}.Sanitize(), cdc.LSN(s.confirmed), strings.ReplaceAll(s.cfg.Publication, "'", "''"))
+	conn.Frontend().SendQuery(&pgproto3.Query{String: sql})
+	if err := conn.Frontend().Flush(); err != nil {
+		return fmt.Errorf("pgoutput.startReplication send: %w", err)
+	}
+	for {
+		msg, err := conn.ReceiveMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("pgoutput.startReplication: %w", err)
+		}
+		switch msg := msg.(type) {
+		case *pgproto3.CopyBothResponse:
+			return nil
+		case *pgproto3.ErrorResponse:
+			return fmt.Errorf("pgoutput.startReplication: %w", pgconn.ErrorResponseToPgError(msg))
+		case *pgproto3.NoticeResponse, *pgproto3.ParameterStatus:
+		default:
+			return fmt.Errorf("pgoutput.startReplication: unexpected message %T", msg)
+		}
+	}
+}
+
+func (s *Source) stream(ctx context.Context, conn *pgconn.PgConn, handler cdc.Handler) error {
+	nextStatus := time.Now().Add(s.cfg.StatusInterval)
+	for {
+		s.applyAcks()
+		if !time.Now().Before(nextStatus) {
+			if err := s.sendStatus(conn); err != nil {
+				return err
+			}
+			nextStatus = time.Now().Add(s.cfg.StatusInterval)
+		}
+
+		recvCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		msg, err := conn.ReceiveMessage(recvCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && pgconn.Timeout(err) {
+				continue
+			}
+			return fmt.Errorf("pgoutput.stream receive: %w", err)
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.ErrorResponse:
+			return fmt.Errorf("pgoutput.stream: %w", pgconn.ErrorResponseToPgError(msg))
+		case *pgproto3.CopyDone:
+			return errors.New("pgoutput.stream: server ended replication")
+		case *pgproto3.CopyData:
+			if len(msg.Data) == 0 {
+				continue
+			}
+			data := slices.Clone(msg.Data)
+			switch data[0] {
+			case keepaliveByte:
+				k, err := parseKeepalive(data[1:])
+				if err != nil {
+					return err
+				}
+				// Outside a transaction everything up to the server's WAL end has been handled,
+				// confirming it lets the slot advance past WAL of other tables and databases.
+				if s.tx == nil && k.ServerWALEnd > s.confirmed {
+					s.written = max(s.written, k.ServerWALEnd)
+					s.commit(handler, k.ServerWALEnd)
+				}
+				if k.ReplyRequested {
+					nextStatus = time.Time{}
+				}
+			case xLogDataByte:
+				xld, err := parseXLogData(data[1:])
+				if err != nil {
+					return err
+				}
+				s.written = max(s.written, xld.WALStart)
+				if err := s.handleMessage(ctx, xld, handler); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (s *Source) sendStatus(conn *pgconn.PgConn) error {
+	msg := &pgproto3.CopyData{Data: encodeStandbyStatus(s.written, s.confirmed, time.Now())}
+	buf, err := msg.Encode(nil)
+	if err != nil {
+		return fmt.Errorf("pgoutput.sendStatus encode: %w", err)
+	}
+	if err := conn.Frontend().SendUnbufferedEncodedCopyData(buf); err != nil {
+		return fmt.Errorf("pgoutput.sendStatus: %w", err)
+	}
+	return nil
+}
+
+func (s *Source) handleMessage(ctx context.Context, xld xLogData, handler cdc.Handler) error {
+	if len(xld.Data) == 0 {
+		return nil
+	}
+	body := xld.Data[1:]
+	switch xld.Data[0] {
+	case msgBegin:
+		begin, err := parseBegin(body)
+		if err != nil {
+			return err
+		}
+		s.tx = &begin
+		s.txEvents, s.txCollections = 0, nil
+	case msgCommit:
+		commit, err := parseCommit(body)
+		if err != nil {
+			return err
+		}
+		if s.txEvents > 0 {
+			end := cdc.TransactionEvent{
+				Status:          cdc.TxEnd,
+				ID:              s.txID(),
+				EventCount:      s.txEvents,
+				DataCollections: s.txCollections,
+				TsMs:            commit.CommitTime.UnixMilli(),
+			}
+			if err := handleTransaction(ctx, handler, end); err != nil {
+				return err
+			}
+		}
+		s.tx = nil
+		s.commit(handler, commit.EndLSN)
+	case msgRelation:
+		rel, err := parseRelation(body)
+		if err != nil {
+			return err
+		}
+		s.relations[rel.ID] = &relation{
+			msg:    rel,
+			schema: relationSchema(rel, s.cfg.ServerName),
+			skip:   !s.captured(rel.Namespace, rel.Name),
+		}
+	case msgInsert:
+		m, err := parseInsert(body)
+		if err != nil {
+			return err
+		}
+		return s.emit(ctx, handler, xld.WALStart, m.RelationID, cdc.OpCreate, nil, m.New)
+	case msgUpdate:
+		m, err := parseUpdate(body)
+		if err != nil {
+			return err
+		}
+		return s.emit(ctx, handler, xld.WALStart, m.RelationID, cdc.OpUpdate, m.Old, m.New)
+	case msgDelete:
+		m, err := parseDelete(body)
+		if err != nil {
+			return err
+		}
+		return s.emit(ctx, handler, xld.WALStart, m.RelationID, cdc.OpDelete, m.Old, nil)
+	case msgTruncate:
+		m, err := parseTruncate(body)
+		if err != nil {
+			return err
+		}
+		for _, id := range m.RelationIDs {
+			if err := s.emit(ctx, handler, xld.WALStart, id, cdc.OpTruncate, nil, nil); err != nil {
+				return err
+			}
+		}
+	case msgOrigin, msgType, msgMessage:
+	}
+	return nil
+}
+
+func (s *Source) captured(namespace, name string) bool {
+	if len(s.cfg.Tables) == 0 {
+		return true
+	}
+	full := namespace + "." + name
+	for _, t := range s.cfg.Tables {
+		if t == full || (namespace == "public" && t == name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Source) emit(ctx context.Context, handler cdc.Handler, lsn uint64, relID uint32, op cdc.Op, oldTuple, newTuple []tupleColumn) error {
+	rel, ok := s.relations[relID]
+	if !ok {
+		return fmt.Errorf("pgoutput: unknown relation %d", relID)
+	}
+	if rel.skip {
+		return nil
+	}
+
+	event := cdc.Event{Op: op, TsMs: time.Now().UnixMilli(), Schema: rel.schema}
+	event.Source = cdc.Source{
+		Version:   sourceVersion,
+		Connector: connectorName,
+		Name:      s.cfg.ServerName,
+		Snapshot:  "false",
+		DB:        s.cfg.Postgres.DataBase,
+		Schema:    rel.msg.Namespace,
+		Table:     rel.msg.Name,
+	}
+	event.Source.Set("lsn", lsn)
+	event.Source.Set("sequence", fmt.Sprintf(`["%d","%d"]`, s.confirmed, lsn))
+	if s.tx != nil {
+		event.Source.TsMs = s.tx.CommitTime.UnixMilli()
+		event.Source.Set("txId", s.tx.Xid)
+		if err := s.countTxEvent(ctx, handler, &event); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if event.Before, err = s.row(rel, oldTuple); err != nil {
+		return err
+	}
+	if event.After, err = s.row(rel, newTuple); err != nil {
+		return err
+	}
+	if err := handler.Handle(ctx, event); err != nil {
+		return fmt.Errorf("pgoutput: handle %s.%s at %s: %w", rel.msg.Namespace, rel.msg.Name, cdc.LSN(lsn), err)
+	}
+	return nil
+}
+
+// txID is the transaction id Debezium uses for Postgres, "xid:lsn" with the commit LSN.
+func (s *Source) txID() string {
+	return fmt.Sprintf("%d:%d", s.tx.Xid, s.tx.FinalLSN)
+}
+
+// countTxEvent sets the transaction block of an event of the current transaction, the
+// first one is preceded by BEGIN.
+func (s *Source) countTxEvent(ctx context.Context, handler cdc.Handler, event *cdc.Event) error {
+	if s.txEvents == 0 {
+		begin := cdc.TransactionEvent{Status: cdc.TxBegin, ID: s.txID(), TsMs: s.tx.CommitTime.UnixMilli()}
+		if err := handleTransaction(ctx, handler, begin); err != nil {
+			return err
+		}
+	}
+	s.txEvents++
+	collection := event.Source.Schema + "." + event.Source.Table
+	i := slices.IndexFunc(s.txCollections, func(c cdc.DataCollection) bool { return c.DataCollection == collection })
+	if i < 0 {
+		s.txCollections = append(s.txCollections, cdc.DataCollection{DataCollection: collection})
+		i = len(s.txCollections) - 1
+	}
+	s.txCollections[i].EventCount++
+	event.Transaction = &cdc.Transaction{
+		ID:                  s.txID(),
+		TotalOrder:          s.txEvents,
+		DataCollectionOrder: s.txCollections[i].EventCount,
+	}
+	return nil
+}
+
+func handleTransaction(ctx context.Context, handler cdc.Handler, tx cdc.TransactionEvent) error {
+	txHandler, ok := handler.(cdc.TransactionHandler)
+	if !ok {
+		return nil
+	}
+	if err := txHandler.HandleTransaction(ctx, tx); err != nil {
+		return fmt.Errorf("pgoutput: handle %s of transaction %s: %w", tx.Status, tx.ID, err)
+	}
+	return nil
+}
+
+func (s *Source) row(rel *relation, tuple []tupleColumn) (*json.RawMessage, error) {
+	if tuple == nil {
+		return nil, nil
+	}
+	row := make(map[string]any, len(tuple))
+	for i, col := range tuple {
+		if i >= len(rel.msg.Columns) {
+			break
+		}
+		column := rel.msg.Columns[i]
+		switch col.Kind {
+		case columnNull:
+			row[column.Name] = nil
+		case columnUnchanged:
+			if rel.schema.RowSchema().Fields[i].Type == "array" {
+				row[column.Name] = []string{cdc.UnavailablePlaceholder}
+			} else {
+				row[column.Name] = cdc.UnavailablePlaceholder
+			}
+		default:
+			value, err := decodeColumn(s.typeMap, column.TypeOID, col.Data)
+			if err != nil {
+				return nil, fmt.Errorf("pgoutput: decode %s.%s.%s: %w", rel.msg.Namespace, rel.msg.Name, column.Name, err)
+			}
+			row[column.Name] = value
+		}
+	}
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return nil, fmt.Errorf("pgoutput: encode row: %w", err)
+	}
+	msg := json.RawMessage(raw)
+	return &msg, nil
+}
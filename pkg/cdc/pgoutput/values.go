@@ -0,0 +1,127 @@
+package pgoutput
+
+import (
+	"debez/pkg/cdc"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	microTimestampName = "io.debezium.time.MicroTimestamp"
+	zonedTimestampName = "io.debezium.time.ZonedTimestamp"
+	dateName           = "io.debezium.time.Date"
+	jsonName           = "io.debezium.data.Json"
+	uuidName           = "io.debezium.data.Uuid"
+)
+
+// columnSchema maps a Postgres type to the Kafka Connect schema Debezium uses for it
+// with the default time.precision.mode and decimal.handling.mode=string.
+func columnSchema(col relationColumn) cdc.Schema {
+	s := cdc.Schema{Field: col.Name, Optional: !col.Key}
+	switch col.TypeOID {
+	case pgtype.BoolOID:
+		s.Type = "boolean"
+	case pgtype.Int2OID:
+		s.Type = "int16"
+	case pgtype.Int4OID, pgtype.OIDOID:
+		s.Type = "int32"
+	case pgtype.Int8OID:
+		s.Type = "int64"
+	case pgtype.Float4OID:
+		s.Type = "float"
+	case pgtype.Float8OID:
+		s.Type = "double"
+	case pgtype.ByteaOID:
+		s.Type = "bytes"
+	case pgtype.TimestampOID:
+		s.Type, s.Name = "int64", microTimestampName
+	case pgtype.TimestamptzOID:
+		s.Type, s.Name = "string", zonedTimestampName
+	case pgtype.DateOID:
+		s.Type, s.Name = "int32", dateName
+	case pgtype.JSONOID, pgtype.JSONBOID:
+		s.Type, s.Name = "string", jsonName
+	case pgtype.UUIDOID:
+		s.Type, s.Name = "string", uuidName
+	case pgtype.TextArrayOID, pgtype.VarcharArrayOID, pgtype.BPCharArrayOID:
+		s.Type, s.Items = "array", &cdc.Schema{Type: "string", Optional: true}
+	case pgtype.Int4ArrayOID:
+		s.Type, s.Items = "array", &cdc.Schema{Type: "int32", Optional: true}
+	case pgtype.Int8ArrayOID:
+		s.Type, s.Items = "array", &cdc.Schema{Type: "int64", Optional: true}
+	default:
+		s.Type = "string"
+	}
+	return s
+}
+
+// relationSchema builds the envelope schema of a relation, the same shape Debezium sends
+// with schemas.enable=true. Sources use it for schema-aware decoding and change detection.
+func relationSchema(rel relationMessage, serverName string) *cdc.Schema {
+	row := cdc.Schema{
+		Type:     "struct",
+		Name:     serverName + "." + rel.Namespace + "." + rel.Name + ".Value",
+		Optional: true,
+	}
+	for _, col := range rel.Columns {
+		row.Fields = append(row.Fields, columnSchema(col))
+	}
+	before, after := row, row
+	before.Field, after.Field = "before", "after"
+	return &cdc.Schema{
+		Type: "struct",
+		Name: serverName + "." + rel.Namespace + "." + rel.Name + ".Envelope",
+		Fields: []cdc.Schema{
+			before,
+			after,
+			{Type: "struct", Field: "source", Name: "io.debezium.connector.postgresql.Source"},
+			{Type: "string", Field: "op"},
+			{Type: "int64", Field: "ts_ms", Optional: true},
+		},
+	}
+}
+
+// decodeColumn converts a text-format value into its Debezium JSON representation.
+func decodeColumn(m *pgtype.Map, oid uint32, data []byte) (any, error) {
+	switch oid {
+	case pgtype.TimestampOID, pgtype.TimestamptzOID, pgtype.DateOID:
+		var t pgtype.Timestamptz
+		var err error
+		switch oid {
+		case pgtype.TimestampOID:
+			var ts pgtype.Timestamp
+			err = m.Scan(oid, pgtype.TextFormatCode, data, &ts)
+			t = pgtype.Timestamptz{Time: ts.Time, InfinityModifier: ts.InfinityModifier, Valid: ts.Valid}
+		case pgtype.DateOID:
+			var d pgtype.Date
+			err = m.Scan(oid, pgtype.TextFormatCode, data, &d)
+			t = pgtype.Timestamptz{Time: d.Time, InfinityModifier: d.InfinityModifier, Valid: d.Valid}
+		default:
+			err = m.Scan(oid, pgtype.TextFormatCode, data, &t)
+		}
+		if err != nil || t.InfinityModifier != pgtype.Finite {
+			return string(data), nil
+		}
+		switch oid {
+		case pgtype.TimestampOID:
+			return t.Time.UnixMicro(), nil
+		case pgtype.DateOID:
+			return t.Time.Unix() / int64(24*time.Hour/time.Second), nil
+		default:
+			return t.Time.UTC().Format(time.RFC3339Nano), nil
+		}
+	case pgtype.BoolOID, pgtype.Int2OID, pgtype.Int4OID, pgtype.Int8OID, pgtype.OIDOID,
+		pgtype.Float4OID, pgtype.Float8OID, pgtype.ByteaOID,
+		pgtype.TextArrayOID, pgtype.VarcharArrayOID, pgtype.BPCharArrayOID,
+		pgtype.Int4ArrayOID, pgtype.Int8ArrayOID:
+		typ, ok := m.TypeForOID(oid)
+		if !ok {
+			return string(data), nil
+		}
+		return typ.Codec.DecodeValue(m, oid, pgtype.TextFormatCode, data)
+	default:
+		// numeric, json, uuid, enums and everything else travel as strings.
+		return string(data), nil
+	}
+}
@@ -0,0 +1,283 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	defaultPoolWorkers   = 8
+	defaultPoolQueueSize = 1024
+)
+
+// KeyFunc returns the partition key of an event. Events with the same key are handled
+// in order, an empty key waits for every earlier event and is handled alone.
+type KeyFunc func(e Event) string
+
+// KeyColumns partitions events by table and the values of the given row columns, taken
+// from the new row or, for deletes, the old one. Events without the columns, like
+// truncates, get an empty key.
+func KeyColumns(columns ...string) KeyFunc {
+	return func(e Event) string {
+		row := e.After
+		if row == nil {
+			row = e.Before
+		}
+		if row == nil {
+			return ""
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(*row, &values); err != nil {
+			return ""
+		}
+		key := e.Source.Schema + "." + e.Source.Table
+		for _, column := range columns {
+			value, ok := values[column]
+			if !ok {
+				return ""
+			}
+			key += "\x00" + string(value)
+		}
+		return key
+	}
+}
+
+// Acknowledger is a handler that finishes events after Handle returned. Sources confirm
+// a position from the callback of AckAfter instead of when Handle returns.
+type Acknowledger interface {
+	// AckAfter calls ack once every event passed to Handle before the call was handled.
+	AckAfter(ack func())
+}
+
+// ackAfter calls ack once h finished the events it was given, at once when h finishes
+// them in Handle.
+func ackAfter(h Handler, ack func()) {
+	if a, ok := h.(Acknowledger); ok {
+		a.AckAfter(ack)
+		return
+	}
+	ack()
+}
+
+type PoolConfig struct {
+	Workers int
+	// QueueSize is how many events each worker buffers, Handle blocks when the queue is full.
+	QueueSize int
+	// Key partitions the events, KeyColumns("id") when nil.
+	Key KeyFunc
+	// Transactions passes BEGIN and END to the wrapped handler after the events before them
+	// were handled. Do not put a pool in front of Dispatcher.GroupTransactions: it would
+	// buffer the events of a transaction in the order the workers finish them.
+	Transactions bool
+}
+
+type poolJob struct {
+	seq uint64
+	e   Event
+}
+
+type poolAck struct {
+	// after is the number of events that must be done before ack is called.
+	after uint64
+	ack   func()
+}
+
+// Pool hands events to a fixed set of workers, by the hash of their key, so events of
+// different rows are handled in parallel and events of one row in order. It reports
+// a position as processed only when every event before it was, see AckAfter.
+// The first failure stops the pool: Handle returns it from then on, events queued after
+// it are dropped and their positions never acknowledged. Use a new pool for every run
+// of a source, which then resumes from the last acknowledged position.
+type Pool struct {
+	next   Handler
+	cfg    PoolConfig
+	queues []chan poolJob
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	mu       sync.Mutex
+	assigned uint64
+	// doneUpTo is the number of events at the start of the stream that are all done.
+	doneUpTo uint64
+	done     map[uint64]struct{}
+	acks     []poolAck
+	err      error
+}
+
+// NewPool starts the workers, they stop with ctx or Close.
+func NewPool(ctx context.Context, next Handler, cfg PoolConfig) *Pool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultPoolWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultPoolQueueSize
+	}
+	if cfg.Key == nil {
+		cfg.Key = KeyColumns("id")
+	}
+	p := &Pool{
+		next:   next,
+		cfg:    cfg,
+		queues: make([]chan poolJob, cfg.Workers),
+		done:   make(map[uint64]struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	for i := range p.queues {
+		p.queues[i] = make(chan poolJob, cfg.QueueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+// Handle queues e for the worker of its key. It must not be called concurrently or after Close.
+func (p *Pool) Handle(ctx context.Context, e Event) error {
+	if err := p.failure(); err != nil {
+		return err
+	}
+	key := p.cfg.Key(e)
+	if key == "" {
+		if err := p.wait(ctx); err != nil {
+			return err
+		}
+		seq := p.assign()
+		err := p.next.Handle(p.ctx, e)
+		p.complete(seq, err)
+		return err
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	queue := p.queues[h.Sum32()%uint32(len(p.queues))]
+	job := poolJob{seq: p.assign(), e: e}
+	select {
+	case queue <- job:
+		return nil
+	case <-p.ctx.Done():
+		if err := p.failure(); err != nil {
+			return err
+		}
+		return p.ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HandleTransaction waits for the queued events and passes tx on when the pool was
+// configured with Transactions.
+func (p *Pool) HandleTransaction(ctx context.Context, tx TransactionEvent) error {
+	next, ok := p.next.(TransactionHandler)
+	if !p.cfg.Transactions || !ok {
+		return nil
+	}
+	if err := p.wait(ctx); err != nil {
+		return err
+	}
+	return next.HandleTransaction(p.ctx, tx)
+}
+
+// AckAfter calls ack once the workers handled the events before the call and the wrapped
+// handler, when it is an Acknowledger itself, finished them.
+func (p *Pool) AckAfter(ack func()) {
+	p.afterDone(func() { ackAfter(p.next, ack) })
+}
+
+// afterDone calls fn once the workers handled every event handed to the pool so far.
+func (p *Pool) afterDone(fn func()) {
+	p.mu.Lock()
+	if p.doneUpTo >= p.assigned {
+		p.mu.Unlock()
+		fn()
+		return
+	}
+	p.acks = append(p.acks, poolAck{after: p.assigned, ack: fn})
+	p.mu.Unlock()
+}
+
+// Close stops the workers after they handled the queued events and returns the failure
+// that stopped the pool, if any.
+func (p *Pool) Close() error {
+	p.once.Do(func() {
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	})
+	p.wg.Wait()
+	p.cancel()
+	return p.failure()
+}
+
+func (p *Pool) work(queue <-chan poolJob) {
+	defer p.wg.Done()
+	for job := range queue {
+		if p.ctx.Err() != nil {
+			continue
+		}
+		p.complete(job.seq, p.next.Handle(p.ctx, job.e))
+	}
+}
+
+// wait blocks until every event handed to the pool so far was handled.
+func (p *Pool) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	p.afterDone(func() { close(done) })
+	select {
+	case <-done:
+		return nil
+	case <-p.ctx.Done():
+		if err := p.failure(); err != nil {
+			return err
+		}
+		return p.ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) assign() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	seq := p.assigned
+	p.assigned++
+	return seq
+}
+
+func (p *Pool) complete(seq uint64, err error) {
+	p.mu.Lock()
+	if err != nil {
+		if p.err == nil {
+			p.err = fmt.Errorf("cdc: pool: %w", err)
+		}
+		p.mu.Unlock()
+		p.cancel()
+		return
+	}
+	p.done[seq] = struct{}{}
+	for {
+		if _, ok := p.done[p.doneUpTo]; !ok {
+			break
+		}
+		delete(p.done, p.doneUpTo)
+		p.doneUpTo++
+	}
+	var due []poolAck
+	for len(p.acks) > 0 && p.acks[0].after <= p.doneUpTo {
+		due = append(due, p.acks[0])
+		p.acks = p.acks[1:]
+	}
+	p.mu.Unlock()
+	for _, a := range due {
+		a.ack()
+	}
+}
+
+func (p *Pool) failure() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
@@ -0,0 +1,189 @@
+package cdc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestPool(t *testing.T, next Handler, cfg PoolConfig) *Pool {
+	t.Helper()
+	p := NewPool(context.Background(), next, cfg)
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func noopHandler() Handler {
+	return HandlerFunc(func(context.Context, Event) error { return nil })
+}
+
+// ackRecorder counts the calls of the acks it hands out.
+type ackRecorder struct {
+	mu    sync.Mutex
+	acked []int
+}
+
+func (r *ackRecorder) ack(n int) func() {
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.acked = append(r.acked, n)
+	}
+}
+
+func (r *ackRecorder) get() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.acked...)
+}
+
+func TestPoolAckAfterWithoutEventsAcksAtOnce(t *testing.T) {
+	p := newTestPool(t, noopHandler(), PoolConfig{Workers: 1})
+	var r ackRecorder
+	p.AckAfter(r.ack(1))
+	if got := r.get(); !slices.Equal(got, []int{1}) {
+		t.Fatalf("acked = %v, want [1]", got)
+	}
+}
+
+func TestPoolAckWaitsForEveryEarlierEvent(t *testing.T) {
+	p := newTestPool(t, noopHandler(), PoolConfig{Workers: 1})
+	var r ackRecorder
+	seqs := []uint64{p.assign(), p.assign(), p.assign()}
+	p.AckAfter(r.ack(1))
+
+	p.complete(seqs[2], nil)
+	p.complete(seqs[1], nil)
+	if got := r.get(); len(got) != 0 {
+		t.Fatalf("acked = %v before the first event was done", got)
+	}
+	p.complete(seqs[0], nil)
+	if got := r.get(); !slices.Equal(got, []int{1}) {
+		t.Fatalf("acked = %v, want [1]", got)
+	}
+}
+
+func TestPoolAcksFireInOrderAtTheirWatermark(t *testing.T) {
+	p := newTestPool(t, noopHandler(), PoolConfig{Workers: 1})
+	var r ackRecorder
+	first := p.assign()
+	p.AckAfter(r.ack(1))
+	second := p.assign()
+	p.AckAfter(r.ack(2))
+	third := p.assign()
+	p.AckAfter(r.ack(3))
+
+	p.complete(second, nil)
+	if got := r.get(); len(got) != 0 {
+		t.Fatalf("acked = %v, want none", got)
+	}
+	p.complete(first, nil)
+	if got := r.get(); !slices.Equal(got, []int{1, 2}) {
+		t.Fatalf("acked = %v, want [1 2]", got)
+	}
+	p.complete(third, nil)
+	if got := r.get(); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Fatalf("acked = %v, want [1 2 3]", got)
+	}
+}
+
+func TestPoolFailureStopsAcks(t *testing.T) {
+	p := newTestPool(t, noopHandler(), PoolConfig{Workers: 1})
+	var r ackRecorder
+	first := p.assign()
+	second := p.assign()
+	p.AckAfter(r.ack(1))
+
+	failure := errors.New("boom")
+	p.complete(first, failure)
+	p.complete(second, nil)
+	if got := r.get(); len(got) != 0 {
+		t.Fatalf("acked = %v after a failure", got)
+	}
+	if err := p.Handle(context.Background(), Event{}); !errors.Is(err, failure) {
+		t.Fatalf("Handle after failure = %v, want %v", err, failure)
+	}
+}
+
+func userEvent(id int) Event {
+	row := json.RawMessage(`{"id":` + strconv.Itoa(id) + `}`)
+	return Event{Op: OpUpdate, After: &row, Source: Source{Schema: "public", Table: "users"}}
+}
+
+func TestPoolAcksOnlyAfterSlowEventIsHandled(t *testing.T) {
+	release := make(chan struct{})
+	handler := HandlerFunc(func(_ context.Context, e Event) error {
+		if string(*e.After) == `{"id":1}` {
+			<-release
+		}
+		return nil
+	})
+	p := newTestPool(t, handler, PoolConfig{Workers: 4})
+	acked := make(chan struct{})
+	ctx := context.Background()
+	for id := 1; id <= 8; id++ {
+		if err := p.Handle(ctx, userEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.AckAfter(func() { close(acked) })
+
+	select {
+	case <-acked:
+		t.Fatal("acked while the first event was still being handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-acked:
+	case <-time.After(time.Second):
+		t.Fatal("not acked after every event was handled")
+	}
+}
+
+func TestPoolKeepsOrderPerKey(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	handler := HandlerFunc(func(_ context.Context, e Event) error {
+		var row struct {
+			ID  int `json:"id"`
+			Seq int `json:"seq"`
+		}
+		if err := json.Unmarshal(*e.After, &row); err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		key := strconv.Itoa(row.ID)
+		seen[key] = append(seen[key], row.Seq)
+		return nil
+	})
+	p := NewPool(context.Background(), handler, PoolConfig{Workers: 4})
+	ctx := context.Background()
+	for seq := 0; seq < 50; seq++ {
+		for id := 0; id < 10; id++ {
+			row := json.RawMessage(`{"id":` + strconv.Itoa(id) + `,"seq":` + strconv.Itoa(seq) + `}`)
+			if err := p.Handle(ctx, Event{Op: OpUpdate, After: &row, Source: Source{Schema: "public", Table: "users"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for key, seqs := range seen {
+		if len(seqs) != 50 {
+			t.Fatalf("key %s got %d events, want 50", key, len(seqs))
+		}
+		for i, seq := range seqs {
+			if seq != i {
+				t.Fatalf("key %s handled out of order: %v", key, seqs)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package cdc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxRecordingLine bounds a line of a recording, the size of the largest event.
+const maxRecordingLine = 16 << 20
+
+// Recording is a line of a recorded stream: an event with its schema, or transaction metadata.
+type Recording struct {
+	Seq         int64             `json:"seq"`
+	ReceivedAt  time.Time         `json:"received_at"`
+	Event       *Event            `json:"event,omitempty"`
+	Schema      *Schema           `json:"schema,omitempty"`
+	Transaction *TransactionEvent `json:"transaction,omitempty"`
+}
+
+// Record returns a middleware that writes every event and transaction event the wrapped
+// handler receives to w, one Recording per line, before passing it on. Wrap the handler the
+// source feeds, so the recording keeps the order the events arrived in.
+func Record(w io.Writer) Middleware {
+	r := &recorder{w: w}
+	return func(next Handler) Handler {
+		return &recordingHandler{recorder: r, next: next}
+	}
+}
+
+type recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	seq int64
+}
+
+func (r *recorder) write(rec Recording) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	rec.Seq = r.seq
+	rec.ReceivedAt = time.Now().UTC()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("cdc: record: %w", err)
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("cdc: record: %w", err)
+	}
+	return nil
+}
+
+type recordingHandler struct {
+	recorder *recorder
+	next     Handler
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, e Event) error {
+	if err := h.recorder.write(Recording{Event: &e, Schema: e.Schema}); err != nil {
+		return err
+	}
+	return h.next.Handle(ctx, e)
+}
+
+func (h *recordingHandler) HandleTransaction(ctx context.Context, tx TransactionEvent) error {
+	if err := h.recorder.write(Recording{Transaction: &tx}); err != nil {
+		return err
+	}
+	if next, ok := h.next.(TransactionHandler); ok {
+		return next.HandleTransaction(ctx, tx)
+	}
+	return nil
+}
+
+func (h *recordingHandler) AckAfter(ack func()) {
+	ackAfter(h.next, ack)
+}
+
+// Replay feeds a recording to h and returns how many lines it replayed. Speed scales the
+// pauses between the events: 1 keeps the original pace, 10 is ten times faster and 0 does
+// not wait at all. Replay stops at the first error of h.
+func Replay(ctx context.Context, r io.Reader, h Handler, speed float64) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxRecordingLine)
+	txHandler, _ := h.(TransactionHandler)
+
+	var replayed, lineNo int
+	var previous time.Time
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec Recording
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return replayed, fmt.Errorf("cdc.Replay line %d: %w", lineNo, err)
+		}
+		if rec.Event == nil && rec.Transaction == nil {
+			return replayed, fmt.Errorf("cdc.Replay line %d: no event", lineNo)
+		}
+
+		if speed > 0 && !previous.IsZero() && rec.ReceivedAt.After(previous) {
+			timer := time.NewTimer(time.Duration(float64(rec.ReceivedAt.Sub(previous)) / speed))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return replayed, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if !rec.ReceivedAt.IsZero() {
+			previous = rec.ReceivedAt
+		}
+
+		var err error
+		switch {
+		case rec.Transaction != nil:
+			if txHandler != nil {
+				err = txHandler.HandleTransaction(ctx, *rec.Transaction)
+			}
+		default:
+			e := *rec.Event
+			e.Schema = rec.Schema
+			err = h.Handle(ctx, e)
+		}
+		if err != nil {
+			return replayed, fmt.Errorf("cdc.Replay seq %d: %w", rec.Seq, err)
+		}
+		replayed++
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return replayed, fmt.Errorf("cdc.Replay line %d: longer than %d bytes", lineNo+1, maxRecordingLine)
+		}
+		return replayed, fmt.Errorf("cdc.Replay: %w", err)
+	}
+	return replayed, nil
+}
@@ -0,0 +1,46 @@
+package cdc
+
+// Schema is a Kafka Connect JSON schema as sent with schemas.enable=true.
+type Schema struct {
+	Type       string            `json:"type"`
+	Name       string            `json:"name,omitempty"`
+	Optional   bool              `json:"optional"`
+	Field      string            `json:"field,omitempty"`
+	Version    int               `json:"version,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Fields     []Schema          `json:"fields,omitempty"`
+	Items      *Schema           `json:"items,omitempty"`
+}
+
+// FieldSchema returns the schema of a struct field or nil.
+func (s *Schema) FieldSchema(name string) *Schema {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Fields {
+		if s.Fields[i].Field == name {
+			return &s.Fields[i]
+		}
+	}
+	return nil
+}
+
+// RowSchema returns the schema of the before/after rows of an envelope schema.
+func (s *Schema) RowSchema() *Schema {
+	if row := s.FieldSchema("after"); row != nil {
+		return row
+	}
+	return s.FieldSchema("before")
+}
+
+// FieldNames returns the names of the fields of a struct schema in order.
+func (s *Schema) FieldNames() []string {
+	if s == nil {
+		return nil
+	}
+	names := make([]string, 0, len(s.Fields))
+	for _, f := range s.Fields {
+		names = append(names, f.Field)
+	}
+	return names
+}
@@ -0,0 +1,252 @@
+package cdc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SchemaField is a column of a captured table as described by the event schema.
+type SchemaField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Semantic is the logical type, e.g. io.debezium.time.MicroTimestamp.
+	Semantic string `json:"semantic,omitempty"`
+	Optional bool   `json:"optional"`
+}
+
+// TableSchema is a version of the row schema of a captured table.
+type TableSchema struct {
+	Schema      string        `json:"schema"`
+	Table       string        `json:"table"`
+	Version     int           `json:"version"`
+	Fields      []SchemaField `json:"fields"`
+	Fingerprint string        `json:"fingerprint"`
+	DetectedAt  time.Time     `json:"detected_at"`
+}
+
+// Field returns the named column or nil.
+func (s *TableSchema) Field(name string) *SchemaField {
+	if s == nil {
+		return nil
+	}
+	for i := range s.Fields {
+		if s.Fields[i].Name == name {
+			return &s.Fields[i]
+		}
+	}
+	return nil
+}
+
+// SchemaChange is a new version of a table schema. Previous is nil for the first version seen.
+type SchemaChange struct {
+	Previous *TableSchema
+	Current  TableSchema
+	Added    []string
+	Removed  []string
+	// Changed lists columns whose type, semantic type or nullability changed.
+	Changed []string
+}
+
+// SchemaStore persists table schema versions.
+type SchemaStore interface {
+	Latest(ctx context.Context, schema, table string) (TableSchema, bool, error)
+	Save(ctx context.Context, version TableSchema) error
+}
+
+// SchemaListener is told about new table schema versions before the event that carries one
+// reaches the handlers.
+type SchemaListener interface {
+	SchemaChanged(ctx context.Context, change SchemaChange) error
+}
+
+type SchemaListenerFunc func(ctx context.Context, change SchemaChange) error
+
+func (f SchemaListenerFunc) SchemaChanged(ctx context.Context, change SchemaChange) error {
+	return f(ctx, change)
+}
+
+// TableSchemaOf returns the row schema of the event, false when it came without a schema.
+func TableSchemaOf(e Event) (TableSchema, bool) {
+	row := e.Schema.RowSchema()
+	if row == nil || e.Source.Table == "" {
+		return TableSchema{}, false
+	}
+	fields := make([]SchemaField, 0, len(row.Fields))
+	for _, f := range row.Fields {
+		fields = append(fields, SchemaField{Name: f.Field, Type: f.Type, Semantic: f.Name, Optional: f.Optional})
+	}
+	return TableSchema{
+		Schema:      e.Source.Schema,
+		Table:       e.Source.Table,
+		Fields:      fields,
+		Fingerprint: fingerprint(fields),
+	}, true
+}
+
+func fingerprint(fields []SchemaField) string {
+	h := sha256.New()
+	for _, f := range fields {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%t\n", f.Name, f.Type, f.Semantic, f.Optional)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// DiffSchemas returns the change from previous to current.
+func DiffSchemas(previous *TableSchema, current TableSchema) SchemaChange {
+	change := SchemaChange{Previous: previous, Current: current}
+	for _, f := range current.Fields {
+		old := previous.Field(f.Name)
+		switch {
+		case old == nil:
+			change.Added = append(change.Added, f.Name)
+		case *old != f:
+			change.Changed = append(change.Changed, f.Name)
+		}
+	}
+	if previous != nil {
+		for _, f := range previous.Fields {
+			if current.Field(f.Name) == nil {
+				change.Removed = append(change.Removed, f.Name)
+			}
+		}
+	}
+	return change
+}
+
+type tableKey struct {
+	schema, table string
+}
+
+// SchemaDetector compares the row schema of every event with the last known version of its
+// table. A new version is passed to the listeners and then saved, so a listener that fails
+// sees it again with the retried event.
+type SchemaDetector struct {
+	store     SchemaStore
+	listeners []SchemaListener
+
+	mu    sync.Mutex
+	known map[tableKey]TableSchema
+}
+
+func NewSchemaDetector(store SchemaStore, listeners ...SchemaListener) *SchemaDetector {
+	return &SchemaDetector{
+		store:     store,
+		listeners: listeners,
+		known:     make(map[tableKey]TableSchema),
+	}
+}
+
+// Observe checks the schema of e and returns the change it introduced, nil when there was none.
+func (d *SchemaDetector) Observe(ctx context.Context, e Event) (*SchemaChange, error) {
+	current, ok := TableSchemaOf(e)
+	if !ok {
+		return nil, nil
+	}
+	key := tableKey{current.Schema, current.Table}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	previous, ok := d.known[key]
+	if !ok {
+		latest, found, err := d.store.Latest(ctx, key.schema, key.table)
+		if err != nil {
+			return nil, fmt.Errorf("cdc: load schema of %s.%s: %w", key.schema, key.table, err)
+		}
+		if found {
+			previous, ok = latest, true
+			d.known[key] = latest
+		}
+	}
+	if ok && previous.Fingerprint == current.Fingerprint {
+		return nil, nil
+	}
+
+	current.Version = 1
+	current.DetectedAt = time.Now().UTC()
+	var change SchemaChange
+	if ok {
+		current.Version = previous.Version + 1
+		change = DiffSchemas(&previous, current)
+	} else {
+		change = DiffSchemas(nil, current)
+	}
+	var errs []error
+	for _, l := range d.listeners {
+		if err := l.SchemaChanged(ctx, change); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("cdc: schema change of %s.%s: %w", key.schema, key.table, err)
+	}
+	if err := d.store.Save(ctx, current); err != nil {
+		return nil, fmt.Errorf("cdc: save schema of %s.%s: %w", key.schema, key.table, err)
+	}
+	d.known[key] = current
+	return &change, nil
+}
+
+// Middleware detects schema changes before the wrapped handler sees the event. Wrap the
+// dispatcher with it, not a single handler, so every table is checked once per event.
+// Transaction metadata is passed on when the wrapped handler takes it.
+func (d *SchemaDetector) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return &detectingHandler{detector: d, next: next}
+	}
+}
+
+type detectingHandler struct {
+	detector *SchemaDetector
+	next     Handler
+}
+
+func (h *detectingHandler) Handle(ctx context.Context, e Event) error {
+	if _, err := h.detector.Observe(ctx, e); err != nil {
+		return err
+	}
+	return h.next.Handle(ctx, e)
+}
+
+func (h *detectingHandler) AckAfter(ack func()) {
+	ackAfter(h.next, ack)
+}
+
+func (h *detectingHandler) HandleTransaction(ctx context.Context, tx TransactionEvent) error {
+	if next, ok := h.next.(TransactionHandler); ok {
+		return next.HandleTransaction(ctx, tx)
+	}
+	return nil
+}
+
+// MemorySchemaStore keeps schema versions in memory, for tests and sources without a database.
+type MemorySchemaStore struct {
+	mu       sync.Mutex
+	versions map[tableKey][]TableSchema
+}
+
+func NewMemorySchemaStore() *MemorySchemaStore {
+	return &MemorySchemaStore{versions: make(map[tableKey][]TableSchema)}
+}
+
+func (s *MemorySchemaStore) Latest(_ context.Context, schema, table string) (TableSchema, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions := s.versions[tableKey{schema, table}]
+	if len(versions) == 0 {
+		return TableSchema{}, false, nil
+	}
+	return versions[len(versions)-1], true, nil
+}
+
+func (s *MemorySchemaStore) Save(_ context.Context, version TableSchema) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := tableKey{version.Schema, version.Table}
+	s.versions[key] = append(s.versions[key], version)
+	return nil
+}
@@ -0,0 +1,151 @@
+package cdc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func schemaEvent(table string, fields ...Schema) Event {
+	return Event{
+		Op:     OpCreate,
+		Source: Source{Schema: "public", Table: table},
+		Schema: &Schema{Type: "struct", Fields: []Schema{{Type: "struct", Field: "after", Fields: fields}}},
+	}
+}
+
+func column(name, typ string, optional bool) Schema {
+	return Schema{Field: name, Type: typ, Optional: optional}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	previous := TableSchema{Fields: []SchemaField{
+		{Name: "id", Type: "int64"},
+		{Name: "email", Type: "string"},
+		{Name: "created_at", Type: "int64", Semantic: "io.debezium.time.MicroTimestamp"},
+	}}
+	tests := []struct {
+		name        string
+		previous    *TableSchema
+		current     []SchemaField
+		wantAdded   []string
+		wantRemoved []string
+		wantChanged []string
+	}{
+		{
+			name:      "first version",
+			current:   []SchemaField{{Name: "id", Type: "int64"}},
+			wantAdded: []string{"id"},
+		},
+		{
+			name:     "unchanged",
+			previous: &previous,
+			current:  previous.Fields,
+		},
+		{
+			name:     "added and removed",
+			previous: &previous,
+			current: []SchemaField{
+				{Name: "id", Type: "int64"},
+				{Name: "created_at", Type: "int64", Semantic: "io.debezium.time.MicroTimestamp"},
+				{Name: "name", Type: "string", Optional: true},
+			},
+			wantAdded:   []string{"name"},
+			wantRemoved: []string{"email"},
+		},
+		{
+			name:     "type, semantic type and nullability",
+			previous: &previous,
+			current: []SchemaField{
+				{Name: "id", Type: "int32"},
+				{Name: "email", Type: "string", Optional: true},
+				{Name: "created_at", Type: "int64", Semantic: "io.debezium.time.NanoTimestamp"},
+			},
+			wantChanged: []string{"id", "email", "created_at"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := DiffSchemas(tt.previous, TableSchema{Fields: tt.current})
+			if !slices.Equal(change.Added, tt.wantAdded) || !slices.Equal(change.Removed, tt.wantRemoved) || !slices.Equal(change.Changed, tt.wantChanged) {
+				t.Fatalf("added %v, removed %v, changed %v, want %v, %v, %v",
+					change.Added, change.Removed, change.Changed, tt.wantAdded, tt.wantRemoved, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestSchemaDetectorVersions(t *testing.T) {
+	store := NewMemorySchemaStore()
+	var changes []SchemaChange
+	d := NewSchemaDetector(store, SchemaListenerFunc(func(_ context.Context, change SchemaChange) error {
+		changes = append(changes, change)
+		return nil
+	}))
+	ctx := context.Background()
+	id := column("id", "int64", false)
+	email := column("email", "string", false)
+
+	steps := []struct {
+		name        string
+		event       Event
+		wantVersion int
+	}{
+		{name: "first", event: schemaEvent("users", id), wantVersion: 1},
+		{name: "same schema", event: schemaEvent("users", id), wantVersion: 0},
+		{name: "added column", event: schemaEvent("users", id, email), wantVersion: 2},
+		{name: "other table", event: schemaEvent("orders", id), wantVersion: 1},
+		{name: "without schema", event: Event{Op: OpCreate, Source: Source{Table: "users"}}, wantVersion: 0},
+	}
+	for _, step := range steps {
+		change, err := d.Observe(ctx, step.event)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if step.wantVersion == 0 {
+			if change != nil {
+				t.Fatalf("%s: change %+v, want none", step.name, change)
+			}
+			continue
+		}
+		if change == nil || change.Current.Version != step.wantVersion {
+			t.Fatalf("%s: change %+v, want version %d", step.name, change, step.wantVersion)
+		}
+	}
+	if len(changes) != 3 || !slices.Equal(changes[1].Added, []string{"email"}) {
+		t.Fatalf("listener saw %+v", changes)
+	}
+
+	// A new detector continues from the stored versions.
+	restarted := NewSchemaDetector(store)
+	if change, err := restarted.Observe(ctx, schemaEvent("users", id, email)); err != nil || change != nil {
+		t.Fatalf("Observe after restart = %+v, %v, want no change", change, err)
+	}
+}
+
+func TestSchemaDetectorRetriesFailedListener(t *testing.T) {
+	store := NewMemorySchemaStore()
+	failure := errors.New("alert failed")
+	fail := true
+	d := NewSchemaDetector(store, SchemaListenerFunc(func(context.Context, SchemaChange) error {
+		if fail {
+			return failure
+		}
+		return nil
+	}))
+	ctx := context.Background()
+	e := schemaEvent("users", column("id", "int64", false))
+
+	if _, err := d.Observe(ctx, e); !errors.Is(err, failure) {
+		t.Fatalf("Observe = %v, want %v", err, failure)
+	}
+	if _, found, _ := store.Latest(ctx, "public", "users"); found {
+		t.Fatal("version saved although a listener failed")
+	}
+	fail = false
+	change, err := d.Observe(ctx, e)
+	if err != nil || change == nil || change.Current.Version != 1 {
+		t.Fatalf("retried Observe = %+v, %v, want version 1", change, err)
+	}
+}
@@ -0,0 +1,116 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"debez/pkg/cdc"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultBulkTimeout  = 30 * time.Second
+	maxBulkResponseBody = 64 << 20
+)
+
+// BulkEncoder builds the body of a bulk request and reads the response of the endpoint.
+type BulkEncoder interface {
+	ContentType() string
+	// Encode returns the body and, for every operation in it, the index of its event.
+	// Events that cannot be encoded are returned in rejected, the others are still sent.
+	Encode(events []cdc.Event) (body []byte, items []int, rejected []ItemError, err error)
+	// Decode checks a 2xx response and returns a *PartialError for the operations that failed,
+	// indexed into events through items.
+	Decode(body []byte, items []int) error
+}
+
+type BulkHTTPConfig struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+	Encoder BulkEncoder
+}
+
+// BulkHTTP posts every batch in one request to a bulk endpoint. 429 and 5xx responses
+// are retried, other failed responses are permanent.
+type BulkHTTP struct {
+	cfg BulkHTTPConfig
+}
+
+func NewBulkHTTP(cfg BulkHTTPConfig) *BulkHTTP {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: defaultBulkTimeout}
+	}
+	return &BulkHTTP{cfg: cfg}
+}
+
+func (s *BulkHTTP) Write(ctx context.Context, events []cdc.Event) error {
+	body, items, rejected, err := s.cfg.Encoder.Encode(events)
+	if err != nil {
+		return Permanent(fmt.Errorf("sink.BulkHTTP encode: %w", err))
+	}
+	if len(items) > 0 {
+		err := s.post(ctx, body, items)
+		var partial *PartialError
+		var permanent *PermanentError
+		switch {
+		case err == nil:
+		case errors.As(err, &partial):
+			rejected = append(rejected, partial.Items...)
+		case len(rejected) == 0:
+			return err
+		default:
+			// Keep the rejected events out of the retries of the request.
+			for _, index := range items {
+				rejected = append(rejected, ItemError{Index: index, Err: err, Retryable: !errors.As(err, &permanent)})
+			}
+		}
+	}
+	if len(rejected) > 0 {
+		return &PartialError{Items: rejected}
+	}
+	return nil
+}
+
+func (s *BulkHTTP) post(ctx context.Context, body []byte, items []int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(fmt.Errorf("sink.BulkHTTP request: %w", err))
+	}
+	req.Header.Set("Content-Type", s.cfg.Encoder.ContentType())
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sink.BulkHTTP post: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxBulkResponseBody))
+	if err != nil {
+		return fmt.Errorf("sink.BulkHTTP read response: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("sink.BulkHTTP: status %d: %s", resp.StatusCode, truncate(respBody))
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return Permanent(fmt.Errorf("sink.BulkHTTP: status %d: %s", resp.StatusCode, truncate(respBody)))
+	}
+	return s.cfg.Encoder.Decode(respBody, items)
+}
+
+func (s *BulkHTTP) Close() error {
+	s.cfg.Client.CloseIdleConnections()
+	return nil
+}
+
+func truncate(body []byte) string {
+	const limit = 512
+	if len(body) > limit {
+		return string(body[:limit]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,162 @@
+package sink
+
+import (
+	"bytes"
+	"debez/pkg/cdc"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type ElasticsearchConfig struct {
+	// URL is the cluster address, requests go to <URL>/_bulk.
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+	// Index is the index of every event, "<schema>.<table>" when empty.
+	Index string
+	// IDColumn is the row column used as document id, "id" when empty.
+	IDColumn string
+}
+
+// NewElasticsearch returns a sink that indexes the new rows and deletes the old ones with
+// the _bulk API. Documents are versioned with the event LSN, so a redelivered or reordered
+// event never overwrites a newer one.
+func NewElasticsearch(cfg ElasticsearchConfig) *BulkHTTP {
+	if cfg.IDColumn == "" {
+		cfg.IDColumn = "id"
+	}
+	return NewBulkHTTP(BulkHTTPConfig{
+		URL:     strings.TrimRight(cfg.URL, "/") + "/_bulk",
+		Headers: cfg.Headers,
+		Client:  cfg.Client,
+		Encoder: &ElasticsearchEncoder{Index: cfg.Index, IDColumn: cfg.IDColumn},
+	})
+}
+
+// ElasticsearchEncoder encodes events as _bulk index and delete operations. Truncates
+// are skipped, the API cannot express them.
+type ElasticsearchEncoder struct {
+	Index    string
+	IDColumn string
+}
+
+type esAction struct {
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	Version     uint64 `json:"version,omitempty"`
+	VersionType string `json:"version_type,omitempty"`
+}
+
+func (enc *ElasticsearchEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (enc *ElasticsearchEncoder) Encode(events []cdc.Event) ([]byte, []int, []ItemError, error) {
+	var body bytes.Buffer
+	var items []int
+	var rejected []ItemError
+	for i, e := range events {
+		if e.Op == cdc.OpTruncate || e.Op == cdc.OpMessage {
+			continue
+		}
+		row := e.After
+		if e.Op == cdc.OpDelete {
+			row = e.Before
+		}
+		id, err := enc.documentID(row)
+		if err != nil {
+			rejected = append(rejected, ItemError{Index: i, Err: err})
+			continue
+		}
+		action := esAction{Index: enc.Index, ID: id}
+		if action.Index == "" {
+			action.Index = e.Source.Schema + "." + e.Source.Table
+		}
+		if lsn, ok := e.Source.LSN(); ok {
+			action.Version, action.VersionType = uint64(lsn), "external"
+		}
+		op := "index"
+		if e.Op == cdc.OpDelete {
+			op = "delete"
+		}
+		line, err := json.Marshal(map[string]esAction{op: action})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		body.Write(line)
+		body.WriteByte('\n')
+		if op == "index" {
+			body.Write(bytes.TrimSpace(*row))
+			body.WriteByte('\n')
+		}
+		items = append(items, i)
+	}
+	return body.Bytes(), items, rejected, nil
+}
+
+func (enc *ElasticsearchEncoder) documentID(row *json.RawMessage) (string, error) {
+	if row == nil {
+		return "", errors.New("event has no row")
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(*row, &values); err != nil {
+		return "", fmt.Errorf("row: %w", err)
+	}
+	raw, ok := values[enc.IDColumn]
+	if !ok || bytes.Equal(raw, []byte("null")) {
+		return "", fmt.Errorf("row has no %s column", enc.IDColumn)
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	return string(raw), nil
+}
+
+type esResponse struct {
+	Errors bool                      `json:"errors"`
+	Items  []map[string]esItemResult `json:"items"`
+}
+
+type esItemResult struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// Decode treats version conflicts as success, the document already has a newer version,
+// and deletes of missing documents too.
+func (enc *ElasticsearchEncoder) Decode(body []byte, items []int) error {
+	var resp esResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("sink.Elasticsearch decode response: %w", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	if len(resp.Items) != len(items) {
+		return fmt.Errorf("sink.Elasticsearch: %d results for %d operations", len(resp.Items), len(items))
+	}
+	var failed []ItemError
+	for i, item := range resp.Items {
+		for op, result := range item {
+			switch {
+			case result.Status >= 200 && result.Status <= 299,
+				result.Status == http.StatusConflict,
+				op == "delete" && result.Status == http.StatusNotFound:
+				continue
+			}
+			failed = append(failed, ItemError{
+				Index:     items[i],
+				Err:       fmt.Errorf("sink.Elasticsearch: %s status %d: %s", op, result.Status, result.Error),
+				Retryable: result.Status == http.StatusTooManyRequests || result.Status >= http.StatusInternalServerError,
+			})
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &PartialError{Items: failed}
+}
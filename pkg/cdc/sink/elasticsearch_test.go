@@ -0,0 +1,168 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestElasticsearchDecode(t *testing.T) {
+	enc := &ElasticsearchEncoder{IDColumn: "id"}
+	body := `{"errors":true,"items":[
+		{"index":{"status":201}},
+		{"index":{"status":409,"error":{"type":"version_conflict_engine_exception"}}},
+		{"delete":{"status":404}},
+		{"index":{"status":404,"error":{"type":"index_not_found_exception"}}},
+		{"index":{"status":429,"error":{"type":"es_rejected_execution_exception"}}},
+		{"index":{"status":503,"error":{"type":"unavailable_shards_exception"}}},
+		{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}
+	]}`
+	// Operations map to the events 10..16 of the batch.
+	items := []int{10, 11, 12, 13, 14, 15, 16}
+
+	err := enc.Decode([]byte(body), items)
+	var partial *PartialError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Decode = %v, want *PartialError", err)
+	}
+	type result struct {
+		index     int
+		retryable bool
+	}
+	var got []result
+	for _, item := range partial.Items {
+		got = append(got, result{item.Index, item.Retryable})
+	}
+	want := []result{{13, false}, {14, true}, {15, true}, {16, false}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("failed items = %v, want %v", got, want)
+	}
+}
+
+func TestElasticsearchDecodeWithoutErrors(t *testing.T) {
+	enc := &ElasticsearchEncoder{IDColumn: "id"}
+	if err := enc.Decode([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`), []int{0}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestElasticsearchDecodeCountMismatch(t *testing.T) {
+	enc := &ElasticsearchEncoder{IDColumn: "id"}
+	err := enc.Decode([]byte(`{"errors":true,"items":[{"index":{"status":500}}]}`), []int{0, 1})
+	var partial *PartialError
+	if err == nil || errors.As(err, &partial) {
+		t.Fatalf("Decode = %v, want a whole-batch error", err)
+	}
+}
+
+// bulkServer answers _bulk requests with the queued responses and records the request bodies.
+type bulkServer struct {
+	mu        sync.Mutex
+	responses []bulkResponse
+	bodies    []string
+}
+
+type bulkResponse struct {
+	status int
+	body   string
+}
+
+func (s *bulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	s.bodies = append(s.bodies, string(body))
+	resp := bulkResponse{status: http.StatusOK, body: `{"errors":false,"items":[]}`}
+	if len(s.responses) > 0 {
+		resp = s.responses[0]
+		s.responses = s.responses[1:]
+	}
+	s.mu.Unlock()
+	w.WriteHeader(resp.status)
+	_, _ = io.WriteString(w, resp.body)
+}
+
+func newElasticsearchBatcher(t *testing.T, server *bulkServer, deadLetters *memoryDeadLetters) *Batcher {
+	t.Helper()
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	es := NewElasticsearch(ElasticsearchConfig{URL: ts.URL, Index: "users"})
+	return NewBatcher(es, testConfig(deadLetters))
+}
+
+func TestElasticsearchRetriesRejectedItemsOnly(t *testing.T) {
+	server := &bulkServer{responses: []bulkResponse{
+		{status: http.StatusOK, body: `{"errors":true,"items":[
+			{"index":{"status":201}},
+			{"index":{"status":429}},
+			{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}
+		]}`},
+		{status: http.StatusOK, body: `{"errors":false,"items":[{"index":{"status":201}}]}`},
+	}}
+	deadLetters := &memoryDeadLetters{}
+	b := newElasticsearchBatcher(t, server, deadLetters)
+	ctx := context.Background()
+	for id := 0; id < 3; id++ {
+		_ = b.Handle(ctx, testEvent(id))
+	}
+	b.Flush(ctx)
+
+	if len(server.bodies) != 2 {
+		t.Fatalf("requests = %d, want 2", len(server.bodies))
+	}
+	retry := server.bodies[1]
+	if !strings.Contains(retry, `"_id":"1"`) || strings.Contains(retry, `"_id":"0"`) || strings.Contains(retry, `"_id":"2"`) {
+		t.Fatalf("retry request = %s, want only document 1", retry)
+	}
+	if !strings.Contains(retry, `"version":101`) || !strings.Contains(retry, `"version_type":"external"`) {
+		t.Fatalf("retry request = %s, want the LSN as external version", retry)
+	}
+	if len(deadLetters.letters) != 1 || string(*deadLetters.letters[0].Event.After) != `{"id":2}` {
+		t.Fatalf("dead letters = %v, want document 2", deadLetters.letters)
+	}
+}
+
+func TestElasticsearchRetriesUnavailableCluster(t *testing.T) {
+	server := &bulkServer{responses: []bulkResponse{
+		{status: http.StatusServiceUnavailable, body: `{"error":"unavailable"}`},
+		{status: http.StatusOK, body: `{"errors":false,"items":[{"index":{"status":201}},{"delete":{"status":404}}]}`},
+	}}
+	deadLetters := &memoryDeadLetters{}
+	b := newElasticsearchBatcher(t, server, deadLetters)
+	ctx := context.Background()
+	_ = b.Handle(ctx, testEvent(0))
+	deleted := testEvent(1)
+	deleted.Op, deleted.Before, deleted.After = "d", deleted.After, nil
+	_ = b.Handle(ctx, deleted)
+	b.Flush(ctx)
+
+	if len(server.bodies) != 2 || server.bodies[0] != server.bodies[1] {
+		t.Fatalf("requests = %q, want the same batch twice", server.bodies)
+	}
+	if len(deadLetters.letters) != 0 {
+		t.Fatalf("dead letters = %v, want none", deadLetters.letters)
+	}
+}
+
+func TestElasticsearchBadRequestIsDeadLettered(t *testing.T) {
+	server := &bulkServer{responses: []bulkResponse{
+		{status: http.StatusBadRequest, body: `{"error":"illegal_argument_exception"}`},
+	}}
+	deadLetters := &memoryDeadLetters{}
+	b := newElasticsearchBatcher(t, server, deadLetters)
+	ctx := context.Background()
+	_ = b.Handle(ctx, testEvent(0))
+	b.Flush(ctx)
+
+	if len(server.bodies) != 1 {
+		t.Fatalf("requests = %d, want 1", len(server.bodies))
+	}
+	if len(deadLetters.letters) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(deadLetters.letters))
+	}
+}
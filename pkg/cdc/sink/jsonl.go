@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"debez/pkg/cdc"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJSONLMaxBytes = 100 << 20
+	jsonlTimeFormat      = "20060102T150405.000000000Z"
+)
+
+type JSONLConfig struct {
+	Dir string
+	// Prefix starts the file names, <prefix>-<UTC time>.jsonl.
+	Prefix string
+	// MaxBytes and MaxAge start a new file when the current one is that large or old. A zero MaxAge never rotates by age.
+	MaxBytes int64
+	MaxAge   time.Duration
+	// Sync fsyncs the file after every batch.
+	Sync bool
+}
+
+// JSONL appends events to rotating newline-delimited JSON files, one Debezium envelope
+// per line. A retried batch may be appended twice.
+type JSONL struct {
+	cfg JSONLConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	w      *bufio.Writer
+	size   int64
+	opened time.Time
+}
+
+func NewJSONL(cfg JSONLConfig) (*JSONL, error) {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultJSONLMaxBytes
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "events"
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("sink.NewJSONL: %w", err)
+	}
+	return &JSONL{cfg: cfg}, nil
+}
+
+func (s *JSONL) Write(_ context.Context, events []cdc.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return Permanent(fmt.Errorf("sink.JSONL marshal: %w", err))
+		}
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		if _, err := s.w.Write(line); err != nil {
+			return fmt.Errorf("sink.JSONL write: %w", err)
+		}
+		s.size += int64(len(line))
+	}
+	if s.w == nil {
+		return nil
+	}
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("sink.JSONL flush: %w", err)
+	}
+	if s.cfg.Sync {
+		if err := s.file.Sync(); err != nil {
+			return fmt.Errorf("sink.JSONL sync: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *JSONL) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.close()
+}
+
+// rotate opens a new file when there is none or the current one is full or too old.
+func (s *JSONL) rotate() error {
+	if s.file != nil && s.size < s.cfg.MaxBytes && (s.cfg.MaxAge <= 0 || time.Since(s.opened) < s.cfg.MaxAge) {
+		return nil
+	}
+	if err := s.close(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	name := filepath.Join(s.cfg.Dir, s.cfg.Prefix+"-"+now.Format(jsonlTimeFormat)+".jsonl")
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("sink.JSONL open: %w", err)
+	}
+	s.file, s.w, s.size, s.opened = file, bufio.NewWriter(file), 0, now
+	return nil
+}
+
+func (s *JSONL) close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.w.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file, s.w = nil, nil
+	if err != nil {
+		return fmt.Errorf("sink.JSONL close: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// Package sink writes change events to external systems in batches.
+package sink
+
+import (
+	"context"
+	"debez/pkg/cdc"
+	"debez/pkg/logger"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultBatchSize     = 500
+	defaultFlushInterval = time.Second
+	defaultRetries       = 5
+	defaultBackoff       = 500 * time.Millisecond
+	defaultMaxBackoff    = 30 * time.Second
+)
+
+// Sink writes batches of change events. Write must be safe to repeat with the same events,
+// the batcher retries failed batches.
+type Sink interface {
+	Write(ctx context.Context, events []cdc.Event) error
+	Close() error
+}
+
+// ItemError is the failure of one event of a batch, Index is its position in the batch.
+type ItemError struct {
+	Index     int
+	Err       error
+	Retryable bool
+}
+
+// PartialError is returned by a sink that wrote only part of a batch. Events not listed
+// were written.
+type PartialError struct {
+	Items []ItemError
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("sink: %d events of the batch failed, first: %v", len(e.Items), e.Items[0].Err)
+}
+
+// PermanentError is a failure retrying cannot fix, like a rejected request.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+type Config struct {
+	// Name identifies the sink in logs and dead letters, use the name it is registered with.
+	Name string
+	// BatchSize flushes a batch when it is full, FlushInterval at the latest after that long.
+	BatchSize     int
+	FlushInterval time.Duration
+	// Retries is how many times a failed batch is written again, Backoff doubles after every try up to MaxBackoff.
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// DeadLetters receives the events that still failed after the retries. They are only
+	// logged when it is nil.
+	DeadLetters cdc.DeadLetterStore
+}
+
+// Batcher is a cdc.Handler that collects events into batches for a Sink. Handle returns
+// once the event is buffered and blocks while a full batch is written, so a slow sink
+// slows the stream down. It is a cdc.Acknowledger: a source confirms a position only once
+// the events before it were written or dead-lettered, so buffered events are redelivered
+// after a crash. Sources that take Handle returning as done lose them.
+type Batcher struct {
+	sink Sink
+	cfg  Config
+
+	mu     sync.Mutex
+	buffer []cdc.Event
+	// received counts the buffered events, flushed the ones a finished flush took.
+	received uint64
+	flushed  uint64
+	acks     []batchAck
+	// writeMu keeps batches in order.
+	writeMu sync.Mutex
+}
+
+type batchAck struct {
+	after uint64
+	ack   func()
+}
+
+func NewBatcher(sink Sink, cfg Config) *Batcher {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	if cfg.Retries <= 0 {
+		cfg.Retries = defaultRetries
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	return &Batcher{
+		sink: sink,
+		cfg:  cfg,
+	}
+}
+
+// Handle buffers e. A replayed dead letter is written at once and its failure returned.
+func (b *Batcher) Handle(ctx context.Context, e cdc.Event) error {
+	if cdc.IsReplay(ctx) {
+		b.writeMu.Lock()
+		defer b.writeMu.Unlock()
+		if failed := b.write(ctx, []cdc.Event{e}); len(failed) > 0 {
+			return failed[0].Err
+		}
+		return nil
+	}
+
+	b.mu.Lock()
+	b.buffer = append(b.buffer, e)
+	b.received++
+	full := len(b.buffer) >= b.cfg.BatchSize
+	b.mu.Unlock()
+	if full {
+		b.Flush(ctx)
+	}
+	return nil
+}
+
+// AckAfter calls ack once the events buffered before the call were flushed.
+func (b *Batcher) AckAfter(ack func()) {
+	b.mu.Lock()
+	if b.flushed >= b.received {
+		b.mu.Unlock()
+		ack()
+		return
+	}
+	b.acks = append(b.acks, batchAck{after: b.received, ack: ack})
+	b.mu.Unlock()
+}
+
+// Run flushes every FlushInterval until ctx is done, then flushes what is left and closes the sink.
+func (b *Batcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			b.Flush(context.WithoutCancel(ctx))
+			if err := b.sink.Close(); err != nil {
+				logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to close sink", zap.String("sink", b.cfg.Name), zap.Error(err))
+			}
+			return
+		case <-ticker.C:
+			b.Flush(ctx)
+		}
+	}
+}
+
+// Flush writes the buffered events. Events that still fail after the retries go to the
+// dead letters.
+func (b *Batcher) Flush(ctx context.Context) {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	b.mu.Lock()
+	batch := b.buffer
+	b.buffer = nil
+	end := b.received
+	b.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+	defer b.flushedUpTo(end)
+
+	for _, item := range b.write(ctx, batch) {
+		e := batch[item.Index]
+		fields := []zap.Field{
+			zap.String("sink", b.cfg.Name),
+			zap.String("table", e.Source.Schema+"."+e.Source.Table),
+			zap.String("op", string(e.Op)),
+			zap.Error(item.Err),
+		}
+		if b.cfg.DeadLetters == nil {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to write change event to sink", fields...)
+			continue
+		}
+		letter := cdc.DeadLetter{Handler: b.cfg.Name, Event: e, Error: item.Err.Error(), Attempts: b.cfg.Retries + 1}
+		if err := b.cfg.DeadLetters.Put(ctx, letter); err != nil {
+			logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to dead-letter change event of sink", append(fields, zap.NamedError("dead_letter_error", err))...)
+		}
+	}
+}
+
+// flushedUpTo records that the first end events were flushed and calls the acks due.
+func (b *Batcher) flushedUpTo(end uint64) {
+	b.mu.Lock()
+	b.flushed = end
+	var due []batchAck
+	for len(b.acks) > 0 && b.acks[0].after <= end {
+		due = append(due, b.acks[0])
+		b.acks = b.acks[1:]
+	}
+	b.mu.Unlock()
+	for _, a := range due {
+		a.ack()
+	}
+}
+
+// write writes batch with retries and returns the events that failed, indexed into batch.
+// Only the retryable events of a partial failure are written again.
+func (b *Batcher) write(ctx context.Context, batch []cdc.Event) []ItemError {
+	pending := make([]ItemError, len(batch))
+	for i := range pending {
+		pending[i].Index = i
+	}
+	var failed []ItemError
+	delay := b.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		events := make([]cdc.Event, len(pending))
+		for i, item := range pending {
+			events[i] = batch[item.Index]
+		}
+		err := b.sink.Write(ctx, events)
+		if err == nil {
+			return failed
+		}
+
+		var retry []ItemError
+		var partial *PartialError
+		var permanent *PermanentError
+		switch {
+		case errors.As(err, &partial):
+			for _, item := range partial.Items {
+				item.Index = pending[item.Index].Index
+				if item.Retryable {
+					retry = append(retry, item)
+					continue
+				}
+				failed = append(failed, item)
+			}
+		case errors.As(err, &permanent):
+			for _, item := range pending {
+				failed = append(failed, ItemError{Index: item.Index, Err: err})
+			}
+		default:
+			for _, item := range pending {
+				retry = append(retry, ItemError{Index: item.Index, Err: err, Retryable: true})
+			}
+		}
+		if len(retry) == 0 {
+			return failed
+		}
+		if attempt >= b.cfg.Retries || ctx.Err() != nil {
+			return append(failed, retry...)
+		}
+		pending = retry
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, b.cfg.MaxBackoff)
+	}
+}
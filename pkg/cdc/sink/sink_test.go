@@ -0,0 +1,191 @@
+package sink
+
+import (
+	"context"
+	"debez/pkg/cdc"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSink returns the queued results of Write in order and records the batches.
+type fakeSink struct {
+	mu      sync.Mutex
+	results []error
+	batches [][]cdc.Event
+}
+
+func (s *fakeSink) Write(_ context.Context, events []cdc.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, slices.Clone(events))
+	if len(s.results) == 0 {
+		return nil
+	}
+	err := s.results[0]
+	s.results = s.results[1:]
+	return err
+}
+
+func (s *fakeSink) Close() error {
+	return nil
+}
+
+type memoryDeadLetters struct {
+	mu      sync.Mutex
+	letters []cdc.DeadLetter
+}
+
+func (m *memoryDeadLetters) Put(_ context.Context, letter cdc.DeadLetter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.letters = append(m.letters, letter)
+	return nil
+}
+
+func testEvent(id int) cdc.Event {
+	row := json.RawMessage(`{"id":` + strconv.Itoa(id) + `}`)
+	return cdc.Event{
+		Op:     cdc.OpCreate,
+		After:  &row,
+		Source: cdc.Source{Schema: "public", Table: "users", Fields: map[string]json.RawMessage{"lsn": json.RawMessage(strconv.Itoa(100 + id))}},
+	}
+}
+
+func eventIDs(events []cdc.Event) []string {
+	ids := make([]string, 0, len(events))
+	for _, e := range events {
+		ids = append(ids, string(*e.After))
+	}
+	return ids
+}
+
+func testConfig(deadLetters cdc.DeadLetterStore) Config {
+	return Config{
+		Name:          "test-sink",
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+		Retries:       3,
+		Backoff:       time.Millisecond,
+		MaxBackoff:    time.Millisecond,
+		DeadLetters:   deadLetters,
+	}
+}
+
+func TestBatcherRetriesOnlyRetryableItems(t *testing.T) {
+	sink := &fakeSink{results: []error{
+		&PartialError{Items: []ItemError{
+			{Index: 1, Err: errors.New("busy"), Retryable: true},
+			{Index: 2, Err: errors.New("mapping"), Retryable: false},
+		}},
+		nil,
+	}}
+	deadLetters := &memoryDeadLetters{}
+	b := NewBatcher(sink, testConfig(deadLetters))
+	ctx := context.Background()
+	for id := 0; id < 3; id++ {
+		if err := b.Handle(ctx, testEvent(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Flush(ctx)
+
+	if len(sink.batches) != 2 {
+		t.Fatalf("writes = %d, want 2", len(sink.batches))
+	}
+	if got := eventIDs(sink.batches[1]); !slices.Equal(got, []string{`{"id":1}`}) {
+		t.Fatalf("retried %v, want only the retryable event", got)
+	}
+	if len(deadLetters.letters) != 1 || string(*deadLetters.letters[0].Event.After) != `{"id":2}` {
+		t.Fatalf("dead letters = %v, want the permanently failed event", deadLetters.letters)
+	}
+	if deadLetters.letters[0].Handler != "test-sink" {
+		t.Fatalf("dead letter handler = %q", deadLetters.letters[0].Handler)
+	}
+}
+
+func TestBatcherMapsRetriedItemIndexesToTheBatch(t *testing.T) {
+	sink := &fakeSink{results: []error{
+		&PartialError{Items: []ItemError{
+			{Index: 0, Err: errors.New("busy"), Retryable: true},
+			{Index: 2, Err: errors.New("busy"), Retryable: true},
+		}},
+		// Indexes of the retry point into the retried events: 1 is event 2 of the batch.
+		&PartialError{Items: []ItemError{{Index: 1, Err: errors.New("mapping")}}},
+	}}
+	deadLetters := &memoryDeadLetters{}
+	b := NewBatcher(sink, testConfig(deadLetters))
+	ctx := context.Background()
+	for id := 0; id < 3; id++ {
+		_ = b.Handle(ctx, testEvent(id))
+	}
+	b.Flush(ctx)
+
+	if got := eventIDs(sink.batches[1]); !slices.Equal(got, []string{`{"id":0}`, `{"id":2}`}) {
+		t.Fatalf("retried %v", got)
+	}
+	if len(deadLetters.letters) != 1 || string(*deadLetters.letters[0].Event.After) != `{"id":2}` {
+		t.Fatalf("dead letters = %v, want event 2", deadLetters.letters)
+	}
+}
+
+func TestBatcherGivesUpAfterRetries(t *testing.T) {
+	failure := errors.New("unavailable")
+	sink := &fakeSink{results: []error{failure, failure, failure, failure, failure}}
+	deadLetters := &memoryDeadLetters{}
+	b := NewBatcher(sink, testConfig(deadLetters))
+	ctx := context.Background()
+	_ = b.Handle(ctx, testEvent(0))
+	b.Flush(ctx)
+
+	if len(sink.batches) != 4 {
+		t.Fatalf("writes = %d, want 1 + 3 retries", len(sink.batches))
+	}
+	if len(deadLetters.letters) != 1 || deadLetters.letters[0].Attempts != 4 {
+		t.Fatalf("dead letters = %+v", deadLetters.letters)
+	}
+}
+
+func TestBatcherPermanentErrorIsNotRetried(t *testing.T) {
+	sink := &fakeSink{results: []error{Permanent(errors.New("bad request"))}}
+	deadLetters := &memoryDeadLetters{}
+	b := NewBatcher(sink, testConfig(deadLetters))
+	ctx := context.Background()
+	_ = b.Handle(ctx, testEvent(0))
+	_ = b.Handle(ctx, testEvent(1))
+	b.Flush(ctx)
+
+	if len(sink.batches) != 1 {
+		t.Fatalf("writes = %d, want 1", len(sink.batches))
+	}
+	if len(deadLetters.letters) != 2 {
+		t.Fatalf("dead letters = %d, want 2", len(deadLetters.letters))
+	}
+}
+
+func TestBatcherAcksAfterFlush(t *testing.T) {
+	b := NewBatcher(&fakeSink{}, testConfig(nil))
+	ctx := context.Background()
+
+	acked := 0
+	b.AckAfter(func() { acked++ })
+	if acked != 1 {
+		t.Fatal("ack without buffered events was not called at once")
+	}
+
+	_ = b.Handle(ctx, testEvent(0))
+	b.AckAfter(func() { acked++ })
+	_ = b.Handle(ctx, testEvent(1))
+	b.AckAfter(func() { acked++ })
+	if acked != 1 {
+		t.Fatalf("acked %d times before the flush", acked-1)
+	}
+	b.Flush(ctx)
+	if acked != 3 {
+		t.Fatalf("acks after flush = %d, want 2", acked-1)
+	}
+}
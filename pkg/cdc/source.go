@@ -0,0 +1,192 @@
+package cdc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Source is the source block of an event. Attributes shared by all connectors are fields,
+// connector specific ones are kept in Fields and read through typed accessors.
+type Source struct {
+	Version   string   `json:"version"`
+	Connector string   `json:"connector"`
+	Name      string   `json:"name"`
+	TsMs      int64    `json:"ts_ms"`
+	Snapshot  Snapshot `json:"snapshot"`
+	DB        string   `json:"db"`
+	Schema    string   `json:"schema,omitempty"`
+	Table     string   `json:"table,omitempty"`
+
+	Fields map[string]json.RawMessage `json:"-"`
+}
+
+var sourceKeys = map[string]struct{}{
+	"version": {}, "connector": {}, "name": {}, "ts_ms": {}, "snapshot": {},
+	"db": {}, "schema": {}, "table": {},
+}
+
+func (s *Source) UnmarshalJSON(data []byte) error {
+	type plain Source
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for k := range sourceKeys {
+		delete(fields, k)
+	}
+	s.Fields = fields
+	return nil
+}
+
+func (s Source) MarshalJSON() ([]byte, error) {
+	type plain Source
+	data, err := json.Marshal(plain(s))
+	if err != nil || len(s.Fields) == 0 {
+		return data, err
+	}
+	fields := make(map[string]json.RawMessage, len(s.Fields)+len(sourceKeys))
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range s.Fields {
+		if _, ok := sourceKeys[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Set stores a connector specific attribute.
+func (s *Source) Set(key string, value any) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if s.Fields == nil {
+		s.Fields = make(map[string]json.RawMessage)
+	}
+	s.Fields[key] = raw
+}
+
+func (s Source) int64Field(key string) (int64, bool) {
+	raw, ok := s.Fields[key]
+	if !ok {
+		return 0, false
+	}
+	var v *int64
+	if err := json.Unmarshal(raw, &v); err != nil || v == nil {
+		return 0, false
+	}
+	return *v, true
+}
+
+// LSN is the Postgres log sequence number of the change.
+func (s Source) LSN() (LSN, bool) {
+	v, ok := s.int64Field("lsn")
+	return LSN(v), ok
+}
+
+// TxID is the Postgres transaction id of the change.
+func (s Source) TxID() (int64, bool) {
+	return s.int64Field("txId")
+}
+
+func (s Source) Xmin() (int64, bool) {
+	return s.int64Field("xmin")
+}
+
+// Sequence returns the Postgres [lastCommitLsn, lsn] pair.
+func (s Source) Sequence() (lastCommit LSN, lsn LSN, ok bool) {
+	raw, found := s.Fields["sequence"]
+	if !found {
+		return 0, 0, false
+	}
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err != nil {
+		return 0, 0, false
+	}
+	var pair []*string
+	if err := json.Unmarshal([]byte(encoded), &pair); err != nil || len(pair) != 2 {
+		return 0, 0, false
+	}
+	if pair[0] != nil {
+		v, err := strconv.ParseUint(*pair[0], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		lastCommit = LSN(v)
+	}
+	if pair[1] != nil {
+		v, err := strconv.ParseUint(*pair[1], 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		lsn = LSN(v)
+	}
+	return lastCommit, lsn, true
+}
+
+// Snapshot is source.snapshot: "true", "false", "first", "last", "incremental" and so on.
+// Older connectors send a boolean.
+type Snapshot string
+
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*s = Snapshot(strconv.FormatBool(b))
+		return nil
+	}
+	var str *string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if str == nil {
+		*s = ""
+		return nil
+	}
+	*s = Snapshot(*str)
+	return nil
+}
+
+// Active reports whether the event was produced by a snapshot.
+func (s Snapshot) Active() bool {
+	return s != "" && s != "false"
+}
+
+// Last reports whether the event is the last one of the snapshot.
+func (s Snapshot) Last() bool {
+	return s == "last"
+}
+
+// LSN is a Postgres log sequence number.
+type LSN uint64
+
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
+
+// ParseLSN parses both the "16/B374D848" and the decimal form.
+func ParseLSN(s string) (LSN, error) {
+	hi, lo, found := strings.Cut(s, "/")
+	if !found {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cdc.ParseLSN %q: %w", s, err)
+		}
+		return LSN(v), nil
+	}
+	h, err := strconv.ParseUint(hi, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("cdc.ParseLSN %q: %w", s, err)
+	}
+	l, err := strconv.ParseUint(lo, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("cdc.ParseLSN %q: %w", s, err)
+	}
+	return LSN(h<<32 | l), nil
+}
@@ -0,0 +1,111 @@
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type TxStatus string
+
+const (
+	TxBegin TxStatus = "BEGIN"
+	TxEnd   TxStatus = "END"
+)
+
+// TransactionEvent is a transaction metadata event, sent by Debezium to the
+// <topic.prefix>.transaction topic with provide.transaction.metadata=true.
+// EventCount and DataCollections are only set on END.
+type TransactionEvent struct {
+	Status          TxStatus         `json:"status"`
+	ID              string           `json:"id"`
+	EventCount      int64            `json:"event_count"`
+	DataCollections []DataCollection `json:"data_collections,omitempty"`
+	TsMs            int64            `json:"ts_ms"`
+}
+
+// DataCollection is the number of events of one table in a transaction.
+type DataCollection struct {
+	DataCollection string `json:"data_collection"`
+	EventCount     int64  `json:"event_count"`
+}
+
+// DecodeTransaction parses a transaction metadata event with or without the schema/payload
+// wrapper. ok is false when data is not one, e.g. a change event.
+func DecodeTransaction(data []byte) (TransactionEvent, bool, error) {
+	var tx TransactionEvent
+	var w wrapper
+	if err := json.Unmarshal(data, &w); err != nil {
+		return tx, false, fmt.Errorf("cdc.DecodeTransaction: %w", err)
+	}
+	payload := data
+	if w.Payload != nil {
+		payload = w.Payload
+	}
+	if bytes.Equal(bytes.TrimSpace(payload), []byte("null")) {
+		return tx, false, nil
+	}
+	if err := json.Unmarshal(payload, &tx); err != nil {
+		return tx, false, fmt.Errorf("cdc.DecodeTransaction payload: %w", err)
+	}
+	if (tx.Status != TxBegin && tx.Status != TxEnd) || tx.ID == "" {
+		return TransactionEvent{}, false, nil
+	}
+	return tx, true, nil
+}
+
+// TransactionHandler consumes transaction metadata events. Sources pass BEGIN and END
+// to handlers that implement it, before the first and after the last event of a transaction.
+type TransactionHandler interface {
+	HandleTransaction(ctx context.Context, tx TransactionEvent) error
+}
+
+// Tx is a complete transaction, its events in commit order.
+type Tx struct {
+	ID     string  `json:"id"`
+	Events []Event `json:"events"`
+	// Partial is set when the transaction was larger than the dispatcher buffers and is
+	// delivered in several parts. Each part is still in order.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// TxHandler consumes whole transactions, see Dispatcher.RegisterTx.
+type TxHandler interface {
+	HandleTx(ctx context.Context, tx Tx) error
+}
+
+type TxHandlerFunc func(ctx context.Context, tx Tx) error
+
+func (f TxHandlerFunc) HandleTx(ctx context.Context, tx Tx) error {
+	return f(ctx, tx)
+}
+
+type txKey struct{}
+
+// WithTx stores the transaction a transaction handler is invoked with in ctx.
+func WithTx(ctx context.Context, tx Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction of a transaction handler invocation. Middlewares
+// see its last event as the event, this tells them it stands for the whole transaction.
+func TxFromContext(ctx context.Context) (Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(Tx)
+	return tx, ok
+}
+
+// pendingTx is a transaction the dispatcher is buffering.
+type pendingTx struct {
+	started time.Time
+	events  []Event
+	// expected is the event count of END, 0 until it arrived.
+	expected int64
+	// delivered counts events already handed out in parts of an oversized transaction.
+	delivered int64
+}
+
+func (p *pendingTx) complete() bool {
+	return p.expected > 0 && p.delivered+int64(len(p.events)) >= p.expected
+}
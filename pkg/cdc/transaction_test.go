@@ -0,0 +1,228 @@
+package cdc
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// txRecorder records the transactions a transaction handler receives.
+type txRecorder struct {
+	mu  sync.Mutex
+	txs []Tx
+}
+
+func (r *txRecorder) HandleTx(_ context.Context, tx Tx) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.txs = append(r.txs, tx)
+	return nil
+}
+
+func (r *txRecorder) get() []Tx {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.txs)
+}
+
+func txEvent(id string, lsn int) Event {
+	e := lsnEvent(lsn)
+	e.Op = OpCreate
+	e.Source.Schema, e.Source.Table = "public", "users"
+	e.Transaction = &Transaction{ID: id}
+	return e
+}
+
+func TestRunTxTimeoutsFlushesWithoutFurtherEvents(t *testing.T) {
+	d := NewDispatcher()
+	var r txRecorder
+	d.RegisterTx("tx", Route{}, &r)
+	d.GroupTransactions(0, 100*time.Millisecond)
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.RunTxTimeouts(runCtx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	ctx := context.Background()
+	if err := d.HandleTransaction(ctx, TransactionEvent{Status: TxBegin, ID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, lsn := range []int{1, 2} {
+		if err := d.Handle(ctx, txEvent("1", lsn)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := r.get(); len(got) != 0 {
+		t.Fatalf("dispatched %d transactions before END or the timeout", len(got))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(r.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	got := r.get()
+	if len(got) != 1 || !got[0].Partial || !slices.Equal(txLSNs(got[0]), []uint64{1, 2}) {
+		t.Fatalf("dispatched %+v, want one partial transaction of [1 2]", got)
+	}
+}
+
+func TestRunTxTimeoutsReturnsWithoutGrouping(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewDispatcher().RunTxTimeouts(context.Background())
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunTxTimeouts did not return without GroupTransactions")
+	}
+}
+
+// txStep is an event or a transaction marker passed to the dispatcher.
+type txStep struct {
+	event  *Event
+	marker *TransactionEvent
+}
+
+func eventStep(id string, lsn int) txStep {
+	e := txEvent(id, lsn)
+	return txStep{event: &e}
+}
+
+func beginStep(id string) txStep {
+	return txStep{marker: &TransactionEvent{Status: TxBegin, ID: id}}
+}
+
+func endStep(id string, collections ...DataCollection) txStep {
+	m := TransactionEvent{Status: TxEnd, ID: id, DataCollections: collections}
+	for _, c := range collections {
+		m.EventCount += c.EventCount
+	}
+	return txStep{marker: &m}
+}
+
+type dispatchedTx struct {
+	lsns    []uint64
+	partial bool
+}
+
+func TestDispatcherGroupsTransactions(t *testing.T) {
+	users := func(n int64) DataCollection { return DataCollection{DataCollection: "public.users", EventCount: n} }
+	plain := txEvent("", 9)
+	plain.Transaction = nil
+	tests := []struct {
+		name      string
+		maxEvents int
+		steps     []txStep
+		want      []dispatchedTx
+	}{
+		{
+			name:  "complete at END",
+			steps: []txStep{beginStep("1"), eventStep("1", 1), eventStep("1", 2), endStep("1", users(2))},
+			want:  []dispatchedTx{{lsns: []uint64{1, 2}}},
+		},
+		{
+			name:  "END before the events",
+			steps: []txStep{endStep("1", users(2)), eventStep("1", 1), eventStep("1", 2)},
+			want:  []dispatchedTx{{lsns: []uint64{1, 2}}},
+		},
+		{
+			name: "interleaved transactions",
+			steps: []txStep{
+				beginStep("1"), beginStep("2"), eventStep("1", 1), eventStep("2", 2), eventStep("1", 3),
+				endStep("2", users(1)), endStep("1", users(2)),
+			},
+			want: []dispatchedTx{{lsns: []uint64{2}}, {lsns: []uint64{1, 3}}},
+		},
+		{
+			name: "unrouted tables are not waited for",
+			steps: []txStep{
+				beginStep("1"), eventStep("1", 1),
+				endStep("1", users(1), DataCollection{DataCollection: "public.orders", EventCount: 5}),
+			},
+			want: []dispatchedTx{{lsns: []uint64{1}}},
+		},
+		{
+			name:      "oversized transaction in parts",
+			maxEvents: 2,
+			steps:     []txStep{beginStep("1"), eventStep("1", 1), eventStep("1", 2), eventStep("1", 3), endStep("1", users(3))},
+			want:      []dispatchedTx{{lsns: []uint64{1, 2}, partial: true}, {lsns: []uint64{3}, partial: true}},
+		},
+		{
+			name:  "END without routed events flushes the buffer",
+			steps: []txStep{beginStep("1"), eventStep("1", 1), endStep("1", DataCollection{DataCollection: "public.orders", EventCount: 1})},
+			want:  []dispatchedTx{{lsns: []uint64{1}}},
+		},
+		{
+			name:  "event without transaction",
+			steps: []txStep{{event: &plain}},
+			want:  []dispatchedTx{{lsns: []uint64{9}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDispatcher()
+			var r txRecorder
+			d.RegisterTx("tx", Route{Schema: "public", Table: "users"}, &r)
+			d.GroupTransactions(tt.maxEvents, time.Hour)
+			ctx := context.Background()
+			for _, step := range tt.steps {
+				var err error
+				if step.event != nil {
+					err = d.Handle(ctx, *step.event)
+				} else {
+					err = d.HandleTransaction(ctx, *step.marker)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got []dispatchedTx
+			for _, tx := range r.get() {
+				got = append(got, dispatchedTx{lsns: txLSNs(tx), partial: tx.Partial})
+			}
+			if !slices.EqualFunc(got, tt.want, func(a, b dispatchedTx) bool {
+				return a.partial == b.partial && slices.Equal(a.lsns, b.lsns)
+			}) {
+				t.Fatalf("dispatched %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatcherFlushesStaleTransactions(t *testing.T) {
+	d := NewDispatcher()
+	var r txRecorder
+	d.RegisterTx("tx", Route{}, &r)
+	// Every buffered transaction is stale by the time the next event arrives.
+	d.GroupTransactions(0, time.Nanosecond)
+	ctx := context.Background()
+
+	for _, e := range []Event{txEvent("1", 1), txEvent("2", 2)} {
+		if err := d.Handle(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := r.get()
+	if len(got) != 1 || !got[0].Partial || !slices.Equal(txLSNs(got[0]), []uint64{1}) {
+		t.Fatalf("dispatched %+v, want transaction 1 as a part", got)
+	}
+
+	if err := d.FlushTransactions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got = r.get()
+	if len(got) != 2 || got[1].ID != "2" || !slices.Equal(txLSNs(got[1]), []uint64{2}) {
+		t.Fatalf("dispatched %+v, want transaction 2 flushed", got)
+	}
+}
@@ -0,0 +1,126 @@
+package debeziumclient
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const postgresConnectorClass = "io.debezium.connector.postgresql.PostgresConnector"
+
+// ConnectorBuilder assembles a CreateConnectorRequest for the Debezium Postgres connector.
+type ConnectorBuilder struct {
+	request CreateConnectorRequest
+}
+
+func NewPostgresConnector(name string) *ConnectorBuilder {
+	return &ConnectorBuilder{
+		request: CreateConnectorRequest{
+			Name: name,
+			Config: CreateConnectorConfig{
+				ConnectorClass: postgresConnectorClass,
+				TasksMax:       "1",
+				AdditionalParameters: map[string]string{
+					"plugin.name": "pgoutput",
+				},
+			},
+		},
+	}
+}
+
+func (b *ConnectorBuilder) Database(host string, port uint16, user, password, dbname string) *ConnectorBuilder {
+	b.request.Config.DatabaseHostname = host
+	b.request.Config.DatabasePort = strconv.Itoa(int(port))
+	b.request.Config.DatabaseUser = user
+	b.request.Config.DatabasePassword = password
+	b.request.Config.DatabaseDbname = dbname
+	return b
+}
+
+// TopicPrefix sets both topic.prefix (Debezium 2.x) and database.server.name (1.x).
+func (b *ConnectorBuilder) TopicPrefix(prefix string) *ConnectorBuilder {
+	b.request.Config.DatabaseServerName = prefix
+	return b.Set("topic.prefix", prefix)
+}
+
+func (b *ConnectorBuilder) Tables(tables ...string) *ConnectorBuilder {
+	return b.Set("table.include.list", strings.Join(tables, ","))
+}
+
+func (b *ConnectorBuilder) Slot(name string) *ConnectorBuilder {
+	return b.Set("slot.name", name)
+}
+
+func (b *ConnectorBuilder) Publication(name string) *ConnectorBuilder {
+	return b.Set("publication.name", name)
+}
+
+// Heartbeat makes the connector emit heartbeats and run actionQuery on every one of them.
+// Writing to a table of the captured database keeps the slot's confirmed LSN moving
+// when the captured tables are quiet. An empty actionQuery only enables heartbeat messages.
+func (b *ConnectorBuilder) Heartbeat(interval time.Duration, actionQuery string) *ConnectorBuilder {
+	b.Set("heartbeat.interval.ms", strconv.FormatInt(interval.Milliseconds(), 10))
+	if actionQuery != "" {
+		b.Set("heartbeat.action.query", actionQuery)
+	}
+	return b
+}
+
+// OutboxEventRouter captures the outbox table and turns its inserts into domain events with
+// Debezium's EventRouter SMT: one topic per aggregate type (outbox.event.<aggregatetype>),
+// keyed by aggregateid, the payload column as value and the event type in the eventType header.
+// A TopicNameMatches predicate restricts the SMT to the outbox topic, so the other captured
+// tables keep their change events. Transforms and predicates already set are kept.
+// Outbox rows may be deleted right after their insert, the SMT drops delete events.
+func (b *ConnectorBuilder) OutboxEventRouter(table string) *ConnectorBuilder {
+	tables := b.request.Config.AdditionalParameters["table.include.list"]
+	if tables == "" {
+		b.Tables(table)
+	} else if !slices.Contains(strings.Split(tables, ","), table) {
+		b.Set("table.include.list", tables+","+table)
+	}
+	return b.
+		appendList("transforms", "outbox").
+		Set("transforms.outbox.type", "io.debezium.transforms.outbox.EventRouter").
+		Set("transforms.outbox.predicate", "outboxTable").
+		Set("transforms.outbox.table.fields.additional.placement", "type:header:eventType").
+		Set("transforms.outbox.table.expand.json.payload", "true").
+		Set("transforms.outbox.route.by.field", "aggregatetype").
+		Set("transforms.outbox.route.topic.replacement", "outbox.event.${routedByValue}").
+		appendList("predicates", "outboxTable").
+		Set("predicates.outboxTable.type", "org.apache.kafka.connect.transforms.predicates.TopicNameMatches").
+		// Change event topics are <topic.prefix>.<schema>.<table>.
+		Set("predicates.outboxTable.pattern", ".*\\."+regexp.QuoteMeta(table))
+}
+
+// appendList adds value to a comma-separated config list unless it is already in it.
+func (b *ConnectorBuilder) appendList(key, value string) *ConnectorBuilder {
+	list := b.request.Config.AdditionalParameters[key]
+	if list == "" {
+		return b.Set(key, value)
+	}
+	if slices.Contains(strings.Split(list, ","), value) {
+		return b
+	}
+	return b.Set(key, list+","+value)
+}
+
+// Set sets an arbitrary connector config key.
+func (b *ConnectorBuilder) Set(key, value string) *ConnectorBuilder {
+	if b.request.Config.AdditionalParameters == nil {
+		b.request.Config.AdditionalParameters = make(map[string]string)
+	}
+	b.request.Config.AdditionalParameters[key] = value
+	return b
+}
+
+func (b *ConnectorBuilder) Build() CreateConnectorRequest {
+	request := b.request
+	request.Config.AdditionalParameters = make(map[string]string, len(b.request.Config.AdditionalParameters))
+	for k, v := range b.request.Config.AdditionalParameters {
+		request.Config.AdditionalParameters[k] = v
+	}
+	return request
+}
@@ -11,7 +11,7 @@ import (
 const (
 	getConnector          = "/connectors/%s"
 	getConnectorStatus    = "/connectors/%s/status"
-	getConnectorsStatuses = "/connectors?expand=status"
+	getConnectorsStatuses = "/connectors?expand=status&expand=info"
 	postCreateConnectors  = "/connectors"
 	deleteConnector       = "/connectors/%s"
 )
@@ -55,17 +55,20 @@ func (c *Client) GetConnectorsStatuses(ctx context.Context) (GetConnectorsStatus
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+getConnectorsStatuses, nil)
 	if err != nil {
-		return GetConnectorsStatusResponse{}, fmt.Errorf("GetConnectorsStatuses.NewRequestWithContext: %w", err)
+		return nil, fmt.Errorf("GetConnectorsStatuses.NewRequestWithContext: %w", err)
 	}
 
 	resp, err := c.cc.Do(req)
 	if err != nil {
-		return GetConnectorsStatusResponse{}, fmt.Errorf("GetConnectorsStatuses.Client.Do: %w", err)
+		return nil, fmt.Errorf("GetConnectorsStatuses.Client.Do: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GetConnectorsStatuses: unexpected status %d", resp.StatusCode)
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&connectorResponse); err != nil {
-		return GetConnectorsStatusResponse{}, fmt.Errorf("GetConnectorsStatuses.DecodeJSON: %w", err)
+		return nil, fmt.Errorf("GetConnectorsStatuses.DecodeJSON: %w", err)
 	}
 	return connectorResponse, nil
 }
@@ -1,5 +1,7 @@
 package debeziumclient
 
+import "encoding/json"
+
 type GetConnectorResponse struct {
 	Name   string                 `json:"name"`
 	Config map[string]interface{} `json:"config"`
@@ -7,10 +9,12 @@ type GetConnectorResponse struct {
 	Type   string                 `json:"type"`
 }
 
-type GetConnectorsStatusResponse struct {
-	Connectors []struct {
-		Status GetConnectorStatusResponse
-	} `json:"connector"`
+// GetConnectorsStatusResponse is keyed by connector name.
+type GetConnectorsStatusResponse map[string]ConnectorExpanded
+
+type ConnectorExpanded struct {
+	Status GetConnectorStatusResponse `json:"status"`
+	Info   GetConnectorResponse       `json:"info"`
 }
 
 type CreateConnectorConfig struct {
@@ -22,19 +26,78 @@ type CreateConnectorConfig struct {
 	DatabasePassword     string            `json:"database.password"`
 	DatabaseDbname       string            `json:"database.dbname"`
 	DatabaseServerName   string            `json:"database.server.name"`
-	AdditionalParameters map[string]string `json:"-,omitempty"`
+	AdditionalParameters map[string]string `json:"-"`
+}
+
+// MarshalJSON flattens AdditionalParameters into the config object, the way Kafka Connect expects them.
+func (c CreateConnectorConfig) MarshalJSON() ([]byte, error) {
+	type plain CreateConnectorConfig
+	data, err := json.Marshal(plain(c))
+	if err != nil {
+		return nil, err
+	}
+	if len(c.AdditionalParameters) == 0 {
+		return data, nil
+	}
+	fields := make(map[string]string, len(c.AdditionalParameters)+8)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range c.AdditionalParameters {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON collects every config key without a dedicated field into AdditionalParameters.
+func (c *CreateConnectorConfig) UnmarshalJSON(data []byte) error {
+	type plain CreateConnectorConfig
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	known, err := json.Marshal(plain(*c))
+	if err != nil {
+		return err
+	}
+	var knownFields map[string]any
+	if err := json.Unmarshal(known, &knownFields); err != nil {
+		return err
+	}
+	for k, v := range fields {
+		if _, ok := knownFields[k]; ok {
+			continue
+		}
+		if c.AdditionalParameters == nil {
+			c.AdditionalParameters = make(map[string]string)
+		}
+		if s, ok := v.(string); ok {
+			c.AdditionalParameters[k] = s
+		} else {
+			raw, _ := json.Marshal(v)
+			c.AdditionalParameters[k] = string(raw)
+		}
+	}
+	return nil
 }
 
 type TaskInfo struct {
 	ID       int    `json:"id"`
 	State    string `json:"state"`
 	WorkerID string `json:"worker_id"`
+	Trace    string `json:"trace,omitempty"`
 }
 type GetConnectorStatusResponse struct {
 	Name      string `json:"name"`
 	Connector struct {
 		State    string `json:"state"`
 		WorkerID string `json:"worker_id"`
+		Trace    string `json:"trace,omitempty"`
 	} `json:"connector"`
 	Tasks []TaskInfo `json:"tasks"`
 	Type  string     `json:"type"`
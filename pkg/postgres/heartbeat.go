@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"debez/pkg/logger"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// HeartbeatQuery bumps the single row of debezium_heartbeat. The same statement is
+// used as the connector's heartbeat.action.query.
+const HeartbeatQuery = "INSERT INTO debezium_heartbeat (id, ts) VALUES (1, NOW()) ON CONFLICT (id) DO UPDATE SET ts = EXCLUDED.ts"
+
+const defaultHeartbeatInterval = 30 * time.Second
+
+type Heartbeat struct {
+	db       *DataBase
+	interval time.Duration
+}
+
+func NewHeartbeat(db *DataBase, interval time.Duration) *Heartbeat {
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	return &Heartbeat{
+		db:       db,
+		interval: interval,
+	}
+}
+
+// Run writes a heartbeat every interval until ctx is done.
+func (h *Heartbeat) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := h.db.Pool.Exec(ctx, HeartbeatQuery); err != nil && ctx.Err() == nil {
+				logger.GetLoggerFromCtx(ctx).Info(ctx, "failed to write heartbeat", zap.Error(err))
+			}
+		}
+	}
+}
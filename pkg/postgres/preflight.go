@@ -0,0 +1,269 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+)
+
+type CheckResult struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
+}
+
+type PreflightReport struct {
+	Status CheckStatus   `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// PreflightOptions describes the connector that is about to be created.
+// Tables are "schema.table" names from table.include.list.
+type PreflightOptions struct {
+	User        string
+	Tables      []string
+	Publication string
+	Slot        string
+}
+
+func (r PreflightReport) Failed() bool {
+	return r.Status == CheckFail
+}
+
+func (r *PreflightReport) add(name string, status CheckStatus, format string, args ...any) {
+	r.Checks = append(r.Checks, CheckResult{Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+	switch {
+	case status == CheckFail:
+		r.Status = CheckFail
+	case status == CheckWarn && r.Status == CheckPass:
+		r.Status = CheckWarn
+	}
+}
+
+// Preflight checks that the source database is ready for a Debezium Postgres connector.
+// Problems are reported as failed checks, the error is returned only when the context is done.
+func (db *DataBase) Preflight(ctx context.Context, opts PreflightOptions) (PreflightReport, error) {
+	report := PreflightReport{Status: CheckPass}
+
+	db.checkWalLevel(ctx, &report)
+	db.checkReplicationSlots(ctx, &report, opts.Slot)
+	db.checkWalSenders(ctx, &report)
+	db.checkReplicationRole(ctx, &report, opts.User)
+	for _, table := range opts.Tables {
+		db.checkTable(ctx, &report, opts.User, table)
+	}
+	db.checkPublication(ctx, &report, opts.Publication, opts.Tables)
+
+	if err := ctx.Err(); err != nil {
+		return report, fmt.Errorf("Preflight: %w", err)
+	}
+	return report, nil
+}
+
+func (db *DataBase) setting(ctx context.Context, name string) (string, error) {
+	var value string
+	if err := db.Pool.QueryRow(ctx, "SELECT current_setting($1)", name).Scan(&value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+func (db *DataBase) intSetting(ctx context.Context, name string) (int, error) {
+	value, err := db.setting(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+func (db *DataBase) checkWalLevel(ctx context.Context, report *PreflightReport) {
+	const name = "wal_level"
+	level, err := db.setting(ctx, name)
+	if err != nil {
+		report.add(name, CheckFail, "failed to read wal_level: %v", err)
+		return
+	}
+	if level != "logical" {
+		report.add(name, CheckFail, "wal_level is %q, logical is required", level)
+		return
+	}
+	report.add(name, CheckPass, "wal_level is logical")
+}
+
+func (db *DataBase) checkReplicationSlots(ctx context.Context, report *PreflightReport, slot string) {
+	const name = "max_replication_slots"
+	maxSlots, err := db.intSetting(ctx, name)
+	if err != nil {
+		report.add(name, CheckFail, "failed to read max_replication_slots: %v", err)
+		return
+	}
+	var used int
+	var exists bool
+	err = db.Pool.QueryRow(ctx,
+		"SELECT count(*), COALESCE(bool_or(slot_name = $1), false) FROM pg_replication_slots", slot,
+	).Scan(&used, &exists)
+	if err != nil {
+		report.add(name, CheckFail, "failed to count replication slots: %v", err)
+		return
+	}
+	switch {
+	case exists:
+		report.add(name, CheckPass, "slot %q already exists (%d of %d slots used)", slot, used, maxSlots)
+	case used >= maxSlots:
+		report.add(name, CheckFail, "all %d replication slots are in use", maxSlots)
+	case used+1 == maxSlots:
+		report.add(name, CheckWarn, "the connector will take the last free replication slot (%d of %d used)", used, maxSlots)
+	default:
+		report.add(name, CheckPass, "%d of %d replication slots used", used, maxSlots)
+	}
+}
+
+func (db *DataBase) checkWalSenders(ctx context.Context, report *PreflightReport) {
+	const name = "max_wal_senders"
+	maxSenders, err := db.intSetting(ctx, name)
+	if err != nil {
+		report.add(name, CheckFail, "failed to read max_wal_senders: %v", err)
+		return
+	}
+	var used int
+	if err := db.Pool.QueryRow(ctx, "SELECT count(*) FROM pg_stat_replication").Scan(&used); err != nil {
+		report.add(name, CheckFail, "failed to count wal senders: %v", err)
+		return
+	}
+	switch {
+	case used >= maxSenders:
+		report.add(name, CheckFail, "all %d wal senders are in use", maxSenders)
+	case used+1 == maxSenders:
+		report.add(name, CheckWarn, "the connector will take the last free wal sender (%d of %d used)", used, maxSenders)
+	default:
+		report.add(name, CheckPass, "%d of %d wal senders used", used, maxSenders)
+	}
+}
+
+func (db *DataBase) checkReplicationRole(ctx context.Context, report *PreflightReport, user string) {
+	const name = "replication_privilege"
+	var replication bool
+	err := db.Pool.QueryRow(ctx,
+		"SELECT rolreplication OR rolsuper FROM pg_roles WHERE rolname = $1", user,
+	).Scan(&replication)
+	if errors.Is(err, pgx.ErrNoRows) {
+		report.add(name, CheckFail, "role %q does not exist", user)
+		return
+	}
+	if err != nil {
+		report.add(name, CheckFail, "failed to read role %q: %v", user, err)
+		return
+	}
+	if !replication {
+		report.add(name, CheckFail, "role %q has no REPLICATION privilege", user)
+		return
+	}
+	report.add(name, CheckPass, "role %q can replicate", user)
+}
+
+func (db *DataBase) checkTable(ctx context.Context, report *PreflightReport, user, table string) {
+	identity, err := db.GetReplicaIdentity(ctx, table)
+	if errors.Is(err, ErrTableNotFound) {
+		report.add("table:"+table, CheckFail, "table %s does not exist", table)
+		return
+	}
+	if err != nil {
+		report.add("table:"+table, CheckFail, "failed to inspect table %s: %v", table, err)
+		return
+	}
+
+	var canSelect bool
+	err = db.Pool.QueryRow(ctx,
+		"SELECT has_table_privilege($1::name, to_regclass($2::text), 'SELECT')", user, TableIdentifier(table).Sanitize(),
+	).Scan(&canSelect)
+	switch {
+	case err != nil:
+		report.add("select:"+table, CheckFail, "failed to read privileges of %q on %s: %v", user, table, err)
+	case canSelect:
+		report.add("select:"+table, CheckPass, "role %q can select from %s", user, table)
+	default:
+		report.add("select:"+table, CheckFail, "role %q has no SELECT on %s", user, table)
+	}
+
+	name := "replica_identity:" + table
+	switch identity.Identity {
+	case ReplicaIdentityDefault:
+		if identity.PrimaryKey {
+			report.add(name, CheckPass, "%s has a primary key", table)
+		} else {
+			report.add(name, CheckFail, "%s has no primary key and REPLICA IDENTITY DEFAULT, updates and deletes cannot be captured", table)
+		}
+	case ReplicaIdentityFull:
+		if identity.PrimaryKey {
+			report.add(name, CheckPass, "%s uses REPLICA IDENTITY FULL", table)
+		} else {
+			report.add(name, CheckWarn, "%s uses REPLICA IDENTITY FULL but has no primary key, events will have no message key", table)
+		}
+	case ReplicaIdentityIndex:
+		report.add(name, CheckPass, "%s uses REPLICA IDENTITY USING INDEX %s", table, identity.Index)
+	default:
+		report.add(name, CheckFail, "%s uses REPLICA IDENTITY NOTHING, updates and deletes cannot be captured", table)
+	}
+}
+
+func (db *DataBase) checkPublication(ctx context.Context, report *PreflightReport, publication string, tables []string) {
+	const name = "publication"
+	var allTables bool
+	err := db.Pool.QueryRow(ctx,
+		"SELECT puballtables FROM pg_publication WHERE pubname = $1", publication,
+	).Scan(&allTables)
+	if errors.Is(err, pgx.ErrNoRows) {
+		report.add(name, CheckFail, "publication %q does not exist", publication)
+		return
+	}
+	if err != nil {
+		report.add(name, CheckFail, "failed to read publication %q: %v", publication, err)
+		return
+	}
+	if allTables {
+		report.add(name, CheckPass, "publication %q covers all tables", publication)
+		return
+	}
+
+	var missing []string
+	for _, table := range tables {
+		var published bool
+		err := db.Pool.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM pg_publication_tables
+				WHERE pubname = $1 AND (schemaname || '.' || tablename) = $2
+			)`, publication, qualifiedTable(table),
+		).Scan(&published)
+		if err != nil {
+			report.add(name, CheckFail, "failed to read tables of publication %q: %v", publication, err)
+			return
+		}
+		if !published {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		report.add(name, CheckFail, "publication %q does not include %v", publication, missing)
+		return
+	}
+	report.add(name, CheckPass, "publication %q includes all captured tables", publication)
+}
+
+func qualifiedTable(table string) string {
+	ident := TableIdentifier(table)
+	if len(ident) == 1 {
+		return "public." + ident[0]
+	}
+	return ident[0] + "." + ident[1]
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type ReplicaIdentity string
+
+const (
+	ReplicaIdentityDefault ReplicaIdentity = "DEFAULT"
+	ReplicaIdentityFull    ReplicaIdentity = "FULL"
+	ReplicaIdentityIndex   ReplicaIdentity = "USING INDEX"
+	ReplicaIdentityNothing ReplicaIdentity = "NOTHING"
+)
+
+var ErrTableNotFound = errors.New("table not found")
+
+type TableReplicaIdentity struct {
+	Table      string          `json:"table"`
+	Identity   ReplicaIdentity `json:"identity"`
+	Index      string          `json:"index,omitempty"`
+	PrimaryKey bool            `json:"primary_key"`
+}
+
+// replicaIdentityFromCode maps pg_class.relreplident to ReplicaIdentity.
+func replicaIdentityFromCode(code string) ReplicaIdentity {
+	switch code {
+	case "d":
+		return ReplicaIdentityDefault
+	case "f":
+		return ReplicaIdentityFull
+	case "i":
+		return ReplicaIdentityIndex
+	default:
+		return ReplicaIdentityNothing
+	}
+}
+
+func (db *DataBase) GetReplicaIdentity(ctx context.Context, table string) (TableReplicaIdentity, error) {
+	var code string
+	result := TableReplicaIdentity{Table: table}
+	err := db.Pool.QueryRow(ctx, `
+		SELECT c.relreplident::text,
+		       COALESCE((SELECT ic.relname FROM pg_index i JOIN pg_class ic ON ic.oid = i.indexrelid
+		                 WHERE i.indrelid = c.oid AND i.indisreplident), ''),
+		       EXISTS (SELECT 1 FROM pg_index i WHERE i.indrelid = c.oid AND i.indisprimary)
+		FROM pg_class c
+		WHERE c.oid = to_regclass($1::text)`, TableIdentifier(table).Sanitize(),
+	).Scan(&code, &result.Index, &result.PrimaryKey)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return TableReplicaIdentity{}, fmt.Errorf("GetReplicaIdentity %s: %w", table, ErrTableNotFound)
+	}
+	if err != nil {
+		return TableReplicaIdentity{}, fmt.Errorf("GetReplicaIdentity: %w", err)
+	}
+	result.Identity = replicaIdentityFromCode(code)
+	return result, nil
+}
+
+// SetReplicaIdentity runs ALTER TABLE ... REPLICA IDENTITY. Index is only used with ReplicaIdentityIndex.
+// FULL makes Postgres log the whole old row, so Debezium update and delete events carry a complete "before".
+func (db *DataBase) SetReplicaIdentity(ctx context.Context, table string, identity ReplicaIdentity, index string) error {
+	sql := fmt.Sprintf("ALTER TABLE %s REPLICA IDENTITY ", TableIdentifier(table).Sanitize())
+	switch identity {
+	case ReplicaIdentityDefault, ReplicaIdentityFull, ReplicaIdentityNothing:
+		sql += string(identity)
+	case ReplicaIdentityIndex:
+		if index == "" {
+			return fmt.Errorf("SetReplicaIdentity: index is required for %s", identity)
+		}
+		sql += string(identity) + " " + pgx.Identifier{index}.Sanitize()
+	default:
+		return fmt.Errorf("SetReplicaIdentity: unknown replica identity %q", identity)
+	}
+	if _, err := db.Pool.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("SetReplicaIdentity: %w", err)
+	}
+	return nil
+}
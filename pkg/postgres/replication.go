@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultOutputPlugin = "pgoutput"
+
+type ReplicationSlot struct {
+	Name              string  `json:"slot_name"`
+	Plugin            string  `json:"plugin"`
+	Type              string  `json:"slot_type"`
+	Database          string  `json:"database"`
+	Active            bool    `json:"active"`
+	ActivePID         *int32  `json:"active_pid,omitempty"`
+	RestartLSN        *string `json:"restart_lsn,omitempty"`
+	ConfirmedFlushLSN *string `json:"confirmed_flush_lsn,omitempty"`
+	RetainedWALBytes  int64   `json:"retained_wal_bytes"`
+	WALStatus         *string `json:"wal_status,omitempty"`
+}
+
+type Publication struct {
+	Name      string   `json:"name"`
+	AllTables bool     `json:"all_tables"`
+	Insert    bool     `json:"insert"`
+	Update    bool     `json:"update"`
+	Delete    bool     `json:"delete"`
+	Truncate  bool     `json:"truncate"`
+	Tables    []string `json:"tables"`
+}
+
+// ListReplicationSlots returns logical replication slots of the current database.
+// RetainedWALBytes is the amount of WAL the server keeps because of the slot's restart_lsn.
+func (db *DataBase) ListReplicationSlots(ctx context.Context) ([]ReplicationSlot, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT slot_name,
+		       COALESCE(plugin, ''),
+		       slot_type,
+		       COALESCE(database, ''),
+		       active,
+		       active_pid,
+		       restart_lsn::text,
+		       confirmed_flush_lsn::text,
+		       COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn), 0)::bigint,
+		       wal_status
+		FROM pg_replication_slots
+		WHERE slot_type = 'logical' AND database = current_database()
+		ORDER BY slot_name`)
+	if err != nil {
+		return nil, fmt.Errorf("ListReplicationSlots.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var slots []ReplicationSlot
+	for rows.Next() {
+		var s ReplicationSlot
+		if err := rows.Scan(
+			&s.Name, &s.Plugin, &s.Type, &s.Database, &s.Active, &s.ActivePID,
+			&s.RestartLSN, &s.ConfirmedFlushLSN, &s.RetainedWALBytes, &s.WALStatus,
+		); err != nil {
+			return nil, fmt.Errorf("ListReplicationSlots.Scan: %w", err)
+		}
+		slots = append(slots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListReplicationSlots.Rows: %w", err)
+	}
+	return slots, nil
+}
+
+// CreateReplicationSlot creates a logical slot and returns its consistent point LSN.
+// An empty plugin defaults to pgoutput, the plugin Debezium uses for Postgres.
+func (db *DataBase) CreateReplicationSlot(ctx context.Context, name, plugin string) (string, error) {
+	if plugin == "" {
+		plugin = defaultOutputPlugin
+	}
+	var lsn string
+	err := db.Pool.QueryRow(ctx,
+		"SELECT lsn::text FROM pg_create_logical_replication_slot($1, $2)", name, plugin,
+	).Scan(&lsn)
+	if err != nil {
+		return "", fmt.Errorf("CreateReplicationSlot: %w", err)
+	}
+	return lsn, nil
+}
+
+func (db *DataBase) DropReplicationSlot(ctx context.Context, name string) error {
+	if _, err := db.Pool.Exec(ctx, "SELECT pg_drop_replication_slot($1)", name); err != nil {
+		return fmt.Errorf("DropReplicationSlot: %w", err)
+	}
+	return nil
+}
+
+func (db *DataBase) ListPublications(ctx context.Context) ([]Publication, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT p.pubname,
+		       p.puballtables,
+		       p.pubinsert,
+		       p.pubupdate,
+		       p.pubdelete,
+		       p.pubtruncate,
+		       COALESCE(array_agg(pt.schemaname || '.' || pt.tablename ORDER BY pt.schemaname, pt.tablename)
+		                FILTER (WHERE pt.tablename IS NOT NULL), '{}')
+		FROM pg_publication p
+		LEFT JOIN pg_publication_tables pt ON pt.pubname = p.pubname
+		GROUP BY p.pubname, p.puballtables, p.pubinsert, p.pubupdate, p.pubdelete, p.pubtruncate
+		ORDER BY p.pubname`)
+	if err != nil {
+		return nil, fmt.Errorf("ListPublications.Query: %w", err)
+	}
+	defer rows.Close()
+
+	var publications []Publication
+	for rows.Next() {
+		var p Publication
+		if err := rows.Scan(&p.Name, &p.AllTables, &p.Insert, &p.Update, &p.Delete, &p.Truncate, &p.Tables); err != nil {
+			return nil, fmt.Errorf("ListPublications.Scan: %w", err)
+		}
+		publications = append(publications, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListPublications.Rows: %w", err)
+	}
+	return publications, nil
+}
+
+func (db *DataBase) CreatePublication(ctx context.Context, name string, tables ...string) error {
+	sql := "CREATE PUBLICATION " + pgx.Identifier{name}.Sanitize()
+	if len(tables) > 0 {
+		sql += " FOR TABLE " + tableList(tables)
+	}
+	if _, err := db.Pool.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("CreatePublication: %w", err)
+	}
+	return nil
+}
+
+func (db *DataBase) AddPublicationTables(ctx context.Context, publication string, tables ...string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	sql := fmt.Sprintf("ALTER PUBLICATION %s ADD TABLE %s", pgx.Identifier{publication}.Sanitize(), tableList(tables))
+	if _, err := db.Pool.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("AddPublicationTables: %w", err)
+	}
+	return nil
+}
+
+func (db *DataBase) RemovePublicationTables(ctx context.Context, publication string, tables ...string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	sql := fmt.Sprintf("ALTER PUBLICATION %s DROP TABLE %s", pgx.Identifier{publication}.Sanitize(), tableList(tables))
+	if _, err := db.Pool.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("RemovePublicationTables: %w", err)
+	}
+	return nil
+}
+
+// EnsurePublication creates the publication if it does not exist and adds the tables it misses.
+func (db *DataBase) EnsurePublication(ctx context.Context, name string, tables ...string) error {
+	publications, err := db.ListPublications(ctx)
+	if err != nil {
+		return fmt.Errorf("EnsurePublication: %w", err)
+	}
+	for _, p := range publications {
+		if p.Name != name {
+			continue
+		}
+		if p.AllTables {
+			return nil
+		}
+		var missing []string
+		for _, t := range tables {
+			if !slices.Contains(p.Tables, qualifiedTable(t)) {
+				missing = append(missing, t)
+			}
+		}
+		return db.AddPublicationTables(ctx, name, missing...)
+	}
+	return db.CreatePublication(ctx, name, tables...)
+}
+
+// TableIdentifier turns "schema.table" (or just "table") into a quoted identifier.
+func TableIdentifier(table string) pgx.Identifier {
+	return pgx.Identifier(strings.SplitN(table, ".", 2))
+}
+
+func tableList(tables []string) string {
+	quoted := make([]string, 0, len(tables))
+	for _, t := range tables {
+		quoted = append(quoted, TableIdentifier(t).Sanitize())
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Querier is implemented by both *pgxpool.Pool and pgx.Tx.
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type txKey struct{}
+
+type txHooksKey struct{}
+
+type txHooks struct {
+	mu    sync.Mutex
+	hooks []func()
+}
+
+// WithTx makes repositories called with the returned context run inside tx.
+func WithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// Conn returns the transaction stored in ctx or the pool.
+func Conn(ctx context.Context, pool *pgxpool.Pool) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return pool
+}
+
+// RunInTx runs fn in a transaction that is committed when fn returns nil. When ctx already
+// carries a transaction fn joins it and the outer caller decides about the commit.
+func RunInTx(ctx context.Context, pool *pgxpool.Pool, fn func(ctx context.Context) error) error {
+	if _, ok := TxFromContext(ctx); ok {
+		return fn(ctx)
+	}
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	hooks := &txHooks{}
+	defer hooks.run()
+	defer tx.Rollback(context.Background())
+	if err := fn(context.WithValue(WithTx(ctx, tx), txHooksKey{}, hooks)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// AfterTx calls fn once the transaction started by RunInTx in ctx has ended, committed or
+// rolled back, and at once when ctx carries none.
+func AfterTx(ctx context.Context, fn func()) {
+	hooks, ok := ctx.Value(txHooksKey{}).(*txHooks)
+	if !ok {
+		fn()
+		return
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.hooks = append(hooks.hooks, fn)
+}
+
+func (h *txHooks) run() {
+	h.mu.Lock()
+	hooks := h.hooks
+	h.mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+func (db *DataBase) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return RunInTx(ctx, db.Pool, fn)
+}